@@ -0,0 +1,328 @@
+package archive
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// FormatName 与 Version 写入 manifest.json，导入时据此识别与兼容旧版本
+	FormatName = "ai-design-project-archive"
+	Version    = 1
+
+	manifestFile = "manifest.json"
+	blobDir      = "blobs/"
+	// maxManifestSize manifest.json 的大小上限
+	maxManifestSize = 32 << 20
+)
+
+var (
+	ErrInvalidArchive = errors.New("invalid project archive")
+	ErrChecksum       = errors.New("archive checksum mismatch")
+
+	keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// Manifest 归档的全部元数据。其中的 ID 均为源实例的 ID，只用于归档内部的相互引用，导入时重新分配
+type Manifest struct {
+	Format      string       `json:"format"`
+	Version     int          `json:"version"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Project     Project      `json:"project"`
+	Scenes      []Scene      `json:"scenes"`
+	Generations []Generation `json:"generations"`
+	Images      []Image      `json:"images"`
+	References  []Reference  `json:"references"`
+	Blobs       []Blob       `json:"blobs"`
+}
+
+type Project struct {
+	ID          uuid.UUID `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Type        string    `json:"type"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Scene struct {
+	ID              uuid.UUID  `json:"id"`
+	OrderIndex      int        `json:"order_index"`
+	StartMs         int64      `json:"start_ms"`
+	EndMs           int64      `json:"end_ms"`
+	Description     string     `json:"description"`
+	VisualPrompt    string     `json:"visual_prompt"`
+	CameraNotes     string     `json:"camera_notes"`
+	SelectedImageID *uuid.UUID `json:"selected_image_id,omitempty"`
+}
+
+type Generation struct {
+	ID              uuid.UUID         `json:"id"`
+	Prompt          string            `json:"prompt"`
+	Model           string            `json:"model"`
+	Size            string            `json:"size"`
+	N               int               `json:"n"`
+	Template        string            `json:"template,omitempty"`
+	TemplateVersion int               `json:"template_version,omitempty"`
+	Variables       map[string]string `json:"variables,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+// Image 已完成的图片记录；ParentImageID 为编辑血缘，只在源图片也在归档中时保留。
+// 每张图片都必须带有 blob，归档不保存上游链接
+type Image struct {
+	ID              uuid.UUID  `json:"id"`
+	ParentImageID   *uuid.UUID `json:"parent_image_id,omitempty"`
+	GenerationID    *uuid.UUID `json:"generation_id,omitempty"`
+	SceneID         *uuid.UUID `json:"scene_id,omitempty"`
+	Prompt          string     `json:"prompt"`
+	Model           string     `json:"model"`
+	Size            string     `json:"size"`
+	Template        string     `json:"template,omitempty"`
+	TemplateVersion int        `json:"template_version,omitempty"`
+	OutputIndex     int        `json:"output_index"`
+	BlobKey         string     `json:"blob_key"`
+	GeneratedAt     *time.Time `json:"generated_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type Reference struct {
+	ID          uuid.UUID `json:"id"`
+	ImageID     uuid.UUID `json:"image_id"`
+	Kind        string    `json:"kind"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Blob 归档中的 blobs/<sha256> 文件
+type Blob struct {
+	SHA256      string `json:"sha256"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// Writer 流式写出归档：先逐个写入 blob，最后写入 manifest.json
+type Writer struct {
+	zw    *zip.Writer
+	blobs map[string]Blob
+	order []string
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w), blobs: map[string]Blob{}}
+}
+
+// AddBlob 写入图片内容并返回其 SHA-256，相同内容只写入一次
+func (w *Writer) AddBlob(data []byte, contentType string) (string, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	if _, ok := w.blobs[key]; ok {
+		return key, nil
+	}
+
+	// 图片本身已压缩，直接存储
+	fw, err := w.zw.CreateHeader(&zip.FileHeader{Name: blobDir + key, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return "", err
+	}
+	w.blobs[key] = Blob{SHA256: key, Size: int64(len(data)), ContentType: contentType}
+	w.order = append(w.order, key)
+	return key, nil
+}
+
+// Close 补全格式信息与 blob 清单，写入 manifest.json 并结束归档
+func (w *Writer) Close(m *Manifest) error {
+	m.Format = FormatName
+	m.Version = Version
+	m.CreatedAt = time.Now().UTC()
+	m.Blobs = make([]Blob, 0, len(w.order))
+	for _, key := range w.order {
+		m.Blobs = append(m.Blobs, w.blobs[key])
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	fw, err := w.zw.CreateHeader(&zip.FileHeader{Name: manifestFile, Method: zip.Deflate, Modified: m.CreatedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// Reader 读取并校验归档
+type Reader struct {
+	Manifest *Manifest
+	files    map[string]*zip.File
+	blobs    map[string]Blob
+	maxBlob  int64
+}
+
+// Open 解析归档并校验 manifest：格式与版本、引用完整性，以及每个 blob 声明的大小不超过 maxBlob 且与 zip 头一致。
+// blob 内容的 SHA-256 在 Blob 读取时校验，每个 blob 只需读取一次
+func Open(r io.ReaderAt, size, maxBlob int64) (*Reader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	ar := &Reader{files: map[string]*zip.File{}, blobs: map[string]Blob{}, maxBlob: maxBlob}
+	for _, f := range zr.File {
+		ar.files[f.Name] = f
+	}
+
+	raw, err := ar.read(manifestFile, maxManifestSize)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("%w: manifest.json: %v", ErrInvalidArchive, err)
+	}
+	if m.Format != FormatName {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidArchive, m.Format)
+	}
+	if m.Version < 1 || m.Version > Version {
+		return nil, fmt.Errorf("%w: unsupported version %d (this server supports up to %d)", ErrInvalidArchive, m.Version, Version)
+	}
+	ar.Manifest = &m
+
+	if err := ar.verify(); err != nil {
+		return nil, err
+	}
+	return ar, nil
+}
+
+// Blob 读取 blob 内容并校验大小与 SHA-256，不一致时返回 ErrChecksum
+func (r *Reader) Blob(key string) ([]byte, Blob, error) {
+	blob, ok := r.blobs[key]
+	if !ok {
+		return nil, Blob{}, fmt.Errorf("%w: blob %s is not listed", ErrInvalidArchive, key)
+	}
+	// verify 已保证 blob.Size 不超过 maxBlob
+	data, err := r.read(blobDir+key, blob.Size)
+	if err != nil {
+		return nil, Blob{}, err
+	}
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != blob.Size || hex.EncodeToString(sum[:]) != key {
+		return nil, Blob{}, fmt.Errorf("%w: blob %s", ErrChecksum, key)
+	}
+	return data, blob, nil
+}
+
+func (r *Reader) read(name string, limit int64) ([]byte, error) {
+	f, ok := r.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, name)
+	}
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidArchive, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	defer rc.Close()
+
+	// 不信任 zip 头中的大小，按上限读取
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidArchive, name)
+	}
+	return data, nil
+}
+
+// verify 检查所有引用都能在归档内解析，每张图片都有 blob，且 blob 的大小在上限之内
+func (r *Reader) verify() error {
+	m := r.Manifest
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidArchive, fmt.Sprintf(format, args...))
+	}
+
+	for _, blob := range m.Blobs {
+		if !keyPattern.MatchString(blob.SHA256) {
+			return invalid("invalid blob key %q", blob.SHA256)
+		}
+		if blob.Size <= 0 || blob.Size > r.maxBlob {
+			return invalid("blob %s has invalid size %d (limit %d)", blob.SHA256, blob.Size, r.maxBlob)
+		}
+		// zip 头中的解压后大小必须与声明一致；读取时仍按声明的大小截断，防止压缩炸弹
+		f, ok := r.files[blobDir+blob.SHA256]
+		if !ok {
+			return invalid("missing %s%s", blobDir, blob.SHA256)
+		}
+		if f.UncompressedSize64 != uint64(blob.Size) {
+			return fmt.Errorf("%w: blob %s", ErrChecksum, blob.SHA256)
+		}
+		r.blobs[blob.SHA256] = blob
+	}
+
+	images := map[uuid.UUID]bool{}
+	for _, image := range m.Images {
+		if images[image.ID] {
+			return invalid("duplicate image %s", image.ID)
+		}
+		images[image.ID] = true
+		if image.BlobKey == "" {
+			return invalid("image %s has no content", image.ID)
+		}
+		if _, ok := r.blobs[image.BlobKey]; !ok {
+			return invalid("image %s references missing blob %s", image.ID, image.BlobKey)
+		}
+	}
+	generations := map[uuid.UUID]bool{}
+	for _, g := range m.Generations {
+		generations[g.ID] = true
+	}
+	scenes := map[uuid.UUID]bool{}
+	for _, scene := range m.Scenes {
+		if scenes[scene.ID] {
+			return invalid("duplicate scene %s", scene.ID)
+		}
+		scenes[scene.ID] = true
+		if scene.StartMs < 0 || scene.EndMs < scene.StartMs {
+			return invalid("scene %s has an invalid time range", scene.ID)
+		}
+		if scene.SelectedImageID != nil && !images[*scene.SelectedImageID] {
+			return invalid("scene %s selects missing image %s", scene.ID, *scene.SelectedImageID)
+		}
+	}
+	for _, image := range m.Images {
+		if image.ParentImageID != nil && !images[*image.ParentImageID] {
+			return invalid("image %s has missing parent %s", image.ID, *image.ParentImageID)
+		}
+		if image.GenerationID != nil && !generations[*image.GenerationID] {
+			return invalid("image %s references missing generation %s", image.ID, *image.GenerationID)
+		}
+		if image.SceneID != nil && !scenes[*image.SceneID] {
+			return invalid("image %s references missing scene %s", image.ID, *image.SceneID)
+		}
+	}
+	for _, ref := range m.References {
+		if !images[ref.ImageID] {
+			return invalid("reference %s uses missing image %s", ref.ID, ref.ImageID)
+		}
+	}
+	return nil
+}
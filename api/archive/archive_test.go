@@ -0,0 +1,202 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// build 写出一个包含一个镜头、两张图片（编辑血缘）与一个参考素材的归档
+func build(t *testing.T, edit func(m *Manifest)) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	key, err := w.AddBlob([]byte("first image"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 相同内容只写入一次
+	if again, _ := w.AddBlob([]byte("first image"), "image/png"); again != key {
+		t.Fatalf("duplicate blob got key %s, want %s", again, key)
+	}
+	key2, err := w.AddBlob([]byte("second image"), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sceneID, parentID, childID := uuid.New(), uuid.New(), uuid.New()
+	m := &Manifest{
+		Project: Project{ID: uuid.New(), Title: "Lighthouse", Type: "storyboard", CreatedAt: time.Now()},
+		Scenes:  []Scene{{ID: sceneID, StartMs: 0, EndMs: 5000, Description: "dawn", SelectedImageID: &childID}},
+		Images: []Image{
+			{ID: parentID, BlobKey: key, SceneID: &sceneID},
+			{ID: childID, BlobKey: key2, ParentImageID: &parentID, SceneID: &sceneID},
+		},
+		References: []Reference{{ID: uuid.New(), ImageID: parentID, Kind: "character", Name: "keeper"}},
+	}
+	if edit != nil {
+		edit(m)
+	}
+	if err := w.Close(m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func open(data []byte, maxBlob int64) (*Reader, error) {
+	return Open(bytes.NewReader(data), int64(len(data)), maxBlob)
+}
+
+func TestRoundTrip(t *testing.T) {
+	r, err := open(build(t, nil), 1<<20)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	m := r.Manifest
+	if m.Format != FormatName || m.Version != Version || m.Project.Title != "Lighthouse" {
+		t.Fatalf("manifest = %+v", m)
+	}
+	if len(m.Blobs) != 2 || len(m.Images) != 2 || len(m.Scenes) != 1 || len(m.References) != 1 {
+		t.Fatalf("manifest counts: %d blobs, %d images, %d scenes, %d references", len(m.Blobs), len(m.Images), len(m.Scenes), len(m.References))
+	}
+	data, blob, err := r.Blob(m.Images[1].BlobKey)
+	if err != nil {
+		t.Fatalf("Blob: %v", err)
+	}
+	if string(data) != "second image" || blob.ContentType != "image/jpeg" || blob.Size != int64(len(data)) {
+		t.Errorf("blob = %q, %+v", data, blob)
+	}
+	if _, _, err := r.Blob(strings.Repeat("0", 64)); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("unlisted blob: err = %v", err)
+	}
+}
+
+func TestOpenRejectsBrokenReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(m *Manifest)
+	}{
+		{"image without blob", func(m *Manifest) { m.Images[0].BlobKey = "" }},
+		{"image with unknown blob", func(m *Manifest) { m.Images[0].BlobKey = strings.Repeat("a", 64) }},
+		{"duplicate image", func(m *Manifest) { m.Images[1].ID = m.Images[0].ID }},
+		{"missing parent", func(m *Manifest) { id := uuid.New(); m.Images[1].ParentImageID = &id }},
+		{"missing generation", func(m *Manifest) { id := uuid.New(); m.Images[0].GenerationID = &id }},
+		{"missing scene", func(m *Manifest) { id := uuid.New(); m.Images[0].SceneID = &id }},
+		{"scene selects missing image", func(m *Manifest) { id := uuid.New(); m.Scenes[0].SelectedImageID = &id }},
+		{"reversed time range", func(m *Manifest) { m.Scenes[0].StartMs = 6000 }},
+		{"reference to missing image", func(m *Manifest) { m.References[0].ImageID = uuid.New() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := open(build(t, tt.edit), 1<<20); !errors.Is(err, ErrInvalidArchive) {
+				t.Fatalf("err = %v, want ErrInvalidArchive", err)
+			}
+		})
+	}
+}
+
+func TestOpenRejectsOversizedBlobs(t *testing.T) {
+	data := build(t, nil)
+	// "second image" 为 12 字节
+	if _, err := open(data, 11); !errors.Is(err, ErrInvalidArchive) {
+		t.Fatalf("err = %v, want ErrInvalidArchive", err)
+	}
+	if _, err := open(data, 12); err != nil {
+		t.Fatalf("Open at the limit: %v", err)
+	}
+}
+
+// rewrite 按 edit 改写归档中的文件后重新打包
+func rewrite(t *testing.T, data []byte, edit func(name string, body []byte) (*zip.FileHeader, []byte)) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := new(bytes.Buffer)
+		body.ReadFrom(rc)
+		rc.Close()
+		fh, out := edit(f.Name, body.Bytes())
+		if fh == nil {
+			fh = &zip.FileHeader{Name: f.Name, Method: f.Method}
+		}
+		fw, err := zw.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(out)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBlobChecksum(t *testing.T) {
+	tampered := rewrite(t, build(t, nil), func(name string, body []byte) (*zip.FileHeader, []byte) {
+		if strings.HasPrefix(name, blobDir) && string(body) == "first image" {
+			return nil, []byte("FIRST IMAGE")
+		}
+		return nil, body
+	})
+	r, err := open(tampered, 1<<20)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, _, err := r.Blob(r.Manifest.Images[0].BlobKey); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("err = %v, want ErrChecksum", err)
+	}
+}
+
+func TestOpenRejectsDeflateBomb(t *testing.T) {
+	data := build(t, nil)
+	var key string
+	{
+		r, err := open(data, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key = r.Manifest.Images[0].BlobKey
+	}
+
+	// blob 被替换为可高度压缩的大文件，manifest 中声明的大小不变
+	bomb := rewrite(t, data, func(name string, body []byte) (*zip.FileHeader, []byte) {
+		if name == blobDir+key {
+			return &zip.FileHeader{Name: name, Method: zip.Deflate}, make([]byte, 8<<20)
+		}
+		return nil, body
+	})
+	if _, err := open(bomb, 1<<20); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("err = %v, want ErrChecksum", err)
+	}
+
+	// manifest 声明的大小超过上限
+	inflated := rewrite(t, data, func(name string, body []byte) (*zip.FileHeader, []byte) {
+		if name != manifestFile {
+			return nil, body
+		}
+		var m Manifest
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatal(err)
+		}
+		m.Blobs[0].Size = 8 << 20
+		out, _ := json.Marshal(m)
+		return nil, out
+	})
+	if _, err := open(inflated, 1<<20); !errors.Is(err, ErrInvalidArchive) {
+		t.Fatalf("err = %v, want ErrInvalidArchive", err)
+	}
+}
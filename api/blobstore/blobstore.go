@@ -0,0 +1,84 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+
+	"ai-design-backend/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Info 描述一个 blob；Key 为内容的 SHA-256 十六进制值
+type Info struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// Store 按内容寻址的 blob 存储。相同内容的 Put 返回同一个 Key 且只保存一份。
+// 本地文件系统之外，S3/MinIO 等对象存储也可以实现该接口。
+type Store interface {
+	Put(ctx context.Context, data []byte, contentType string) (Info, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var Default Store
+
+func Init() {
+	switch config.Config.BlobDriver {
+	case "local", "":
+		store, err := NewLocalStore(config.Config.BlobDir)
+		if err != nil {
+			log.Fatal("Failed to init blob store:", err)
+		}
+		Default = store
+		log.Printf("Using local blob store at %s", config.Config.BlobDir)
+	default:
+		log.Fatalf("Unknown BLOB_DRIVER %q (expected local)", config.Config.BlobDriver)
+	}
+}
+
+// KeyOf 计算内容的 Key
+func KeyOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func validKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
+
+// DetectContentType 在调用方未提供类型时根据内容探测
+func DetectContentType(data []byte, contentType string) string {
+	if contentType != "" && contentType != "application/octet-stream" {
+		return contentType
+	}
+	return http.DetectContentType(data)
+}
+
+// ReadAll 读取整个 blob
+func ReadAll(ctx context.Context, store Store, key string) ([]byte, Info, error) {
+	rc, info, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	return data, info, err
+}
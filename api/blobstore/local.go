@@ -0,0 +1,118 @@
+package blobstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore 将 blob 保存在本地目录，按 Key 前缀分两级子目录
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{root: root}, nil
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, key[:2], key[2:4], key)
+}
+
+func (s *LocalStore) Put(ctx context.Context, data []byte, contentType string) (Info, error) {
+	key := KeyOf(data)
+	info := Info{Key: key, Size: int64(len(data)), ContentType: DetectContentType(data, contentType)}
+
+	path := s.path(key)
+	if _, err := os.Stat(path); err == nil {
+		// 内容已存在，去重
+		return info, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Info{}, err
+	}
+
+	// 先写临时文件再重命名，避免并发写入或中途失败留下不完整的 blob
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp-*")
+	if err != nil {
+		return Info{}, err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return Info{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return Info{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return Info{}, err
+	}
+	return info, nil
+}
+
+type blobFile struct {
+	*bufio.Reader
+	file *os.File
+}
+
+func (f blobFile) Close() error {
+	return f.file.Close()
+}
+
+func (s *LocalStore) Open(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	if err := validKey(key); err != nil {
+		return nil, Info{}, err
+	}
+
+	file, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, Info{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, Info{}, err
+	}
+
+	reader := bufio.NewReader(file)
+	head, _ := reader.Peek(512)
+	info := Info{Key: key, Size: stat.Size(), ContentType: http.DetectContentType(head)}
+	return blobFile{Reader: reader, file: file}, info, nil
+}
+
+func (s *LocalStore) Exists(ctx context.Context, key string) (bool, error) {
+	if err := validKey(key); err != nil {
+		return false, err
+	}
+	_, err := os.Stat(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
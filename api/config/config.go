@@ -3,29 +3,100 @@ package config
 import (
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"ai-design-backend/models"
 	"ai-design-backend/storage"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var (
-	Storage *storage.MemoryStorage
+	Storage storage.Store
+	DB      *gorm.DB
 	Config  *AppConfig
 )
 
 type AppConfig struct {
     Port           string
     JWTSecret      string
+    JWTKeysDir      string // RS256/EdDSA 密钥目录，为空时使用 JWTSecret (HS256)
+    JWTSigningKeyID string
+    JWTIssuer       string
+    BcryptCost     int
+    AccessTokenTTL  time.Duration
+    RefreshTokenTTL time.Duration
     QiniuAPIKey    string
     QiniuBaseURL   string
     Environment    string
-    MaxImageSize   int64
+    MaxImageSize   int64 // 上传或内联图片的大小上限（字节）
+    MaxImagePixels int64 // 图片的像素数上限（宽×高），解码前按文件头检查，防止小文件声明超大尺寸
+    MaxArchiveSize int64 // 导入项目归档的大小上限（字节）
     AllowedOrigins []string
+    StorageDriver  string // memory, sqlite
+    DatabasePath   string
+    JobWorkers     int
+    JobQueueSize   int
+
+    // 图片 provider：qiniu、openai 或 mock，未单独注册的模型都交给 ImageProvider
+    ImageProvider     string
+    DefaultImageModel string
+    OpenAIBaseURL     string
+    OpenAIAPIKey      string
+    OpenAIImageModels []string
+    MockImageModels   []string
+
+    // 分镜脚本生成：qiniu（调用 QiniuBaseURL 的 chat/completions）或 mock
+    ScriptProvider string
+    ScriptModel    string
+
+    // 动画小样字幕使用的 TTF/OTF 字体文件，为空时使用内置的 Go 字体（不含中文字形）
+    CaptionFont string
+
+    BlobDriver string // local
+    BlobDir    string
+
+    // 用户上游 API Key 的信封加密主密钥（32 字节，base64 或 hex），为空时不启用
+    VaultMasterKey    string
+    VaultPreviousKeys []string // 轮换前的旧主密钥，仅用于解密
+
+    // 用量与额度
+    PriceTable        []string // model=price 或 model@size=price，* 为默认价格，单位为每张图片
+    CreditsEnabled    bool     // 为 true 时使用服务端 key 的调用需要扣除额度
+    InitialCredits    string   // 新用户的初始额度
+    MonthlyImageQuota int      // 每个用户每月可生成的图片数，0 表示不限
+    // AdminUserIDs 始终视为管理员的用户 ID，用于引导第一个管理员；其他管理员由 users.role 决定
+    AdminUserIDs      []string
+
+    // 限流：每个路由组的限制形如 10/m、100/h，0 或 off 表示不限
+    RateLimitBackend  string // memory
+    RateLimitAuth     string // 登录、注册、刷新令牌，按 IP
+    RateLimitAPI      string // 其他需要登录的接口，按用户
+    RateLimitGenerate string // 生成与编辑，按用户
+    RateLimitProxy    string // 代理接口，按 API Key、用户或透传的上游 key
+    RateLimitProxyIP  string // 代理接口认证之前，按客户端 IP
+    // TrustedProxies 可信反向代理的 IP 或 CIDR，只有来自这些地址的请求才采信 X-Forwarded-For；默认不信任任何代理
+    TrustedProxies    []string
+
+    // 上游调用：超时、重试与熔断
+    UpstreamTimeout        time.Duration
+    UpstreamConnectTimeout time.Duration
+    UpstreamMaxIdleConns   int // 每个上游主机保持的空闲连接数
+    UpstreamMaxRetries     int
+    UpstreamRetryBaseDelay time.Duration
+    UpstreamRetryMaxDelay  time.Duration
+    BreakerThreshold       int // 连续失败多少次后熔断，0 表示不熔断
+    BreakerCooldown        time.Duration
+    // ImageFetchAllowPrivate 允许拉取解析到内网、回环等地址的图片 URL，仅用于本地开发或内网部署的上游
+    ImageFetchAllowPrivate bool
 }
 
 func InitConfig() {
@@ -35,23 +106,111 @@ func InitConfig() {
     Config = &AppConfig{
         Port:           getEnv("PORT", "8080"),
         JWTSecret:      getEnv("JWT_SECRET", "your-jwt-secret-key"),
+        JWTKeysDir:      getEnv("JWT_KEYS_DIR", ""),
+        JWTSigningKeyID: getEnv("JWT_SIGNING_KEY_ID", ""),
+        JWTIssuer:       getEnv("JWT_ISSUER", "ai-design-backend"),
+        BcryptCost:     getEnvInt("BCRYPT_COST", 12),
+        AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+        RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
         QiniuAPIKey:    getEnv("QINIU_API_KEY", "your-api-key-here"),
         QiniuBaseURL:   getEnv("QINIU_BASE_URL", "https://api.qnaigc.com/v1"),
         Environment:    getEnv("ENVIRONMENT", "development"),
-        MaxImageSize:   10 * 1024 * 1024,
+        MaxImageSize:   int64(getEnvInt("MAX_IMAGE_SIZE", 10*1024*1024)),
+        MaxImagePixels: int64(getEnvInt("MAX_IMAGE_PIXELS", 40*1000*1000)),
+        MaxArchiveSize: int64(getEnvInt("MAX_ARCHIVE_SIZE", 512*1024*1024)),
         AllowedOrigins: getEnvList("ALLOWED_ORIGINS", []string{"http://localhost:5173", "http://localhost:3000", "http://localhost:6677", "http://127.0.0.1:6677"}),
+        StorageDriver:  strings.ToLower(getEnv("STORAGE_DRIVER", "memory")),
+        DatabasePath:   getEnv("DATABASE_PATH", "data/ai-design.db"),
+        JobWorkers:     getEnvInt("JOB_WORKERS", 4),
+        JobQueueSize:   getEnvInt("JOB_QUEUE_SIZE", 200),
+
+        ImageProvider:     strings.ToLower(getEnv("IMAGE_PROVIDER", "qiniu")),
+        DefaultImageModel: getEnv("DEFAULT_IMAGE_MODEL", "gemini-2.5-flash-image"),
+        OpenAIBaseURL:     getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+        OpenAIAPIKey:      getEnv("OPENAI_API_KEY", ""),
+        OpenAIImageModels: getEnvList("OPENAI_IMAGE_MODELS", []string{"gpt-image-1", "dall-e-3"}),
+        MockImageModels:   getEnvList("MOCK_IMAGE_MODELS", []string{"mock-image"}),
+
+        ScriptProvider: strings.ToLower(getEnv("SCRIPT_PROVIDER", "qiniu")),
+        ScriptModel:    getEnv("SCRIPT_MODEL", "deepseek-v3"),
+
+        CaptionFont: getEnv("CAPTION_FONT", ""),
+
+        BlobDriver: strings.ToLower(getEnv("BLOB_DRIVER", "local")),
+        BlobDir:    getEnv("BLOB_DIR", "data/blobs"),
+
+        VaultMasterKey:    getEnv("VAULT_MASTER_KEY", ""),
+        VaultPreviousKeys: getEnvList("VAULT_PREVIOUS_KEYS", nil),
+
+        PriceTable:        getEnvList("PRICE_TABLE", []string{"gemini-2.5-flash-image=0.039", "gpt-image-1=0.04", "dall-e-3=0.04", "mock-image=0", "*=0.04"}),
+        CreditsEnabled:    getEnv("CREDITS_ENABLED", "false") == "true",
+        InitialCredits:    getEnv("INITIAL_CREDITS", "0"),
+        MonthlyImageQuota: getEnvInt("MONTHLY_IMAGE_QUOTA", 0),
+        AdminUserIDs:      getEnvList("ADMIN_USER_IDS", nil),
+
+        RateLimitBackend:  strings.ToLower(getEnv("RATE_LIMIT_BACKEND", "memory")),
+        RateLimitAuth:     getEnv("RATE_LIMIT_AUTH", "10/m"),
+        RateLimitAPI:      getEnv("RATE_LIMIT_API", "300/m"),
+        RateLimitGenerate: getEnv("RATE_LIMIT_GENERATE", "20/m"),
+        RateLimitProxy:    getEnv("RATE_LIMIT_PROXY", "60/m"),
+        RateLimitProxyIP:  getEnv("RATE_LIMIT_PROXY_IP", "120/m"),
+        TrustedProxies:    getEnvList("TRUSTED_PROXIES", nil),
+
+        UpstreamTimeout:        getEnvDuration("UPSTREAM_TIMEOUT", 120*time.Second),
+        UpstreamConnectTimeout: getEnvDuration("UPSTREAM_CONNECT_TIMEOUT", 10*time.Second),
+        UpstreamMaxIdleConns:   getEnvInt("UPSTREAM_MAX_IDLE_CONNS", 32),
+        UpstreamMaxRetries:     getEnvInt("UPSTREAM_MAX_RETRIES", 2),
+        UpstreamRetryBaseDelay: getEnvDuration("UPSTREAM_RETRY_BASE_DELAY", 500*time.Millisecond),
+        UpstreamRetryMaxDelay:  getEnvDuration("UPSTREAM_RETRY_MAX_DELAY", 10*time.Second),
+        BreakerThreshold:       getEnvInt("BREAKER_THRESHOLD", 5),
+        BreakerCooldown:        getEnvDuration("BREAKER_COOLDOWN", 30*time.Second),
+        ImageFetchAllowPrivate: getEnv("IMAGE_FETCH_ALLOW_PRIVATE", "false") == "true",
     }
 }
 
 func InitDB() {
-	// 使用内存存储
-	Storage = storage.GetMemoryStorage()
-	log.Println("Using memory storage for development")
+	switch Config.StorageDriver {
+	case "sqlite":
+		if dir := filepath.Dir(Config.DatabasePath); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				log.Fatal("Failed to create database directory:", err)
+			}
+		}
+
+		logLevel := logger.Warn
+		if Config.Environment == "production" {
+			logLevel = logger.Error
+		}
+
+		db, err := gorm.Open(sqlite.Open(Config.DatabasePath), &gorm.Config{
+			Logger: logger.Default.LogMode(logLevel),
+			// 图片允许不关联项目，且删除项目时不级联，因此不创建外键约束
+			DisableForeignKeyConstraintWhenMigrating: true,
+		})
+		if err != nil {
+			log.Fatal("Failed to open database:", err)
+		}
+		DB = db
+		Storage = storage.NewGormStorage(db)
+		log.Printf("Using sqlite storage at %s", Config.DatabasePath)
+	case "memory", "":
+		// 使用内存存储
+		Storage = storage.GetMemoryStorage()
+		log.Println("Using memory storage for development")
+	default:
+		log.Fatalf("Unknown STORAGE_DRIVER %q (expected memory or sqlite)", Config.StorageDriver)
+	}
 }
 
 func AutoMigrate() {
-	// 这里会自动创建表结构
-	// DB.AutoMigrate(&models.User{}, &models.Project{}, &models.Image{})
+	// 内存存储无需建表
+	if DB == nil {
+		return
+	}
+	if err := DB.AutoMigrate(&models.User{}, &models.Project{}, &models.ProjectMember{}, &models.Organization{}, &models.OrgMember{}, &models.ProviderCredential{}, &models.AccessKey{}, &models.ProxyLog{}, &models.UsageEntry{}, &models.UsageAccount{}, &models.Image{}, &models.Generation{}, &models.PromptTemplate{}, &models.Scene{}, &models.ReferenceAsset{}, &models.Job{},
+		&models.AuthSession{}, &models.RefreshToken{}, &models.RevokedToken{}); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
 }
 
 func GetPort() string {
@@ -76,8 +235,8 @@ func CORSMiddleware() gin.HandlerFunc {
             return false
         },
         AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-        AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Requested-With"},
-        ExposeHeaders:    []string{"Content-Length"},
+        AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Requested-With", "X-Workspace-ID"},
+        ExposeHeaders:    []string{"Content-Length", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
         AllowCredentials: true,
         MaxAge:           12 * time.Hour,
     })
@@ -106,3 +265,23 @@ func getEnvList(key string, defaultValue []string) []string {
     }
     return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		log.Printf("Invalid integer for %s: %q, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid duration for %s: %q, using default %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
@@ -0,0 +1,351 @@
+package export
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"ai-design-backend/config"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// 动画小样格式
+const (
+	FormatGIF  = "gif"
+	FormatAPNG = "apng"
+)
+
+const (
+	DefaultAnimaticWidth = 640
+	MinAnimaticWidth     = 160
+	MaxAnimaticWidth     = 960
+	MaxCrossfadeMs       = 2000
+	// minSceneMs 镜头时长缺失或过短时的最短显示时间
+	minSceneMs = 100
+	// 转场每 100ms 一帧，最多 6 帧，避免 GIF 帧数过多
+	crossfadeStepMs  = 100
+	maxCrossfadeStep = 6
+	maxCaptionLines  = 3
+	// maxStepMs 单帧最长显示时间：GIF 与 APNG 的帧延时都是 16 位，以 1/100 秒计最多 655.35 秒，
+	// 更长的镜头拆成多帧
+	maxStepMs = 0xffff * 10
+)
+
+var (
+	animaticBackground = color.Black
+	captionBand        = color.RGBA{0, 0, 0, 0xa0}
+	captionText        = color.White
+	missingFrameColor  = color.RGBA{0x22, 0x22, 0x22, 0xff}
+)
+
+// captionFont 字幕字体，由 Init 加载
+var captionFont *opentype.Font
+
+// Init 读取画面像素数上限，并加载字幕字体：CAPTION_FONT 指定的 TTF/OTF/TTC 文件，未配置时使用内置的 Go 字体
+func Init() {
+	if config.Config.MaxImagePixels > 0 {
+		MaxPixels = config.Config.MaxImagePixels
+	}
+
+	data := goregular.TTF
+	if path := config.Config.CaptionFont; path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Fatal("Invalid CAPTION_FONT:", err)
+		}
+	}
+
+	f, err := parseFont(data)
+	if err != nil {
+		log.Fatal("Invalid CAPTION_FONT:", err)
+	}
+	captionFont = f
+}
+
+// parseFont 解析单个字体文件，字体集合（TTC）取第一个字体
+func parseFont(data []byte) (*opentype.Font, error) {
+	if f, err := opentype.Parse(data); err == nil {
+		return f, nil
+	}
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, err
+	}
+	return collection.Font(0)
+}
+
+// AnimaticOptions 动画小样参数
+type AnimaticOptions struct {
+	Width       int   // 画面宽度（像素），高度按 16:9 计算
+	CrossfadeMs int64 // 镜头之间的淡入淡出时长，0 为硬切
+	Captions    bool  // 是否在画面底部烧录镜头描述
+}
+
+// Normalize 校验参数并补齐默认值
+func (o *AnimaticOptions) Normalize() error {
+	if o.Width == 0 {
+		o.Width = DefaultAnimaticWidth
+	}
+	if o.Width < MinAnimaticWidth || o.Width > MaxAnimaticWidth {
+		return fmt.Errorf("width must be between %d and %d", MinAnimaticWidth, MaxAnimaticWidth)
+	}
+	if o.CrossfadeMs < 0 || o.CrossfadeMs > MaxCrossfadeMs {
+		return fmt.Errorf("crossfade_ms must be between 0 and %d", MaxCrossfadeMs)
+	}
+	return nil
+}
+
+// IsAnimatic 判断格式是否为动画小样
+func IsAnimatic(format string) bool {
+	return format == FormatGIF || format == FormatAPNG
+}
+
+// animStep 动画中的一帧：from 镜头按 mix 比例过渡到 to 镜头，mix 为 0 时只显示 from
+type animStep struct {
+	from, to   int
+	mix        float64
+	durationMs int64
+}
+
+// timeline 计算帧序列：每个镜头显示其时长，转场以切点为中心，前后镜头各让出一半时间，总时长不变
+func timeline(frames []Frame, crossfadeMs int64) []animStep {
+	n := len(frames)
+	durations := make([]int64, n)
+	for i, f := range frames {
+		durations[i] = max(f.EndMs-f.StartMs, minSceneMs)
+	}
+	// fades[i] 为第 i 与 i+1 个镜头之间的转场时长，不超过两侧镜头时长的一半
+	fades := make([]int64, n)
+	for i := 0; i < n-1; i++ {
+		fades[i] = min(crossfadeMs, durations[i]/2, durations[i+1]/2)
+	}
+
+	var steps []animStep
+	var prevFade int64
+	for i := range frames {
+		for hold := durations[i] - prevFade/2 - fades[i]/2; hold > 0; hold -= maxStepMs {
+			steps = append(steps, animStep{from: i, to: i, durationMs: min(hold, maxStepMs)})
+		}
+		if fade := fades[i]; fade > 0 {
+			count := min(max(fade/crossfadeStepMs, 1), maxCrossfadeStep)
+			for k := int64(0); k < count; k++ {
+				steps = append(steps, animStep{
+					from:       i,
+					to:         i + 1,
+					mix:        float64(k+1) / float64(count+1),
+					durationMs: fade*(k+1)/count - fade*k/count,
+				})
+			}
+		}
+		prevFade = fades[i]
+	}
+	return steps
+}
+
+// animator 按需渲染镜头画面（含字幕），只缓存当前用到的两个镜头
+type animator struct {
+	sb    *Storyboard
+	opts  AnimaticOptions
+	face  font.Face
+	cache map[int]*image.RGBA
+	bound image.Rectangle
+}
+
+func newAnimator(sb *Storyboard, opts AnimaticOptions) (*animator, error) {
+	if captionFont == nil {
+		return nil, fmt.Errorf("caption font is not loaded")
+	}
+	height := (opts.Width * 9 / 16) &^ 1 // 偶数高度
+	face, err := opentype.NewFace(captionFont, &opentype.FaceOptions{
+		Size:    float64(height) / 18,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &animator{
+		sb:    sb,
+		opts:  opts,
+		face:  face,
+		cache: map[int]*image.RGBA{},
+		bound: image.Rect(0, 0, opts.Width, height),
+	}, nil
+}
+
+// slide 镜头 i 的完整画面
+func (a *animator) slide(i int) *image.RGBA {
+	if img, ok := a.cache[i]; ok {
+		return img
+	}
+	for k := range a.cache {
+		if k < i-1 {
+			delete(a.cache, k)
+		}
+	}
+
+	canvas := image.NewRGBA(a.bound)
+	draw.Draw(canvas, a.bound, image.NewUniform(animaticBackground), image.Point{}, draw.Src)
+	f := &a.sb.Frames[i]
+	if src := f.decode(); src != nil {
+		xdraw.CatmullRom.Scale(canvas, fitRect(src.Bounds(), a.bound), src, src.Bounds(), draw.Src, nil)
+	} else {
+		draw.Draw(canvas, a.bound, image.NewUniform(missingFrameColor), image.Point{}, draw.Src)
+		a.centerText(canvas, "No frame", a.bound.Dy()/2)
+	}
+	if a.opts.Captions {
+		a.caption(canvas, f.Description)
+	}
+	a.cache[i] = canvas
+	return canvas
+}
+
+// frame 渲染一个动画步骤
+func (a *animator) frame(step animStep) *image.RGBA {
+	from := a.slide(step.from)
+	if step.mix == 0 {
+		return from
+	}
+	to := a.slide(step.to)
+	out := image.NewRGBA(a.bound)
+	for p := range out.Pix {
+		out.Pix[p] = uint8(float64(from.Pix[p])*(1-step.mix) + float64(to.Pix[p])*step.mix + 0.5)
+	}
+	return out
+}
+
+// caption 在画面底部半透明底条上居中绘制描述，最多 maxCaptionLines 行
+func (a *animator) caption(canvas *image.RGBA, text string) {
+	width := a.bound.Dx()
+	lines := breakText(text, float64(width)*0.9, func(r rune) float64 {
+		adv, _ := a.face.GlyphAdvance(r)
+		return float64(adv) / 64
+	})
+	if len(lines) == 0 {
+		return
+	}
+	if len(lines) > maxCaptionLines {
+		lines = lines[:maxCaptionLines]
+		lines[maxCaptionLines-1] += "..."
+	}
+
+	metrics := a.face.Metrics()
+	lineH := metrics.Height.Ceil()
+	pad := lineH / 3
+	top := a.bound.Dy() - len(lines)*lineH - 2*pad
+	draw.Draw(canvas, image.Rect(0, top, width, a.bound.Dy()), image.NewUniform(captionBand), image.Point{}, draw.Over)
+	for i, line := range lines {
+		a.centerText(canvas, line, top+pad+i*lineH+metrics.Ascent.Ceil())
+	}
+}
+
+// centerText 以 baseline 为基线水平居中绘制一行文字
+func (a *animator) centerText(canvas *image.RGBA, text string, baseline int) {
+	d := &font.Drawer{Dst: canvas, Src: image.NewUniform(captionText), Face: a.face}
+	x := (fixed.I(a.bound.Dx()) - d.MeasureString(text)) / 2
+	d.Dot = fixed.Point26_6{X: x, Y: fixed.I(baseline)}
+	d.DrawString(text)
+}
+
+// WriteAnimatic 将镜头画面按各自时长渲染为循环播放的 GIF 或 APNG
+func WriteAnimatic(w io.Writer, format string, sb *Storyboard, opts AnimaticOptions) error {
+	if len(sb.Frames) == 0 {
+		return fmt.Errorf("storyboard has no scenes")
+	}
+	if err := opts.Normalize(); err != nil {
+		return err
+	}
+	a, err := newAnimator(sb, opts)
+	if err != nil {
+		return err
+	}
+	defer a.face.Close()
+
+	steps := timeline(sb.Frames, opts.CrossfadeMs)
+	switch format {
+	case FormatGIF:
+		return writeGIF(w, a, steps)
+	case FormatAPNG:
+		enc := newAPNGEncoder(w, len(steps))
+		for _, step := range steps {
+			if err := enc.add(a.frame(step), step.durationMs); err != nil {
+				return err
+			}
+		}
+		return enc.close()
+	}
+	return fmt.Errorf("unsupported animatic format %q", format)
+}
+
+// writeGIF 使用 Plan 9 调色板与误差扩散抖动量化每一帧并逐帧写出；
+// 帧延时按累计时间取整到 1/100 秒，避免误差累积
+func writeGIF(w io.Writer, a *animator, steps []animStep) error {
+	enc := newGIFEncoder(w)
+	var elapsed int64
+	var paletted *image.Paletted
+	var last animStep
+	for _, step := range steps {
+		start := (elapsed + 5) / 10
+		elapsed += step.durationMs
+		delay := (elapsed+5)/10 - start
+		if delay == 0 {
+			continue
+		}
+
+		// 长镜头拆出的连续帧画面相同，复用上一帧的量化结果
+		if paletted == nil || step.from != last.from || step.to != last.to || step.mix != last.mix {
+			img := a.frame(step)
+			paletted = image.NewPaletted(img.Bounds(), palette.Plan9)
+			draw.FloydSteinberg.Draw(paletted, img.Bounds(), img, image.Point{})
+			last = step
+		}
+		if err := enc.add(paletted, int(delay)); err != nil {
+			return err
+		}
+	}
+	return enc.close()
+}
+
+// breakText 按宽度折行：优先在空格处断开，中文等没有空格的文字按字符断开
+func breakText(text string, maxW float64, advance func(rune) float64) []string {
+	var lines []string
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		runes := []rune(strings.TrimSpace(para))
+		start, lastSpace := 0, -1
+		var w float64
+		for i := 0; i < len(runes); i++ {
+			if runes[i] == ' ' {
+				lastSpace = i
+			}
+			w += advance(runes[i])
+			if w <= maxW || i == start {
+				continue
+			}
+			end := i
+			if lastSpace > start {
+				end = lastSpace
+			}
+			lines = append(lines, strings.TrimSpace(string(runes[start:end])))
+			start, lastSpace, w = end, -1, 0
+			for start < len(runes) && runes[start] == ' ' {
+				start++
+			}
+			i = start - 1
+		}
+		if start < len(runes) {
+			lines = append(lines, string(runes[start:]))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,186 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/gif"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func frames(durations ...int64) []Frame {
+	var out []Frame
+	var start int64
+	for _, d := range durations {
+		out = append(out, Frame{StartMs: start, EndMs: start + d})
+		start += d
+	}
+	return out
+}
+
+func totalMs(steps []animStep) int64 {
+	var total int64
+	for _, s := range steps {
+		total += s.durationMs
+	}
+	return total
+}
+
+func TestTimelineHardCut(t *testing.T) {
+	steps := timeline(frames(1000, 2000), 0)
+	want := []animStep{{from: 0, to: 0, durationMs: 1000}, {from: 1, to: 1, durationMs: 2000}}
+	if len(steps) != len(want) {
+		t.Fatalf("steps = %+v, want %+v", steps, want)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Fatalf("steps = %+v, want %+v", steps, want)
+		}
+	}
+}
+
+func TestTimelineCrossfade(t *testing.T) {
+	steps := timeline(frames(2000, 2000, 2000), 400)
+	if got := totalMs(steps); got != 6000 {
+		t.Fatalf("total = %d, want 6000", got)
+	}
+
+	// 第一个镜头让出半个转场，中间的镜头两侧各让出一半
+	if steps[0] != (animStep{from: 0, to: 0, durationMs: 1800}) {
+		t.Errorf("first hold = %+v", steps[0])
+	}
+	var fades, holds []animStep
+	for _, s := range steps {
+		if s.from == s.to {
+			holds = append(holds, s)
+		} else {
+			fades = append(fades, s)
+		}
+	}
+	if len(holds) != 3 || holds[1].durationMs != 1600 || holds[2].durationMs != 1800 {
+		t.Errorf("holds = %+v", holds)
+	}
+	// 每个转场 400ms 拆成 4 帧，mix 递增
+	if len(fades) != 8 {
+		t.Fatalf("fades = %+v", fades)
+	}
+	for i, s := range fades[:4] {
+		if s.from != 0 || s.to != 1 || s.durationMs != 100 || s.mix != float64(i+1)/5 {
+			t.Errorf("fade %d = %+v", i, s)
+		}
+	}
+}
+
+func TestTimelineClampsFades(t *testing.T) {
+	// 转场不超过两侧镜头时长的一半，过短或缺失的镜头按 minSceneMs 显示
+	steps := timeline(frames(300, 5000, 0), MaxCrossfadeMs)
+	if got := totalMs(steps); got != 300+5000+minSceneMs {
+		t.Fatalf("total = %d, want %d", got, 300+5000+minSceneMs)
+	}
+	var fadeMs [2]int64
+	count := 0
+	for _, s := range steps {
+		if s.from != s.to {
+			fadeMs[s.from] += s.durationMs
+			count++
+		}
+	}
+	if fadeMs[0] != 150 || fadeMs[1] != minSceneMs/2 {
+		t.Errorf("fade durations = %v, want [150 %d]", fadeMs, minSceneMs/2)
+	}
+
+	// 长转场最多拆成 maxCrossfadeStep 帧
+	long := timeline(frames(10_000, 10_000), MaxCrossfadeMs)
+	fades := 0
+	for _, s := range long {
+		if s.from != s.to {
+			fades++
+		}
+	}
+	if fades != maxCrossfadeStep || totalMs(long) != 20_000 {
+		t.Errorf("long crossfade: %d fade steps, total %d", fades, totalMs(long))
+	}
+
+	if steps := timeline(nil, 500); len(steps) != 0 {
+		t.Errorf("empty storyboard = %+v", steps)
+	}
+}
+
+func TestTimelineSplitsLongHolds(t *testing.T) {
+	steps := timeline(frames(700_000, 1000), 0)
+	if got := totalMs(steps); got != 701_000 {
+		t.Fatalf("total = %d, want 701000", got)
+	}
+	if len(steps) != 3 || steps[0].durationMs != maxStepMs || steps[1].durationMs != 700_000-maxStepMs {
+		t.Fatalf("steps = %+v", steps)
+	}
+}
+
+// longAnimatic 渲染一个 700 秒的镜头加一个 1 秒的镜头
+func longAnimatic(t *testing.T, format string) []byte {
+	t.Helper()
+	if captionFont == nil {
+		f, err := parseFont(goregular.TTF)
+		if err != nil {
+			t.Fatal(err)
+		}
+		captionFont = f
+	}
+	var buf bytes.Buffer
+	sb := &Storyboard{Frames: frames(700_000, 1000)}
+	if err := WriteAnimatic(&buf, format, sb, AnimaticOptions{Width: MinAnimaticWidth}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriteGIFLongHold(t *testing.T) {
+	anim, err := gif.DecodeAll(bytes.NewReader(longAnimatic(t, FormatGIF)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, d := range anim.Delay {
+		total += d
+	}
+	if total != 70_100 || len(anim.Image) != 3 || anim.LoopCount != 0 {
+		t.Fatalf("delays = %v, loop = %d", anim.Delay, anim.LoopCount)
+	}
+}
+
+func TestWriteAPNGLongHold(t *testing.T) {
+	data := longAnimatic(t, FormatAPNG)
+	var frames int
+	var totalMs float64
+	for p := len(pngSignature); p+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[p:]))
+		if string(data[p+4:p+8]) == "fcTL" {
+			body := data[p+8:]
+			num, den := binary.BigEndian.Uint16(body[20:]), binary.BigEndian.Uint16(body[22:])
+			totalMs += float64(num) * 1000 / float64(den)
+			frames++
+		}
+		p += 12 + length
+	}
+	if frames != 3 || totalMs != 701_000 {
+		t.Fatalf("%d frames, %vms", frames, totalMs)
+	}
+}
+
+func TestAnimaticOptionsNormalize(t *testing.T) {
+	opts := AnimaticOptions{}
+	if err := opts.Normalize(); err != nil || opts.Width != DefaultAnimaticWidth {
+		t.Fatalf("Normalize = %+v, %v", opts, err)
+	}
+	for _, bad := range []AnimaticOptions{
+		{Width: MinAnimaticWidth - 1},
+		{Width: MaxAnimaticWidth + 1},
+		{CrossfadeMs: -1},
+		{CrossfadeMs: MaxCrossfadeMs + 1},
+	} {
+		if err := bad.Normalize(); err == nil {
+			t.Errorf("Normalize(%+v) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// apngEncoder 用标准库逐帧编码 PNG，再把 IDAT 重新封装为 APNG 的 fcTL/fdAT 帧，不需要一次持有全部帧
+type apngEncoder struct {
+	w      io.Writer
+	frames int
+	seq    uint32 // fcTL 与 fdAT 共用的序号
+	ihdr   []byte
+	err    error
+}
+
+func newAPNGEncoder(w io.Writer, frames int) *apngEncoder {
+	return &apngEncoder{w: w, frames: frames}
+}
+
+// add 写入一帧，durationMs 为该帧的显示时长
+func (e *apngEncoder) add(img image.Image, durationMs int64) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	ihdr, idats, err := pngChunks(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	first := e.ihdr == nil
+	if first {
+		e.ihdr = ihdr
+		e.write(pngSignature)
+		e.chunk("IHDR", ihdr)
+		actl := make([]byte, 8)
+		binary.BigEndian.PutUint32(actl[0:], uint32(e.frames))
+		binary.BigEndian.PutUint32(actl[4:], 0) // 无限循环
+		e.chunk("acTL", actl)
+	} else if !bytes.Equal(ihdr, e.ihdr) {
+		return errors.New("animation frames have different PNG formats")
+	}
+
+	// 延时为 16 位分数，超过 65.535 秒时改用 1/100 秒为单位
+	num, den := durationMs, int64(1000)
+	if num > 0xffff {
+		num, den = (durationMs+5)/10, 100
+	}
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], e.seq)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(img.Bounds().Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(img.Bounds().Dy()))
+	binary.BigEndian.PutUint16(fctl[20:], uint16(num))
+	binary.BigEndian.PutUint16(fctl[22:], uint16(den))
+	// dispose_op 与 blend_op 为 0：整帧覆盖，不与上一帧混合
+	e.chunk("fcTL", fctl)
+	e.seq++
+
+	for _, data := range idats {
+		if first {
+			e.chunk("IDAT", data)
+			continue
+		}
+		fdat := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(fdat, e.seq)
+		copy(fdat[4:], data)
+		e.chunk("fdAT", fdat)
+		e.seq++
+	}
+	return e.err
+}
+
+func (e *apngEncoder) close() error {
+	e.chunk("IEND", nil)
+	return e.err
+}
+
+func (e *apngEncoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+func (e *apngEncoder) chunk(name string, data []byte) {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], name)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	e.write(header)
+	e.write(data)
+	e.write(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+}
+
+// pngChunks 取出 PNG 文件中 IHDR 与全部 IDAT 块的数据
+func pngChunks(data []byte) ([]byte, [][]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, nil, errors.New("invalid PNG signature")
+	}
+	var ihdr []byte
+	var idats [][]byte
+	for p := len(pngSignature); p+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[p:]))
+		name := string(data[p+4 : p+8])
+		if p+12+length > len(data) {
+			return nil, nil, errors.New("truncated PNG chunk")
+		}
+		body := data[p+8 : p+8+length]
+		switch name {
+		case "IHDR":
+			ihdr = body
+		case "IDAT":
+			idats = append(idats, body)
+		}
+		p += 12 + length
+	}
+	if ihdr == nil || len(idats) == 0 {
+		return nil, nil, errors.New("PNG has no image data")
+	}
+	return ihdr, idats, nil
+}
@@ -0,0 +1,215 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"time"
+
+	// 注册可解码的图片格式
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/google/uuid"
+)
+
+// MaxPixels 可以解码的画面像素数上限（宽×高），由 Init 按 MAX_IMAGE_PIXELS 设置
+var MaxPixels int64 = 40 * 1000 * 1000
+
+// 支持的导出格式
+const (
+	FormatPDF = "pdf"
+	FormatPNG = "png"
+	FormatZIP = "zip"
+)
+
+// Frame 一个镜头及其画面；Data 为空表示该镜头还没有选用的画面
+type Frame struct {
+	SceneID      uuid.UUID
+	ImageID      *uuid.UUID
+	StartMs      int64
+	EndMs        int64
+	Description  string
+	VisualPrompt string
+	CameraNotes  string
+	Data         []byte
+	ContentType  string
+}
+
+// Storyboard 导出的输入，Frames 按播放顺序排列
+type Storyboard struct {
+	ProjectID uuid.UUID
+	Title     string
+	Frames    []Frame
+}
+
+// ValidFormat 判断是否为支持的导出格式
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatPDF, FormatPNG, FormatZIP, FormatGIF, FormatAPNG:
+		return true
+	}
+	return false
+}
+
+// ContentType 导出文件的 MIME 类型
+func ContentType(format string) string {
+	switch format {
+	case FormatPDF:
+		return "application/pdf"
+	case FormatPNG:
+		return "image/png"
+	case FormatGIF:
+		return "image/gif"
+	case FormatAPNG:
+		return "image/apng"
+	}
+	return "application/zip"
+}
+
+// Write 按 format 渲染分镜并写入 w；动画小样格式使用 opts
+func Write(w io.Writer, format string, sb *Storyboard, opts AnimaticOptions) error {
+	if IsAnimatic(format) {
+		return WriteAnimatic(w, format, sb, opts)
+	}
+	switch format {
+	case FormatPDF:
+		return WritePDF(w, sb)
+	case FormatPNG:
+		return WriteContactSheet(w, sb)
+	case FormatZIP:
+		return WriteZip(w, sb)
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+// Timecode 将毫秒格式化为 mm:ss.mmm，超过一小时时为 h:mm:ss.mmm
+func Timecode(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	h, m, s := ms/3600000, ms/60000%60, ms/1000%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d.%03d", h, m, s, ms%1000)
+	}
+	return fmt.Sprintf("%02d:%02d.%03d", m, s, ms%1000)
+}
+
+// decode 解码画面并铺在白底上（去掉透明通道）；没有画面、无法解码或尺寸超过 MaxPixels 时返回 nil，按缺少画面处理
+func (f *Frame) decode() *image.RGBA {
+	if len(f.Data) == 0 {
+		return nil
+	}
+	// 先读文件头中的尺寸，避免小文件声明超大尺寸时解码分配大量内存
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(f.Data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(f.Data))
+	if err != nil {
+		return nil
+	}
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// extension 画面文件的扩展名
+func extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".png"
+}
+
+type manifestScene struct {
+	Index         int        `json:"index"`
+	SceneID       uuid.UUID  `json:"scene_id"`
+	StartMs       int64      `json:"start_ms"`
+	EndMs         int64      `json:"end_ms"`
+	TimecodeStart string     `json:"timecode_start"`
+	TimecodeEnd   string     `json:"timecode_end"`
+	Description   string     `json:"description"`
+	VisualPrompt  string     `json:"visual_prompt"`
+	CameraNotes   string     `json:"camera_notes"`
+	ImageID       *uuid.UUID `json:"image_id,omitempty"`
+	// File 压缩包内的画面文件，镜头没有画面时为空
+	File string `json:"file,omitempty"`
+}
+
+type manifest struct {
+	ProjectID  uuid.UUID       `json:"project_id"`
+	Title      string          `json:"title"`
+	ExportedAt time.Time       `json:"exported_at"`
+	DurationMs int64           `json:"duration_ms"`
+	Scenes     []manifestScene `json:"scenes"`
+}
+
+// WriteZip 打包按顺序编号的原始画面文件（frames/001.png …）与描述镜头的 storyboard.json
+func WriteZip(w io.Writer, sb *Storyboard) error {
+	zw := zip.NewWriter(w)
+	m := manifest{
+		ProjectID:  sb.ProjectID,
+		Title:      sb.Title,
+		ExportedAt: time.Now().UTC(),
+		Scenes:     []manifestScene{},
+	}
+
+	for i, f := range sb.Frames {
+		item := manifestScene{
+			Index:         i + 1,
+			SceneID:       f.SceneID,
+			StartMs:       f.StartMs,
+			EndMs:         f.EndMs,
+			TimecodeStart: Timecode(f.StartMs),
+			TimecodeEnd:   Timecode(f.EndMs),
+			Description:   f.Description,
+			VisualPrompt:  f.VisualPrompt,
+			CameraNotes:   f.CameraNotes,
+			ImageID:       f.ImageID,
+		}
+		if len(f.Data) > 0 {
+			item.File = fmt.Sprintf("frames/%03d%s", i+1, extension(f.ContentType))
+			// 图片本身已压缩，直接存储
+			fw, err := zw.CreateHeader(&zip.FileHeader{Name: item.File, Method: zip.Store, Modified: m.ExportedAt})
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(f.Data); err != nil {
+				return err
+			}
+		}
+		if f.EndMs > m.DurationMs {
+			m.DurationMs = f.EndMs
+		}
+		m.Scenes = append(m.Scenes, item)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: "storyboard.json", Method: zip.Deflate, Modified: m.ExportedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
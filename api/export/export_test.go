@@ -0,0 +1,73 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestTimecode(t *testing.T) {
+	tests := []struct {
+		ms   int64
+		want string
+	}{
+		{0, "00:00.000"},
+		{-5, "00:00.000"},
+		{1500, "00:01.500"},
+		{61_001, "01:01.001"},
+		{3_600_000, "1:00:00.000"},
+		{3_723_004, "1:02:03.004"},
+	}
+	for _, tt := range tests {
+		if got := Timecode(tt.ms); got != tt.want {
+			t.Errorf("Timecode(%d) = %q, want %q", tt.ms, got, tt.want)
+		}
+	}
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFrameDecodePixelCap(t *testing.T) {
+	defer func(old int64) { MaxPixels = old }(MaxPixels)
+	MaxPixels = 100
+
+	small := &Frame{Data: encodePNG(t, 10, 10)}
+	if small.decode() == nil {
+		t.Fatal("10x10 frame was rejected")
+	}
+	large := &Frame{Data: encodePNG(t, 11, 10)}
+	if large.decode() != nil {
+		t.Fatal("11x10 frame was decoded despite the pixel cap")
+	}
+	if (&Frame{Data: []byte("not an image")}).decode() != nil {
+		t.Fatal("garbage frame was decoded")
+	}
+}
+
+func TestFrameDecodeRejectsDeclaredBomb(t *testing.T) {
+	// 只有文件头的 PNG 声明 30000x30000，不应进入完整解码
+	ihdr := []byte("IHDR\x00\x00\x75\x30\x00\x00\x75\x30\x08\x02\x00\x00\x00")
+	data := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d"), ihdr...)
+	data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width != 30000 {
+		t.Fatalf("fixture: %+v, %v", cfg, err)
+	}
+	if (&Frame{Data: data}).decode() != nil {
+		t.Fatal("oversized frame was decoded")
+	}
+}
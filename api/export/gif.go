@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"io"
+)
+
+// netscapeLoop 无限循环播放的应用扩展块
+var netscapeLoop = []byte("\x21\xff\x0bNETSCAPE2.0\x03\x01\x00\x00\x00")
+
+// gifEncoder 用标准库逐帧编码单帧 GIF，再把图像块拼接为循环播放的动画，不需要一次持有全部帧
+type gifEncoder struct {
+	w      io.Writer
+	header []byte // 文件头、逻辑屏幕描述与全局调色板，所有帧必须一致
+	err    error
+}
+
+func newGIFEncoder(w io.Writer) *gifEncoder {
+	return &gifEncoder{w: w}
+}
+
+// add 写入一帧，delay 为该帧的显示时长（1/100 秒）
+func (e *gifEncoder) add(img *image.Paletted, delay int) error {
+	if delay < 0 || delay > 0xffff {
+		return errors.New("GIF frame delay out of range")
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{img}, Delay: []int{delay}}); err != nil {
+		return err
+	}
+	header, block, err := gifBlocks(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if e.header == nil {
+		e.header = header
+		e.write(header)
+		e.write(netscapeLoop)
+	} else if !bytes.Equal(header, e.header) {
+		return errors.New("animation frames have different GIF formats")
+	}
+	e.write(block)
+	return e.err
+}
+
+func (e *gifEncoder) close() error {
+	if e.header == nil {
+		return errors.New("animation has no frames")
+	}
+	e.write([]byte{0x3b})
+	return e.err
+}
+
+func (e *gifEncoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+// gifBlocks 把单帧 GIF 拆成文件头（含全局调色板）与图像块（图形控制扩展、图像描述与图像数据）
+func gifBlocks(data []byte) ([]byte, []byte, error) {
+	if len(data) < 14 || !bytes.HasPrefix(data, []byte("GIF89a")) {
+		return nil, nil, errors.New("invalid GIF header")
+	}
+	headerLen := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		headerLen += 3 << (flags&0x07 + 1)
+	}
+	if headerLen >= len(data) || data[len(data)-1] != 0x3b {
+		return nil, nil, errors.New("truncated GIF")
+	}
+	return data[:headerLen], data[headerLen : len(data)-1], nil
+}
@@ -0,0 +1,278 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// PDF 页面布局：A4 横向，单位为点（1/72 英寸），原点在左下角
+const (
+	pageW      = 842.0
+	pageH      = 595.0
+	pageMargin = 40.0
+	frameTop   = 515.0 // 画面区域上沿
+	frameBot   = 165.0 // 画面区域下沿，其下为描述与镜头说明
+	bodySize   = 12.0
+	notesSize  = 10.0
+	lineGap    = 1.35 // 行距相对字号的倍数
+)
+
+// helveticaWidths Helvetica 中 ASCII 32–126 的字宽（千分之一字号），用于折行
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// pdfDoc 按对象编号收集 PDF 对象，编号从 1 开始
+type pdfDoc struct {
+	objects [][]byte
+}
+
+func (d *pdfDoc) reserve() int {
+	d.objects = append(d.objects, nil)
+	return len(d.objects)
+}
+
+func (d *pdfDoc) set(id int, body string) {
+	d.objects[id-1] = []byte(body)
+}
+
+func (d *pdfDoc) add(body string) int {
+	id := d.reserve()
+	d.set(id, body)
+	return id
+}
+
+func (d *pdfDoc) addStream(dict string, data []byte) int {
+	id := d.reserve()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s /Length %d >>\nstream\n", dict, len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	d.objects[id-1] = b.Bytes()
+	return id
+}
+
+func (d *pdfDoc) writeTo(w io.Writer, root, info int) error {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(d.objects))
+	for i, obj := range d.objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		b.Write(obj)
+		b.WriteString("\nendobj\n")
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(d.objects)+1, root, info, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WritePDF 每个镜头一页：画面、时间码、描述与镜头说明。
+// 英文使用内置的 Helvetica，含中文等非 ASCII 字符的段落使用阅读器自带的 STSong-Light，均无需嵌入字体
+func WritePDF(w io.Writer, sb *Storyboard) error {
+	if len(sb.Frames) == 0 {
+		return fmt.Errorf("storyboard has no scenes")
+	}
+
+	doc := &pdfDoc{}
+	catalog := doc.reserve()
+	pages := doc.reserve()
+	helvetica := doc.add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	descriptor := doc.add("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	cidFont := doc.add(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor %d 0 R /DW 1000 /W [1 95 500] >>", descriptor))
+	song := doc.add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>", cidFont))
+
+	var kids []string
+	for i := range sb.Frames {
+		f := &sb.Frames[i]
+		var content bytes.Buffer
+		xobjects := ""
+
+		// 页眉：项目名称、镜头编号与时间码
+		pdfLine(&content, sb.Title, pageMargin, pageH-pageMargin, notesSize, 0.5)
+		header := fmt.Sprintf("Scene %d / %d    %s - %s", i+1, len(sb.Frames), Timecode(f.StartMs), Timecode(f.EndMs))
+		pdfLine(&content, header, pageMargin, pageH-pageMargin-24, 16, 0)
+
+		// 画面按比例缩放到画面区域中居中；没有画面时绘制占位框
+		box := pdfRect{pageMargin, frameBot, pageW - 2*pageMargin, frameTop - frameBot}
+		if img := f.decode(); img != nil {
+			var jpg bytes.Buffer
+			if err := jpeg.Encode(&jpg, img, &jpeg.Options{Quality: 85}); err != nil {
+				return err
+			}
+			b := img.Bounds()
+			imageID := doc.addStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d "+
+				"/ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode", b.Dx(), b.Dy()), jpg.Bytes())
+			xobjects = fmt.Sprintf(" /XObject << /Im1 %d 0 R >>", imageID)
+			r := box.fit(b)
+			fmt.Fprintf(&content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im1 Do Q\n", r.w, r.h, r.x, r.y)
+		} else {
+			fmt.Fprintf(&content, "q 0.93 g %.2f %.2f %.2f %.2f re f Q\n", box.x, box.y, box.w, box.h)
+			label := "No frame"
+			pdfLine(&content, label, box.x+(box.w-textAdvance(label, 14))/2, box.y+box.h/2-5, 14, 0.6)
+		}
+
+		// 描述与镜头说明，超出页面的部分截断
+		y := frameBot - 24
+		maxW := pageW - 2*pageMargin
+		for _, line := range wrap(f.Description, bodySize, maxW) {
+			if y < pageMargin {
+				break
+			}
+			pdfLine(&content, line, pageMargin, y, bodySize, 0)
+			y -= bodySize * lineGap
+		}
+		if notes := strings.TrimSpace(f.CameraNotes); notes != "" {
+			y -= 4
+			for _, line := range wrap("Camera: "+notes, notesSize, maxW) {
+				if y < pageMargin {
+					break
+				}
+				pdfLine(&content, line, pageMargin, y, notesSize, 0.35)
+				y -= notesSize * lineGap
+			}
+		}
+
+		stream, err := deflate(content.Bytes())
+		if err != nil {
+			return err
+		}
+		contentID := doc.addStream("/Filter /FlateDecode", stream)
+		page := doc.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 %d 0 R /F2 %d 0 R >>%s >> /Contents %d 0 R >>",
+			pages, pageW, pageH, helvetica, song, xobjects, contentID))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+
+	doc.set(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	doc.set(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	info := doc.add(fmt.Sprintf("<< /Title %s /Producer (ai-design) >>", pdfUTF16(sb.Title)))
+	return doc.writeTo(w, catalog, info)
+}
+
+type pdfRect struct {
+	x, y, w, h float64
+}
+
+// fit 在 r 中居中放置保持 b 宽高比的最大矩形
+func (r pdfRect) fit(b image.Rectangle) pdfRect {
+	iw, ih := float64(b.Dx()), float64(b.Dy())
+	scale := min(r.w/iw, r.h/ih)
+	w, h := iw*scale, ih*scale
+	return pdfRect{r.x + (r.w-w)/2, r.y + (r.h-h)/2, w, h}
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// runeAdvance 单个字符的宽度（点）；ASCII 段落按 Helvetica 计算，其余按 STSong-Light 的半角/全角计算
+func runeAdvance(r rune, size float64, ascii bool) float64 {
+	if ascii {
+		return float64(helveticaWidths[r-0x20]) * size / 1000
+	}
+	if r >= 0x20 && r <= 0x7e {
+		return size / 2
+	}
+	return size
+}
+
+func textAdvance(s string, size float64) float64 {
+	ascii := isASCII(s)
+	var w float64
+	for _, r := range s {
+		w += runeAdvance(r, size, ascii)
+	}
+	return w
+}
+
+// wrap 按宽度折行，每个段落按是否全为 ASCII 选择字宽
+func wrap(text string, size, maxW float64) []string {
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		ascii := isASCII(strings.TrimSpace(strings.ReplaceAll(para, "\r", "")))
+		lines = append(lines, breakText(para, maxW, func(r rune) float64 {
+			return runeAdvance(r, size, ascii)
+		})...)
+	}
+	return lines
+}
+
+// pdfLine 在 (x, y) 处输出一行文字，gray 为灰度（0 为黑色）
+func pdfLine(b *bytes.Buffer, s string, x, y, size, gray float64) {
+	if s == "" {
+		return
+	}
+	font, text := "F1", pdfLiteral(s)
+	if !isASCII(s) {
+		font, text = "F2", pdfUCS2(s)
+	}
+	fmt.Fprintf(b, "BT %.2f g /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", gray, font, size, x, y, text)
+}
+
+// pdfLiteral 转义为 PDF 字面量字符串，仅用于 ASCII 文本
+func pdfLiteral(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+	return "(" + r.Replace(s) + ")"
+}
+
+// pdfUCS2 编码为 UniGB-UCS2-H 所需的 UCS-2 十六进制字符串，BMP 以外的字符替换为 ?
+func pdfUCS2(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		if r > 0xffff || r < 0x20 {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// pdfUTF16 文档信息中的文本字符串（带 BOM 的 UTF-16BE）
+func pdfUTF16(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
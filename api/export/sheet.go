@@ -0,0 +1,95 @@
+package export
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// 联系表布局，单位像素
+const (
+	sheetColumns = 4
+	sheetCellW   = 320
+	sheetCellH   = 180 // 画面区域为 16:9，其他比例的画面居中留白
+	sheetLabelH  = 22
+	sheetGap     = 16
+	sheetMargin  = 24
+)
+
+var (
+	sheetBackground  = color.White
+	sheetPlaceholder = color.RGBA{0xee, 0xee, 0xee, 0xff}
+	sheetText        = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	sheetMuted       = color.RGBA{0x99, 0x99, 0x99, 0xff}
+)
+
+// WriteContactSheet 以网格形式把所有镜头画面排在一张 PNG 上，每格下方标注镜头编号与时间码
+func WriteContactSheet(w io.Writer, sb *Storyboard) error {
+	n := len(sb.Frames)
+	if n == 0 {
+		return fmt.Errorf("storyboard has no scenes")
+	}
+	cols := min(n, sheetColumns)
+	rows := (n + cols - 1) / cols
+
+	width := 2*sheetMargin + cols*sheetCellW + (cols-1)*sheetGap
+	height := 2*sheetMargin + rows*(sheetCellH+sheetLabelH) + (rows-1)*sheetGap
+	sheet := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(sheetBackground), image.Point{}, draw.Src)
+
+	for i := range sb.Frames {
+		f := &sb.Frames[i]
+		x := sheetMargin + (i%cols)*(sheetCellW+sheetGap)
+		y := sheetMargin + (i/cols)*(sheetCellH+sheetLabelH+sheetGap)
+		cell := image.Rect(x, y, x+sheetCellW, y+sheetCellH)
+		draw.Draw(sheet, cell, image.NewUniform(sheetPlaceholder), image.Point{}, draw.Src)
+
+		if src := f.decode(); src != nil {
+			xdraw.CatmullRom.Scale(sheet, fitRect(src.Bounds(), cell), src, src.Bounds(), draw.Src, nil)
+		} else {
+			label := "No frame"
+			drawText(sheet, label, cell.Min.X+(sheetCellW-textWidth(label))/2, cell.Min.Y+sheetCellH/2+4, sheetMuted)
+		}
+
+		label := fmt.Sprintf("%02d  %s - %s", i+1, Timecode(f.StartMs), Timecode(f.EndMs))
+		drawText(sheet, label, x, y+sheetCellH+16, sheetText)
+	}
+
+	return png.Encode(w, sheet)
+}
+
+// fitRect 在 box 中居中放置保持 src 宽高比的最大矩形
+func fitRect(src, box image.Rectangle) image.Rectangle {
+	sw, sh := src.Dx(), src.Dy()
+	bw, bh := box.Dx(), box.Dy()
+	w, h := bw, sh*bw/sw
+	if h > bh {
+		w, h = sw*bh/sh, bh
+	}
+	x := box.Min.X + (bw-w)/2
+	y := box.Min.Y + (bh-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// drawText 以内置点阵字体绘制文字，(x, y) 为基线起点；字体只覆盖 ASCII
+func drawText(dst draw.Image, s string, x, y int, c color.Color) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+func textWidth(s string) int {
+	return font.MeasureString(basicfont.Face7x13, s).Round()
+}
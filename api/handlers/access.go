@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var roleRank = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+func validRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// roleAtLeast 判断 role 是否拥有 min 的权限
+func roleAtLeast(role, min string) bool {
+	return roleRank[role] > 0 && roleRank[role] >= roleRank[min]
+}
+
+var orgRoleRank = map[string]int{
+	models.OrgRoleMember: 1,
+	models.OrgRoleAdmin:  2,
+	models.OrgRoleOwner:  3,
+}
+
+func validOrgRole(role string) bool {
+	_, ok := orgRoleRank[role]
+	return ok
+}
+
+func orgRoleAtLeast(role, min string) bool {
+	return orgRoleRank[role] > 0 && orgRoleRank[role] >= orgRoleRank[min]
+}
+
+// projectRole 返回用户在项目中的角色，无权访问时返回空字符串。
+// 个人项目：创建者始终是 owner，其他人需要已接受的成员邀请。
+// 组织项目：必须是组织成员；组织 owner/admin 视为项目 owner，普通成员视为 editor，
+// 项目成员角色只能在此基础上提升权限。
+func projectRole(project *models.Project, userID uuid.UUID) (string, error) {
+	role := ""
+	if project.OrgID != nil {
+		orgMember, err := config.Storage.GetOrgMember(*project.OrgID, userID)
+		if err != nil {
+			return "", err
+		}
+		if orgMember == nil {
+			return "", nil
+		}
+		role = models.RoleEditor
+		if orgRoleAtLeast(orgMember.Role, models.OrgRoleAdmin) {
+			return models.RoleOwner, nil
+		}
+	}
+
+	if project.UserID == userID {
+		return models.RoleOwner, nil
+	}
+
+	member, err := config.Storage.GetProjectMember(project.ID, userID)
+	if err != nil {
+		return "", err
+	}
+	if member != nil && member.Status == "accepted" && roleRank[member.Role] > roleRank[role] {
+		role = member.Role
+	}
+	return role, nil
+}
+
+// authorizeProject 加载项目并检查当前用户至少拥有 minRole。
+// 无权访问时返回 404（不暴露项目是否存在），权限不足时返回 403；
+// 返回 ok=false 表示已经写出响应，调用方应直接返回。
+func authorizeProject(c *gin.Context, projectID uuid.UUID, minRole string) (*models.Project, string, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return nil, "", false
+	}
+
+	project, err := config.Storage.GetProjectByID(projectID)
+	if err != nil || project == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, "", false
+	}
+
+	role, err := projectRole(project, userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check project access"})
+		return nil, "", false
+	}
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, "", false
+	}
+	if !roleAtLeast(role, minRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient project permissions"})
+		return nil, "", false
+	}
+	return project, role, true
+}
+
+// authorizeImage 加载图片并通过其所属项目检查权限
+func authorizeImage(c *gin.Context, imageID uuid.UUID, minRole string) (*models.Image, *models.Project, bool) {
+	image, err := config.Storage.GetImageByID(imageID)
+	if err != nil || image == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return nil, nil, false
+	}
+
+	userID, _ := c.Get("userID")
+	project, role, err := imageRole(image, userID.(uuid.UUID))
+	if err != nil || role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return nil, nil, false
+	}
+	if !roleAtLeast(role, minRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient project permissions"})
+		return nil, nil, false
+	}
+	return image, project, true
+}
+
+// imageRole 用户对图片的角色：项目中的图片沿用项目角色，不属于项目的图片只有创建者（owner）可以访问
+func imageRole(image *models.Image, userID uuid.UUID) (*models.Project, string, error) {
+	if image.ProjectID == uuid.Nil {
+		if image.UserID == userID {
+			return nil, models.RoleOwner, nil
+		}
+		return nil, "", nil
+	}
+	project, err := config.Storage.GetProjectByID(image.ProjectID)
+	if err != nil || project == nil {
+		return nil, "", err
+	}
+	role, err := projectRole(project, userID)
+	return project, role, err
+}
+
+// accessibleProjects 返回工作区内用户可访问的项目及其角色。
+// orgID 为空时是个人工作区：用户拥有或已加入的个人项目，不包含任何组织项目。
+func accessibleProjects(userID uuid.UUID, orgID *uuid.UUID) ([]*models.Project, map[uuid.UUID]string, error) {
+	roles := make(map[uuid.UUID]string)
+
+	if orgID != nil {
+		orgProjects, err := config.Storage.GetProjectsByOrgID(*orgID)
+		if err != nil {
+			return nil, nil, err
+		}
+		var projects []*models.Project
+		for _, project := range orgProjects {
+			role, err := projectRole(project, userID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if role == "" {
+				continue
+			}
+			roles[project.ID] = role
+			projects = append(projects, project)
+		}
+		return projects, roles, nil
+	}
+
+	owned, err := config.Storage.GetProjectsByUserID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	projects := make([]*models.Project, 0, len(owned))
+	for _, project := range owned {
+		if project.OrgID != nil {
+			continue
+		}
+		roles[project.ID] = models.RoleOwner
+		projects = append(projects, project)
+	}
+
+	memberships, err := config.Storage.GetMembershipsByUserID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, member := range memberships {
+		if member.Status != "accepted" {
+			continue
+		}
+		if _, seen := roles[member.ProjectID]; seen {
+			continue
+		}
+		project, err := config.Storage.GetProjectByID(member.ProjectID)
+		if err != nil || project == nil || project.OrgID != nil {
+			continue
+		}
+		roles[project.ID] = member.Role
+		projects = append(projects, project)
+	}
+	return projects, roles, nil
+}
+
+// workspaceScope 解析请求的工作区：?workspace= 或 X-Workspace-ID 请求头。
+// 为空或 "personal" 表示个人工作区（返回 nil）；否则必须是当前用户所在组织的 ID。
+// 返回 ok=false 表示已经写出响应。
+func workspaceScope(c *gin.Context) (*uuid.UUID, *models.OrgMember, bool) {
+	workspace := c.Query("workspace")
+	if workspace == "" {
+		workspace = c.GetHeader("X-Workspace-ID")
+	}
+	if workspace == "" || workspace == "personal" {
+		return nil, nil, true
+	}
+
+	orgID, err := uuid.Parse(workspace)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace"})
+		return nil, nil, false
+	}
+
+	userID, _ := c.Get("userID")
+	member, err := config.Storage.GetOrgMember(orgID, userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check workspace access"})
+		return nil, nil, false
+	}
+	if member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, nil, false
+	}
+	return &orgID, member, true
+}
+
+// authorizeOrg 加载组织并检查当前用户至少拥有 minRole，非成员返回 404
+func authorizeOrg(c *gin.Context, orgID uuid.UUID, minRole string) (*models.Organization, *models.OrgMember, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return nil, nil, false
+	}
+
+	org, err := config.Storage.GetOrganizationByID(orgID)
+	if err != nil || org == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, nil, false
+	}
+
+	member, err := config.Storage.GetOrgMember(orgID, userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check organization access"})
+		return nil, nil, false
+	}
+	if member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, nil, false
+	}
+	if !orgRoleAtLeast(member.Role, minRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient organization permissions"})
+		return nil, nil, false
+	}
+	return org, member, true
+}
+
+// projectAPIKey 组织项目使用组织配置的上游 API Key，没有配置时返回空字符串（使用全局 key）
+func projectAPIKey(project *models.Project) string {
+	if project == nil || project.OrgID == nil {
+		return ""
+	}
+	org, err := config.Storage.GetOrganizationByID(*project.OrgID)
+	if err != nil || org == nil {
+		return ""
+	}
+	return orgAPIKey(org)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+	"ai-design-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateAccessKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CreateAccessKeyResponse 明文 key 只在创建时返回一次
+type CreateAccessKeyResponse struct {
+	models.AccessKey
+	Key string `json:"key"`
+}
+
+func GetAccessKeys(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	keys, err := config.Storage.GetAccessKeysByUserID(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch access keys"})
+		return
+	}
+	if keys == nil {
+		keys = []*models.AccessKey{}
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func CreateAccessKey(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req CreateAccessKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plaintext, hash, err := utils.NewAccessKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create access key"})
+		return
+	}
+
+	key := &models.AccessKey{
+		UserID:  userID.(uuid.UUID),
+		Name:    req.Name,
+		Prefix:  plaintext[:len(utils.AccessKeyPrefix)+6],
+		KeyHash: hash,
+	}
+	if err := config.Storage.CreateAccessKey(key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create access key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAccessKeyResponse{AccessKey: *key, Key: plaintext})
+}
+
+func DeleteAccessKey(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access key ID"})
+		return
+	}
+
+	key, err := config.Storage.GetAccessKeyByID(keyID)
+	if err != nil || key == nil || key.UserID != userID.(uuid.UUID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Access key not found"})
+		return
+	}
+
+	if err := config.Storage.DeleteAccessKey(keyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete access key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access key deleted successfully"})
+}
+
+// GetProxyLogs 当前用户最近的代理调用记录，?limit= 默认 100，最多 1000
+func GetProxyLogs(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	entries, err := config.Storage.GetProxyLogsByUserID(userID.(uuid.UUID), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch proxy logs"})
+		return
+	}
+	if entries == nil {
+		entries = []*models.ProxyLog{}
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"ai-design-backend/archive"
+	"ai-design-backend/blobstore"
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ArchiveProject 导出项目归档：项目信息、镜头、生成记录、已完成的图片（含编辑血缘）、参考素材与图片内容。
+// 归档以流的形式写出，图片内容逐个读取，不会一次载入内存
+func ArchiveProject(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	project, _, ok := authorizeProject(c, projectID, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	images, err := config.Storage.GetImagesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+	scenes, err := config.Storage.GetScenesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scenes"})
+		return
+	}
+	refs, err := config.Storage.GetReferencesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch references"})
+		return
+	}
+
+	manifest := &archive.Manifest{
+		Project: archive.Project{
+			ID:          project.ID,
+			Title:       project.Title,
+			Description: project.Description,
+			Type:        project.Type,
+			Status:      project.Status,
+			CreatedAt:   project.CreatedAt,
+		},
+		Scenes:      []archive.Scene{},
+		Generations: []archive.Generation{},
+		Images:      []archive.Image{},
+		References:  []archive.Reference{},
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="project-%s.zip"`, project.ID))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	w := archive.NewWriter(c.Writer)
+	ctx := c.Request.Context()
+
+	// 先写入图片内容；内容无法读取的图片不进入归档，引用它的镜头选图、参考素材与编辑血缘随之去掉
+	included := map[uuid.UUID]bool{}
+	var archived []*models.Image
+	blobKeys := map[uuid.UUID]string{}
+	for _, image := range images {
+		if image.Status != "completed" {
+			continue
+		}
+		data, contentType, err := loadImageContent(ctx, image)
+		if err != nil {
+			log.Printf("archive project %s: skipping image %s without content: %v", project.ID, image.ID, err)
+			continue
+		}
+		key, err := w.AddBlob(data, contentType)
+		if err != nil {
+			log.Printf("archive project %s: %v", project.ID, err)
+			c.Abort()
+			return
+		}
+		blobKeys[image.ID] = key
+		included[image.ID] = true
+		archived = append(archived, image)
+	}
+
+	sceneIDs := map[uuid.UUID]bool{}
+	for _, scene := range scenes {
+		sceneIDs[scene.ID] = true
+		item := archive.Scene{
+			ID:           scene.ID,
+			OrderIndex:   scene.OrderIndex,
+			StartMs:      scene.StartMs,
+			EndMs:        scene.EndMs,
+			Description:  scene.Description,
+			VisualPrompt: scene.VisualPrompt,
+			CameraNotes:  scene.CameraNotes,
+		}
+		if scene.SelectedImageID != nil && included[*scene.SelectedImageID] {
+			item.SelectedImageID = scene.SelectedImageID
+		}
+		manifest.Scenes = append(manifest.Scenes, item)
+	}
+
+	generations := map[uuid.UUID]bool{}
+	for _, image := range archived {
+		item := archive.Image{
+			ID:              image.ID,
+			Prompt:          image.Prompt,
+			Model:           image.Model,
+			Size:            image.Size,
+			Template:        image.Template,
+			TemplateVersion: image.TemplateVersion,
+			OutputIndex:     image.OutputIndex,
+			BlobKey:         blobKeys[image.ID],
+			GeneratedAt:     image.GeneratedAt,
+			CreatedAt:       image.CreatedAt,
+		}
+		// 源图片不在本项目（或未归档）时血缘在此中断
+		if image.ParentImageID != nil && included[*image.ParentImageID] {
+			item.ParentImageID = image.ParentImageID
+		}
+		if image.SceneID != nil && sceneIDs[*image.SceneID] {
+			item.SceneID = image.SceneID
+		}
+		if image.GenerationID != nil {
+			if !generations[*image.GenerationID] {
+				if g, err := config.Storage.GetGenerationByID(*image.GenerationID); err == nil && g != nil {
+					generations[g.ID] = true
+					manifest.Generations = append(manifest.Generations, archive.Generation{
+						ID:              g.ID,
+						Prompt:          g.Prompt,
+						Model:           g.Model,
+						Size:            g.Size,
+						N:               g.N,
+						Template:        g.Template,
+						TemplateVersion: g.TemplateVersion,
+						Variables:       g.Variables,
+						CreatedAt:       g.CreatedAt,
+					})
+				}
+			}
+			if generations[*image.GenerationID] {
+				item.GenerationID = image.GenerationID
+			}
+		}
+		manifest.Images = append(manifest.Images, item)
+	}
+
+	for _, ref := range refs {
+		if !included[ref.ImageID] {
+			continue
+		}
+		manifest.References = append(manifest.References, archive.Reference{
+			ID:          ref.ID,
+			ImageID:     ref.ImageID,
+			Kind:        ref.Kind,
+			Name:        ref.Name,
+			Description: ref.Description,
+			CreatedAt:   ref.CreatedAt,
+		})
+	}
+
+	if err := w.Close(manifest); err != nil {
+		log.Printf("archive project %s: %v", project.ID, err)
+		c.Abort()
+	}
+}
+
+// ImportProject 从归档重建项目，归属于当前用户（或 ?workspace= 指定的组织）。
+// 所有记录重新分配 ID；先校验归档结构，再逐个读取图片内容、校验 SHA-256 与图片格式后写入 blob 存储，之后才创建记录
+func ImportProject(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	orgID, _, ok := workspaceScope(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.Config.MaxArchiveSize+1<<20)
+	fh, err := c.FormFile("archive")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Archive exceeds maximum size"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An archive file is required"})
+		return
+	}
+	if fh.Size > config.Config.MaxArchiveSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Archive exceeds maximum size"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read archive"})
+		return
+	}
+	defer f.Close()
+
+	ar, err := archive.Open(f, fh.Size, config.Config.MaxImageSize)
+	if err != nil {
+		if errors.Is(err, archive.ErrInvalidArchive) || errors.Is(err, archive.ErrChecksum) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read archive"})
+		return
+	}
+	m := ar.Manifest
+
+	if len(m.References) > maxReferenceAssets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A project can have at most %d reference assets", maxReferenceAssets)})
+		return
+	}
+	for _, ref := range m.References {
+		if !validReferenceKind(ref.Kind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("reference %s has invalid kind %q", ref.ID, ref.Kind)})
+			return
+		}
+	}
+
+	if len(m.Scenes) > maxProjectScenes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": tooManyScenesMessage})
+		return
+	}
+	for _, scene := range m.Scenes {
+		if !validSceneTiming(scene.StartMs, scene.EndMs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("scene %s: %s", scene.ID, invalidSceneTimingMessage)})
+			return
+		}
+	}
+
+	// 图片内容与上传图片做同样的校验（类型、大小与像素数），不信任归档中声明的类型；
+	// blob 按内容寻址，重复导入不会产生重复文件
+	ctx := c.Request.Context()
+	blobs := map[string]blobstore.Info{}
+	for _, blob := range m.Blobs {
+		data, _, err := ar.Blob(blob.SHA256)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		contentType, err := validateImage(data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("blob %s: %v", blob.SHA256, err)})
+			return
+		}
+		info, err := blobstore.Default.Put(ctx, data, contentType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+			return
+		}
+		blobs[blob.SHA256] = info
+	}
+
+	// 预先为所有记录分配新 ID，记录之间的引用据此改写
+	ids := map[uuid.UUID]uuid.UUID{}
+	for _, g := range m.Generations {
+		ids[g.ID] = uuid.New()
+	}
+	for _, scene := range m.Scenes {
+		ids[scene.ID] = uuid.New()
+	}
+	for _, image := range m.Images {
+		ids[image.ID] = uuid.New()
+	}
+	remap := func(id *uuid.UUID) *uuid.UUID {
+		if id == nil {
+			return nil
+		}
+		mapped := ids[*id]
+		return &mapped
+	}
+
+	now := time.Now()
+	projectType := m.Project.Type
+	if projectType == "" {
+		projectType = "single"
+	}
+	status := m.Project.Status
+	if status == "" {
+		status = "active"
+	}
+	project := &models.Project{
+		ID:          uuid.New(),
+		UserID:      userID,
+		OrgID:       orgID,
+		Title:       m.Project.Title,
+		Description: m.Project.Description,
+		Type:        projectType,
+		Status:      status,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := config.Storage.CreateProject(project); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+		return
+	}
+
+	// 中途失败时删除已创建的记录；生成记录没有删除接口，残留的记录不再被任何图片引用
+	var createdImages, createdScenes, createdRefs []uuid.UUID
+	fail := func(message string) {
+		for _, id := range createdRefs {
+			config.Storage.DeleteReference(id)
+		}
+		for _, id := range createdImages {
+			config.Storage.DeleteImage(id)
+		}
+		for _, id := range createdScenes {
+			config.Storage.DeleteScene(id)
+		}
+		config.Storage.DeleteProject(project.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+
+	for _, g := range m.Generations {
+		generation := &models.Generation{
+			ID:              ids[g.ID],
+			UserID:          userID,
+			ProjectID:       project.ID,
+			Prompt:          g.Prompt,
+			Model:           g.Model,
+			Size:            g.Size,
+			N:               g.N,
+			Template:        g.Template,
+			TemplateVersion: g.TemplateVersion,
+			Variables:       g.Variables,
+			CreatedAt:       g.CreatedAt,
+		}
+		if err := config.Storage.CreateGeneration(generation); err != nil {
+			fail("Failed to create generation")
+			return
+		}
+	}
+
+	for _, s := range m.Scenes {
+		scene := &models.Scene{
+			ID:              ids[s.ID],
+			ProjectID:       project.ID,
+			OrderIndex:      s.OrderIndex,
+			StartMs:         s.StartMs,
+			EndMs:           s.EndMs,
+			Description:     s.Description,
+			VisualPrompt:    s.VisualPrompt,
+			CameraNotes:     s.CameraNotes,
+			SelectedImageID: remap(s.SelectedImageID),
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		if err := config.Storage.CreateScene(scene); err != nil {
+			fail("Failed to create scene")
+			return
+		}
+		createdScenes = append(createdScenes, scene.ID)
+	}
+
+	for _, img := range m.Images {
+		image := &models.Image{
+			ID:              ids[img.ID],
+			ProjectID:       project.ID,
+			UserID:          userID,
+			ParentImageID:   remap(img.ParentImageID),
+			GenerationID:    remap(img.GenerationID),
+			SceneID:         remap(img.SceneID),
+			Prompt:          img.Prompt,
+			Model:           img.Model,
+			Size:            img.Size,
+			Status:          "completed",
+			Template:        img.Template,
+			TemplateVersion: img.TemplateVersion,
+			OutputIndex:     img.OutputIndex,
+			GeneratedAt:     img.GeneratedAt,
+			CreatedAt:       img.CreatedAt,
+			UpdatedAt:       now,
+		}
+		// verify 已保证每张图片的 blob 都在归档中
+		info := blobs[img.BlobKey]
+		image.BlobKey = info.Key
+		image.ContentType = info.ContentType
+		image.FileSize = info.Size
+		if err := config.Storage.CreateImage(image); err != nil {
+			fail("Failed to create image")
+			return
+		}
+		createdImages = append(createdImages, image.ID)
+	}
+
+	for _, r := range m.References {
+		ref := &models.ReferenceAsset{
+			ProjectID:   project.ID,
+			ImageID:     ids[r.ImageID],
+			Kind:        r.Kind,
+			Name:        r.Name,
+			Description: r.Description,
+			CreatedBy:   userID,
+			CreatedAt:   r.CreatedAt,
+			UpdatedAt:   now,
+		}
+		if err := config.Storage.CreateReference(ref); err != nil {
+			fail("Failed to create reference")
+			return
+		}
+		createdRefs = append(createdRefs, ref.ID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"project":     project,
+		"scenes":      len(m.Scenes),
+		"images":      len(m.Images),
+		"generations": len(m.Generations),
+		"references":  len(m.References),
+		"blobs":       len(m.Blobs),
+	})
+}
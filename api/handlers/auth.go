@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"time"
 
 	"ai-design-backend/config"
 	"ai-design-backend/models"
@@ -18,12 +20,83 @@ type AuthRequest struct {
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Username string `json:"username" binding:"required,min=3,max=50"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required,min=6,max=72"`
 }
 
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token"`
+	ExpiresIn    int          `json:"expires_in"` // access token 有效期（秒）
+	User         *models.User `json:"user"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// issueTokens 在指定会话内签发一对 access/refresh token，refreshID 为新 refresh token 的记录ID
+func issueTokens(user *models.User, sessionID, refreshID uuid.UUID) (*AuthResponse, error) {
+	refreshToken, hash, err := utils.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.RefreshToken{
+		ID:        refreshID,
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(config.Config.RefreshTokenTTL),
+		CreatedAt: time.Now(),
+	}
+	if err := config.Storage.CreateRefreshToken(record); err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateJWT(user.ID, user.Email, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(config.Config.AccessTokenTTL.Seconds()),
+		User:         user,
+	}, nil
+}
+
+// startSession 新建登录会话并签发令牌
+func startSession(user *models.User) (*AuthResponse, error) {
+	session := &models.AuthSession{UserID: user.ID}
+	if err := config.Storage.CreateSession(session); err != nil {
+		return nil, err
+	}
+	return issueTokens(user, session.ID, uuid.New())
+}
+
+// revokeSession 吊销整个会话，其下所有令牌随之失效
+func revokeSession(sessionID uuid.UUID) error {
+	session, err := config.Storage.GetSessionByID(sessionID)
+	if err != nil || session == nil {
+		return err
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	revoked := *session
+	revoked.RevokedAt = &now
+	return config.Storage.UpdateSession(&revoked)
+}
+
+// usernameTaken 用户名是否已被 self 以外的用户使用
+func usernameTaken(username string, self uuid.UUID) (bool, error) {
+	existing, err := config.Storage.GetUserByUsername(username)
+	if err != nil {
+		return false, err
+	}
+	return existing != nil && existing.ID != self, nil
 }
 
 func Register(c *gin.Context) {
@@ -43,12 +116,27 @@ func Register(c *gin.Context) {
 		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		return
 	}
+	if taken, err := usernameTaken(req.Username, uuid.Nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
 
 	// 创建新用户
 	user := &models.User{
 		Email:    req.Email,
 		Username: req.Username,
-		Password: req.Password, // 这里应该加密密码
+		Password: hashedPassword,
+		IsActive: true,
+		Role:     models.UserRoleUser,
 	}
 
 	if err := config.Storage.CreateUser(user); err != nil {
@@ -57,16 +145,13 @@ func Register(c *gin.Context) {
 	}
 
 	// 生成JWT token
-	token, err := utils.GenerateJWT(user.ID, user.Email)
+	response, err := startSession(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 func Login(c *gin.Context) {
@@ -89,17 +174,119 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// 旧的明文或低 cost 密码在登录成功后透明升级
+	if utils.NeedsRehash(user.Password) {
+		if hashed, err := utils.HashPassword(req.Password); err == nil {
+			user.Password = hashed
+			if err := config.Storage.UpdateUser(user); err != nil {
+				log.Printf("Failed to upgrade password hash for user %s: %v", user.ID, err)
+			}
+		} else {
+			log.Printf("Failed to upgrade password hash for user %s: %v", user.ID, err)
+		}
+	}
+
 	// 生成JWT token
-	token, err := utils.GenerateJWT(user.ID, user.Email)
+	response, err := startSession(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RefreshToken 轮换 refresh token：旧令牌作废并在同一会话内签发新令牌。
+// 已使用过的令牌再次出现视为泄露，整个会话被吊销。
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, err := config.Storage.GetRefreshTokenByHash(utils.HashToken(req.RefreshToken))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if record == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	if record.UsedAt != nil {
+		refreshTokenReused(c, record)
+		return
+	}
+	if time.Now().After(record.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
+		return
+	}
+
+	session, err := config.Storage.GetSessionByID(record.SessionID)
+	if err != nil || session == nil || session.RevokedAt != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked"})
+		return
+	}
+
+	user, err := config.Storage.GetUserByID(record.UserID)
+	if err != nil || user == nil || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	// 原子地作废旧令牌；并发请求中只有一个能成功，另一个按重放处理
+	refreshID := uuid.New()
+	ok, err := config.Storage.MarkRefreshTokenUsed(record.ID, time.Now(), refreshID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !ok {
+		refreshTokenReused(c, record)
+		return
+	}
+
+	response, err := issueTokens(user, record.SessionID, refreshID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusOK, response)
+}
+
+func refreshTokenReused(c *gin.Context, record *models.RefreshToken) {
+	log.Printf("Refresh token reuse detected for user %s, revoking session %s", record.UserID, record.SessionID)
+	if err := revokeSession(record.SessionID); err != nil {
+		log.Printf("Failed to revoke session %s: %v", record.SessionID, err)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please log in again"})
+}
+
+// Logout 注销当前 access token 并吊销其所属会话
+func Logout(c *gin.Context) {
+	if jti := c.GetString("tokenID"); jti != "" {
+		expiresAt, _ := c.Get("tokenExpiresAt")
+		revoked := &models.RevokedToken{JTI: jti, ExpiresAt: time.Now().Add(config.Config.AccessTokenTTL)}
+		if t, ok := expiresAt.(time.Time); ok {
+			revoked.ExpiresAt = t
+		}
+		if err := config.Storage.RevokeAccessToken(revoked); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+			return
+		}
+	}
+
+	if sessionID, ok := c.Get("sessionID"); ok && sessionID.(uuid.UUID) != uuid.Nil {
+		if err := revokeSession(sessionID.(uuid.UUID)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
 func GetUserProfile(c *gin.Context) {
@@ -142,7 +329,16 @@ func UpdateUserProfile(c *gin.Context) {
 	}
 
 	// 更新用户信息
-	if req.Username != "" {
+	if req.Username != "" && req.Username != user.Username {
+		taken, err := usernameTaken(req.Username, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+			return
+		}
 		user.Username = req.Username
 	}
 	if req.Avatar != "" {
@@ -0,0 +1,91 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ai-design-backend/config"
+	"ai-design-backend/ratelimit"
+	"ai-design-backend/routes"
+	"ai-design-backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter 使用内存存储与完整的路由配置
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	t.Setenv("BCRYPT_COST", "4")
+	config.InitConfig()
+	config.Storage = storage.GetMemoryStorage()
+	ratelimit.Default = ratelimit.NewMemoryBackend()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	routes.SetupRoutes(r)
+	return r
+}
+
+func do(t *testing.T, r *gin.Engine, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestRegisterRefreshLogout(t *testing.T) {
+	r := newTestRouter(t)
+
+	code, resp := do(t, r, "POST", "/api/v1/auth/register", "", map[string]string{
+		"email": "refresh@example.com", "username": "refresher", "password": "secret123",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("register: %d %v", code, resp)
+	}
+	if user, _ := resp["user"].(map[string]interface{}); user["is_active"] != true {
+		t.Errorf("registered user is not active: %v", user)
+	}
+	refreshToken, _ := resp["refresh_token"].(string)
+
+	// 刷新得到新的令牌对，旧的 refresh token 随即作废
+	code, resp = do(t, r, "POST", "/api/v1/auth/refresh", "", map[string]string{"refresh_token": refreshToken})
+	if code != http.StatusOK {
+		t.Fatalf("refresh: %d %v", code, resp)
+	}
+	accessToken, _ := resp["token"].(string)
+	newRefresh, _ := resp["refresh_token"].(string)
+	if accessToken == "" || newRefresh == "" || newRefresh == refreshToken {
+		t.Fatalf("refresh returned %v", resp)
+	}
+	if code, _ := do(t, r, "GET", "/api/v1/user/profile", accessToken, nil); code != http.StatusOK {
+		t.Fatalf("profile with refreshed token: %d", code)
+	}
+
+	// 登出后 access token 与整个会话的 refresh token 都失效
+	if code, resp := do(t, r, "POST", "/api/v1/auth/logout", accessToken, nil); code != http.StatusOK {
+		t.Fatalf("logout: %d %v", code, resp)
+	}
+	if code, _ := do(t, r, "GET", "/api/v1/user/profile", accessToken, nil); code != http.StatusUnauthorized {
+		t.Errorf("profile after logout: %d, want 401", code)
+	}
+	if code, _ := do(t, r, "POST", "/api/v1/auth/refresh", "", map[string]string{"refresh_token": newRefresh}); code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: %d, want 401", code)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"ai-design-backend/blobstore"
+	"ai-design-backend/models"
+	"ai-design-backend/providers"
+)
+
+// storeOutputBlob 拉取或解码上游输出并保存到 blob 存储，相同内容只保存一份
+func storeOutputBlob(ctx context.Context, out providers.Output) (blobstore.Info, error) {
+	src := out.URL
+	if out.B64JSON != "" {
+		src = out.B64JSON
+	}
+
+	data, contentType, err := providers.LoadImageSource(ctx, src)
+	if err != nil {
+		return blobstore.Info{}, err
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return blobstore.Info{}, fmt.Errorf("upstream returned %s instead of an image", contentType)
+	}
+	return blobstore.Default.Put(ctx, data, contentType)
+}
+
+// storeOutput 保存输出并写入图片记录。上游给出 URL 时即使保存失败也不算生成失败，
+// 图片仍可通过上游链接访问；Base64 输出保存失败则返回错误。
+func storeOutput(ctx context.Context, image *models.Image, out providers.Output) error {
+	image.ImageURL = out.URL
+
+	info, err := storeOutputBlob(ctx, out)
+	if err != nil {
+		if out.URL != "" {
+			log.Printf("image %s: failed to store output, keeping upstream URL: %v", image.ID, err)
+			return nil
+		}
+		return err
+	}
+
+	image.BlobKey = info.Key
+	image.ContentType = info.ContentType
+	image.FileSize = info.Size
+	return nil
+}
+
+// imageURL 返回接口响应中图片的地址：内容已保存到 blob 存储时为本服务的下载地址，
+// 否则为上游链接。Base64 输出没有上游链接，只能通过下载地址访问
+func imageURL(image *models.Image) string {
+	if image.BlobKey != "" {
+		return "/api/v1/images/" + image.ID.String() + "/download"
+	}
+	return image.ImageURL
+}
+
+// loadImageContent 读取已保存图片的内容，优先使用 blob 存储，旧记录回退到图片数据或上游链接
+func loadImageContent(ctx context.Context, image *models.Image) ([]byte, string, error) {
+	if image.BlobKey != "" {
+		data, info, err := blobstore.ReadAll(ctx, blobstore.Default, image.BlobKey)
+		if err == nil {
+			return data, info.ContentType, nil
+		}
+		if !errors.Is(err, blobstore.ErrNotFound) {
+			return nil, "", err
+		}
+	}
+	src := image.ImageData
+	if src == "" {
+		src = image.ImageURL
+	}
+	return providers.LoadImageSource(ctx, src)
+}
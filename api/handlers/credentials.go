@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+	"ai-design-backend/providers"
+	"ai-design-backend/vault"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateAPIKeyRequest struct {
+	Provider string `json:"provider" binding:"required"`
+	APIKey   string `json:"api_key" binding:"required"`
+	Label    string `json:"label"`
+}
+
+type UpdateAPIKeyRequest struct {
+	APIKey string  `json:"api_key"`
+	Label  *string `json:"label"`
+}
+
+// maskKey 只保留前 3 位和后 4 位
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:3] + "****" + key[len(key)-4:]
+}
+
+// sealCredential 加密 API Key 写入记录，以用户 ID 作为附加认证数据
+func sealCredential(cred *models.ProviderCredential, apiKey string) error {
+	sealed, err := vault.Default.Seal([]byte(apiKey), cred.UserID[:])
+	if err != nil {
+		return err
+	}
+	cred.KeyID = sealed.KeyID
+	cred.WrappedKey = sealed.WrappedKey
+	cred.Ciphertext = sealed.Ciphertext
+	cred.MaskedKey = maskKey(apiKey)
+	return nil
+}
+
+// userAPIKey 解密用户为该 provider 保存的 key；没有保存或 vault 未启用时返回空字符串。
+// 由旧主密钥加密的记录会顺便用当前主密钥重新加密。
+func userAPIKey(userID uuid.UUID, provider string) string {
+	if !vault.Enabled() {
+		return ""
+	}
+	cred, err := config.Storage.GetCredential(userID, provider)
+	if err != nil || cred == nil {
+		return ""
+	}
+
+	sealed := vault.Sealed{KeyID: cred.KeyID, WrappedKey: cred.WrappedKey, Ciphertext: cred.Ciphertext}
+	plaintext, err := vault.Default.Open(sealed, cred.UserID[:])
+	if err != nil {
+		log.Printf("vault: failed to open credential %s: %v", cred.ID, err)
+		return ""
+	}
+	if vault.Default.NeedsRewrap(sealed) {
+		if err := sealCredential(cred, string(plaintext)); err == nil {
+			config.Storage.UpdateCredential(cred)
+		}
+	}
+	return string(plaintext)
+}
+
+// resolveAPIKey 决定调用上游时使用的 key 及其来源：组织项目优先使用组织的 key，
+// 其次是用户自己保存的 key；key 为空表示使用全局配置的 key
+func resolveAPIKey(userID uuid.UUID, project *models.Project, model string) (string, string) {
+	if key := projectAPIKey(project); key != "" {
+		return key, models.KeySourceOrg
+	}
+	provider, err := providers.Default.ForModel(model)
+	if err != nil {
+		return "", models.KeySourceServer
+	}
+	if key := userAPIKey(userID, provider.Name()); key != "" {
+		return key, models.KeySourceUser
+	}
+	return "", models.KeySourceServer
+}
+
+func requireVault(c *gin.Context) bool {
+	if !vault.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Key vault is not configured"})
+		return false
+	}
+	return true
+}
+
+func GetAPIKeys(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	creds, err := config.Storage.GetCredentialsByUserID(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+	if creds == nil {
+		creds = []*models.ProviderCredential{}
+	}
+
+	c.JSON(http.StatusOK, creds)
+}
+
+func CreateAPIKey(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !requireVault(c) {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Provider = strings.ToLower(strings.TrimSpace(req.Provider))
+	req.APIKey = strings.TrimSpace(req.APIKey)
+	if providers.Default.Get(req.Provider) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown provider"})
+		return
+	}
+
+	existing, err := config.Storage.GetCredential(userID.(uuid.UUID), req.Provider)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save API key"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "API key for this provider already exists"})
+		return
+	}
+
+	cred := &models.ProviderCredential{
+		UserID:   userID.(uuid.UUID),
+		Provider: req.Provider,
+		Label:    req.Label,
+	}
+	if err := sealCredential(cred, req.APIKey); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt API key"})
+		return
+	}
+	if err := config.Storage.CreateCredential(cred); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, cred)
+}
+
+func UpdateAPIKey(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	credID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	var req UpdateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cred, err := config.Storage.GetCredentialByID(credID)
+	if err != nil || cred == nil || cred.UserID != userID.(uuid.UUID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if req.Label != nil {
+		cred.Label = *req.Label
+	}
+	if apiKey := strings.TrimSpace(req.APIKey); apiKey != "" {
+		if !requireVault(c) {
+			return
+		}
+		if err := sealCredential(cred, apiKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt API key"})
+			return
+		}
+	}
+
+	if err := config.Storage.UpdateCredential(cred); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cred)
+}
+
+func DeleteAPIKey(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	credID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	cred, err := config.Storage.GetCredentialByID(credID)
+	if err != nil || cred == nil || cred.UserID != userID.(uuid.UUID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if err := config.Storage.DeleteCredential(credID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ai-design-backend/config"
+	"ai-design-backend/export"
+	"ai-design-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportQuery width、crossfade_ms 与 captions 只用于 gif/apng 动画小样
+type ExportQuery struct {
+	Format      string `form:"format"`
+	Width       int    `form:"width"`
+	CrossfadeMs int64  `form:"crossfade_ms"`
+	Captions    bool   `form:"captions"`
+}
+
+// ExportStoryboard 将分镜导出为 PDF（每镜一页）、PNG 联系表、ZIP（编号画面与 storyboard.json）
+// 或按镜头时长播放的 GIF/APNG 动画小样
+func ExportStoryboard(c *gin.Context) {
+	project, ok := storyboardProject(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	var query ExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := query.Format
+	if format == "" {
+		format = export.FormatPDF
+	}
+	if !export.ValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of pdf, png, zip, gif, apng"})
+		return
+	}
+	opts := export.AnimaticOptions{Width: query.Width, CrossfadeMs: query.CrossfadeMs, Captions: query.Captions}
+	if err := opts.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scenes, err := config.Storage.GetScenesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scenes"})
+		return
+	}
+	if len(scenes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project has no scenes to export"})
+		return
+	}
+
+	sb := &export.Storyboard{ProjectID: project.ID, Title: project.Title}
+	for _, scene := range scenes {
+		frame := export.Frame{
+			SceneID:      scene.ID,
+			StartMs:      scene.StartMs,
+			EndMs:        scene.EndMs,
+			Description:  scene.Description,
+			VisualPrompt: scene.VisualPrompt,
+			CameraNotes:  scene.CameraNotes,
+		}
+		if scene.SelectedImageID != nil {
+			image, err := config.Storage.GetImageByID(*scene.SelectedImageID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+				return
+			}
+			// 画面读取失败时按缺少画面导出，不影响其他镜头
+			if image != nil {
+				frame.ImageID = &image.ID
+				frame.Data, frame.ContentType, err = loadImageContent(c.Request.Context(), image)
+				if err != nil {
+					log.Printf("export project %s: failed to load image %s: %v", project.ID, image.ID, err)
+					frame.Data = nil
+				}
+			}
+		}
+		sb.Frames = append(sb.Frames, frame)
+	}
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, format, sb, opts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render export"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="storyboard-%s.%s"`, project.ID, format))
+	c.Data(http.StatusOK, export.ContentType(format), buf.Bytes())
+}
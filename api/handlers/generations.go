@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type GenerationResponse struct {
+	models.Generation
+	Status string         `json:"status"` // 对应任务的状态：queued, running, completed, partial, failed
+	Images []models.Image `json:"images"`
+}
+
+// GetGeneration 返回一次生成的参数以及它产生的全部图片（按输出顺序）。
+// 项目中的生成需要项目 viewer 权限，个人生成只有本人可见。
+func GetGeneration(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	generationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid generation ID"})
+		return
+	}
+
+	generation, err := config.Storage.GetGenerationByID(generationID)
+	if err != nil || generation == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Generation not found"})
+		return
+	}
+	if generation.ProjectID != uuid.Nil {
+		if _, _, ok := authorizeProject(c, generation.ProjectID, models.RoleViewer); !ok {
+			return
+		}
+	} else if generation.UserID != userID.(uuid.UUID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Generation not found"})
+		return
+	}
+
+	response := GenerationResponse{Generation: *generation, Images: []models.Image{}}
+	if generation.JobID != nil {
+		if job, err := config.Storage.GetJobByID(*generation.JobID); err == nil && job != nil {
+			response.Status = job.Status
+		}
+	}
+
+	images, err := config.Storage.GetImagesByGenerationID(generation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+	for _, image := range images {
+		response.Images = append(response.Images, *image)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -3,13 +3,32 @@ package handlers
 import (
 	"net/http"
 
+	"ai-design-backend/upstream"
+	"ai-design-backend/utils"
+
 	"github.com/gin-gonic/gin"
 )
 
+// HealthCheck 服务本身可用即返回 200；有 provider 处于熔断状态时 status 为 degraded
 func HealthCheck(c *gin.Context) {
+	status := "ok"
+	upstreams := upstream.Default.Status()
+	for _, s := range upstreams {
+		if s.State != upstream.StateClosed {
+			status = "degraded"
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
+		"status": status,
 		"message": "AI Design Backend is running",
 		"timestamp": gin.Mode(),
+		"upstreams": upstreams,
 	})
-}
\ No newline at end of file
+}
+
+// JWKS 公布 access token 的校验公钥，供其他服务无需共享密钥即可验证令牌
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": utils.JWKS()})
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
+	"ai-design-backend/blobstore"
 	"ai-design-backend/config"
 	"ai-design-backend/models"
+	"ai-design-backend/providers"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -26,6 +30,11 @@ func GetImages(c *gin.Context) {
 			return
 		}
 		
+		// 检查当前用户能否查看该项目
+		if _, _, ok := authorizeProject(c, projectUUID, models.RoleViewer); !ok {
+			return
+		}
+
 		// 获取项目的图片
 		projectImages, err := config.Storage.GetImagesByProjectID(projectUUID)
 		if err != nil {
@@ -33,19 +42,17 @@ func GetImages(c *gin.Context) {
 			return
 		}
 		
-		// 检查项目是否属于当前用户
-		project, err := config.Storage.GetProjectByID(projectUUID)
-		if err != nil || project == nil || project.UserID != userID.(uuid.UUID) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-			return
-		}
-		
 		for _, img := range projectImages {
 			images = append(images, *img)
 		}
 	} else {
-		// 获取用户的所有图片
-		projects, err := config.Storage.GetProjectsByUserID(userID.(uuid.UUID))
+		orgID, _, ok := workspaceScope(c)
+		if !ok {
+			return
+		}
+
+		// 获取当前工作区内用户可访问项目中的所有图片
+		projects, _, err := accessibleProjects(userID.(uuid.UUID), orgID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
 			return
@@ -66,28 +73,14 @@ func GetImages(c *gin.Context) {
 }
 
 func GetImage(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return
-	}
-
 	imageID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
 		return
 	}
 
-	image, err := config.Storage.GetImageByID(imageID)
-	if err != nil || image == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
-		return
-	}
-	
-	// 检查图片是否属于用户（通过项目）
-	project, err := config.Storage.GetProjectByID(image.ProjectID)
-	if err != nil || project == nil || project.UserID != userID.(uuid.UUID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+	image, _, ok := authorizeImage(c, imageID, models.RoleViewer)
+	if !ok {
 		return
 	}
 
@@ -95,29 +88,13 @@ func GetImage(c *gin.Context) {
 }
 
 func DeleteImage(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return
-	}
-
 	imageID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
 		return
 	}
 
-	// 检查图片是否属于用户
-	image, err := config.Storage.GetImageByID(imageID)
-	if err != nil || image == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
-		return
-	}
-	
-	// 检查图片是否属于用户（通过项目）
-	project, err := config.Storage.GetProjectByID(image.ProjectID)
-	if err != nil || project == nil || project.UserID != userID.(uuid.UUID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+	if _, _, ok := authorizeImage(c, imageID, models.RoleEditor); !ok {
 		return
 	}
 
@@ -126,42 +103,71 @@ func DeleteImage(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
 		return
 	}
+	// 选用该图片的镜头恢复为缺少画面，引用它的参考素材一并移除
+	config.Storage.ClearSceneImage(imageID)
+	config.Storage.DeleteReferencesByImageID(imageID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
 }
 
 func DownloadImage(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return
-	}
-
 	imageID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
 		return
 	}
 
-	image, err := config.Storage.GetImageByID(imageID)
-	if err != nil || image == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+	image, _, ok := authorizeImage(c, imageID, models.RoleViewer)
+	if !ok {
 		return
 	}
-	
-	// 检查图片是否属于用户（通过项目）
-	project, err := config.Storage.GetProjectByID(image.ProjectID)
-	if err != nil || project == nil || project.UserID != userID.(uuid.UUID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
-		return
+
+	// 优先从自有 blob 存储返回，上游链接可能已过期
+	if image.BlobKey != "" {
+		rc, info, err := blobstore.Default.Open(c.Request.Context(), image.BlobKey)
+		if err == nil {
+			defer rc.Close()
+			contentType := image.ContentType
+			if contentType == "" {
+				contentType = info.ContentType
+			}
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s"`, image.ID, extensionFor(contentType)))
+			c.Header("ETag", `"`+image.BlobKey+`"`)
+			c.DataFromReader(http.StatusOK, info.Size, contentType, rc, nil)
+			return
+		}
+		if !errors.Is(err, blobstore.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image data"})
+			return
+		}
 	}
 
 	// 返回图片数据
 	if image.ImageData != "" {
-		c.Data(http.StatusOK, "image/png", []byte(image.ImageData))
+		data, contentType, err := providers.LoadImageSource(c.Request.Context(), image.ImageData)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image data not found"})
+			return
+		}
+		c.Data(http.StatusOK, contentType, data)
 	} else if image.ImageURL != "" {
 		c.Redirect(http.StatusTemporaryRedirect, image.ImageURL)
 	} else {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Image data not found"})
 	}
-}
\ No newline at end of file
+}
+
+// extensionFor 根据图片类型给下载文件补上扩展名
+func extensionFor(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	}
+	return ""
+}
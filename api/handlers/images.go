@@ -1,50 +1,48 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
+	"context"
+	"errors"
+	"log"
+	"mime/multipart"
 	"net/http"
+	"strings"
 	"time"
 
 	"ai-design-backend/config"
+	"ai-design-backend/jobs"
 	"ai-design-backend/models"
+	"ai-design-backend/providers"
+	"ai-design-backend/usage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type GenerateImageRequest struct {
-	Prompt    string `json:"prompt" binding:"required"`
+	Prompt    string `json:"prompt"` // 不使用模板时必填
 	Model     string `json:"model"`
 	Size      string `json:"size"`
 	N         int    `json:"n"`
 	ProjectID string `json:"project_id"`
-	Template  string `json:"template"`
+	// Template 为模板 ID 或名称，Variables 为模板变量，渲染结果作为实际的提示词
+	Template  string            `json:"template"`
+	Variables map[string]string `json:"variables"`
+	// Async 默认为 true：立即返回 202 及任务ID，通过 /jobs/:id 查询进度；显式传 false 时同步等待结果
+	Async *bool `json:"async"`
 }
 
 type GenerateImageResponse struct {
-	Success bool     `json:"success"`
-	Images  []string `json:"images"`
-	Message string   `json:"message"`
-}
-
-type QiniuImageResponse struct {
-	Images []struct {
-		URL     string `json:"url"`
-		B64JSON string `json:"b64_json"`
-	} `json:"images"`
-	Data []struct {
-		URL     string `json:"url"`
-		B64JSON string `json:"b64_json"`
-	} `json:"data"`
-	Created int    `json:"created"`
-	Model   string `json:"model"`
+	Success      bool        `json:"success"`
+	Images       []string    `json:"images"`
+	Message      string      `json:"message"`
+	JobID        *uuid.UUID  `json:"job_id,omitempty"`
+	GenerationID *uuid.UUID  `json:"generation_id,omitempty"`
+	ImageIDs     []uuid.UUID `json:"image_ids,omitempty"`
 }
 
 func GenerateImage(c *gin.Context) {
-	_, exists := c.Get("userID")
+	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
@@ -58,7 +56,7 @@ func GenerateImage(c *gin.Context) {
 
 	// 设置默认值
 	if req.Model == "" {
-		req.Model = "gemini-2.5-flash-image"
+		req.Model = config.Config.DefaultImageModel
 	}
 	if req.Size == "" {
 		req.Size = "1024x1024"
@@ -67,61 +65,191 @@ func GenerateImage(c *gin.Context) {
 		req.N = 1
 	}
 
-	// 创建图片记录
-	image := &models.Image{
+	// 使用模板时由模板渲染出实际发送给上游的提示词
+	prompt := req.Prompt
+	var template *models.PromptTemplate
+	if req.Template != "" {
+		var ok bool
+		if template, prompt, ok = renderPrompt(c, userID.(uuid.UUID), req.Template, req.Prompt, req.Variables); !ok {
+			return
+		}
+	} else if strings.TrimSpace(prompt) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prompt is required"})
+		return
+	}
+
+	jobID := uuid.New()
+	job := &models.Job{
+		ID:     jobID,
+		UserID: userID.(uuid.UUID),
+		Type:   "generate",
+	}
+
+	// 记录本次生成的参数，返回的每张图片都关联到它
+	generation := &models.Generation{
 		ID:        uuid.New(),
+		UserID:    userID.(uuid.UUID),
+		JobID:     &jobID,
 		Prompt:    req.Prompt,
 		Model:     req.Model,
 		Size:      req.Size,
-		Status:    "pending",
+		N:         req.N,
+		Variables: req.Variables,
 		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	}
+
+	// 创建第一张图片的记录，其余输出在生成完成后补充
+	image := &models.Image{
+		ID:           uuid.New(),
+		UserID:       userID.(uuid.UUID),
+		GenerationID: &generation.ID,
+		Prompt:       prompt,
+		Model:        req.Model,
+		Size:         req.Size,
+		Status:       "pending",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	if template != nil {
+		generation.Template = template.Name
+		generation.TemplateVersion = template.Version
+		image.Template = template.Name
+		image.TemplateVersion = template.Version
 	}
 
 	// 如果有项目ID，关联到项目
+	var project *models.Project
 	if req.ProjectID != "" {
 		projectID, err := uuid.Parse(req.ProjectID)
 		if err == nil {
+			// 向项目中生成图片需要 editor 权限
+			var ok bool
+			if project, _, ok = authorizeProject(c, projectID, models.RoleEditor); !ok {
+				return
+			}
 			image.ProjectID = projectID
+			job.ProjectID = projectID
+			generation.ProjectID = projectID
 		}
 	}
+	if _, ok := modelProvider(c, req.Model); !ok {
+		return
+	}
+	apiKey, keySource := resolveAPIKey(userID.(uuid.UUID), project, req.Model)
 
-	// 保存到存储
-	if err := config.Storage.CreateImage(image); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create image record"})
+	// 提交前检查月度配额与额度
+	call := usageCall(userID.(uuid.UUID), project, "generate", req.Model, req.Size, req.N, keySource)
+	if err := usage.Check(call); err != nil {
+		usageError(c, err)
 		return
 	}
 
-	// 调用七牛云API生成图片
-	images, err := callQiniuImageAPI(req.Prompt, req.Model, req.Size, req.N)
-	if err != nil {
-		// 更新状态为失败
-		image.Status = "failed"
-		image.Error = err.Error()
-		config.Storage.UpdateImage(image)
+	// 只有一个任务，siblingIDs 在 Wait 返回后读取
+	var siblingIDs []uuid.UUID
+	run := func(ctx context.Context, image *models.Image) error {
+		// 调用模型对应的 provider 生成图片
+		ctx = providers.WithAPIKey(ctx, apiKey)
+		outputs, err := meteredGenerate(ctx, call, image.Prompt)
+		if err != nil {
+			return err
+		}
+		if len(outputs) == 0 {
+			return errors.New("no image returned")
+		}
+		siblingIDs = storeSiblingOutputs(ctx, image, outputs[1:])
+		return storeOutput(ctx, image, outputs[0])
+	}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate image"})
+	if err := config.Storage.CreateGeneration(generation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create generation"})
+		return
+	}
+	if !submitJob(c, job, []*models.Image{image}, run, asyncRequested(req.Async)) {
 		return
 	}
 
-	// 更新图片记录
-	if len(images) > 0 {
-		image.ImageURL = images[0]
-		image.Status = "completed"
-		now := time.Now()
-		image.GeneratedAt = &now
-		config.Storage.UpdateImage(image)
+	if job.Status != "completed" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate image", "job_id": job.ID})
+		return
 	}
 
+	imageIDs := append([]uuid.UUID{image.ID}, siblingIDs...)
 	c.JSON(http.StatusOK, GenerateImageResponse{
-		Success: true,
-		Images:  images,
-		Message: "Image generated successfully",
+		Success:      true,
+		Images:       storedImageURLs(imageIDs),
+		Message:      "Image generated successfully",
+		JobID:        &job.ID,
+		GenerationID: &generation.ID,
+		ImageIDs:     imageIDs,
 	})
 }
 
+// storedImageURLs 按顺序返回已完成图片的地址（见 imageURL），未完成或不存在的位置为空字符串
+func storedImageURLs(ids []uuid.UUID) []string {
+	urls := make([]string, len(ids))
+	for i, id := range ids {
+		image, err := config.Storage.GetImageByID(id)
+		if err != nil || image == nil || image.Status != "completed" {
+			continue
+		}
+		urls[i] = imageURL(image)
+	}
+	return urls
+}
+
+// modelProvider 返回负责该模型的 provider；没有可用的 provider 时返回 400 与注册表的错误信息
+func modelProvider(c *gin.Context, model string) (providers.ImageProvider, bool) {
+	provider, err := providers.Default.ForModel(model)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return provider, true
+}
+
+// storeSiblingOutputs 为同一次生成的其余输出各写入一条已完成的图片记录，并返回保存成功的图片 ID。
+// 队列只跟踪第一张图片，这些记录同样关联到任务，可以通过 /jobs/:id 与 /generations/:id 查到，
+// 并各自发布一条图片事件。
+func storeSiblingOutputs(ctx context.Context, first *models.Image, outputs []providers.Output) []uuid.UUID {
+	var ids []uuid.UUID
+	for i, out := range outputs {
+		now := time.Now()
+		image := &models.Image{
+			ID:              uuid.New(),
+			ProjectID:       first.ProjectID,
+			UserID:          first.UserID,
+			GenerationID:    first.GenerationID,
+			Template:        first.Template,
+			TemplateVersion: first.TemplateVersion,
+			OutputIndex:     i + 1,
+			JobID:           first.JobID,
+			Prompt:          first.Prompt,
+			Model:           first.Model,
+			Size:            first.Size,
+			Status:          "completed",
+			GeneratedAt:     &now,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		if err := storeOutput(ctx, image, out); err != nil {
+			image.Status = "failed"
+			image.Error = err.Error()
+			image.GeneratedAt = nil
+		}
+		if err := config.Storage.CreateImage(image); err != nil {
+			log.Printf("generation %s: failed to save output %d: %v", first.GenerationID, i+1, err)
+			continue
+		}
+		ids = append(ids, image.ID)
+		if first.JobID != nil {
+			jobs.Default.PublishImage(*first.JobID, image)
+		}
+	}
+	return ids
+}
+
 func GenerateBatchImages(c *gin.Context) {
-	_, exists := c.Get("userID")
+	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
@@ -132,26 +260,72 @@ func GenerateBatchImages(c *gin.Context) {
 		Model     string   `json:"model"`
 		Size      string   `json:"size"`
 		ProjectID string   `json:"project_id"`
+		// Async 默认为 true：立即返回 202 及任务ID；显式传 false 时同步等待结果
+		Async *bool `json:"async"`
+		// 每个提示词都作为模板的 {{.prompt}} 渲染
+		Template  string            `json:"template"`
+		Variables map[string]string `json:"variables"`
+		// UseReferences 为 false 时不附带项目的参考素材，默认附带
+		UseReferences *bool `json:"use_references"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if len(req.Prompts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prompts must not be empty"})
+		return
+	}
 
 	// 设置默认值
 	if req.Model == "" {
-		req.Model = "gemini-2.5-flash-image"
+		req.Model = config.Config.DefaultImageModel
 	}
 	if req.Size == "" {
 		req.Size = "1024x1024"
 	}
 
-	var images []string
-	for i, prompt := range req.Prompts {
+	job := &models.Job{
+		UserID: userID.(uuid.UUID),
+		Type:   "batch",
+	}
+	var project *models.Project
+	if req.ProjectID != "" {
+		if projectID, err := uuid.Parse(req.ProjectID); err == nil {
+			var ok bool
+			if project, _, ok = authorizeProject(c, projectID, models.RoleEditor); !ok {
+				return
+			}
+			job.ProjectID = projectID
+		}
+	}
+	if _, ok := modelProvider(c, req.Model); !ok {
+		return
+	}
+	apiKey, keySource := resolveAPIKey(userID.(uuid.UUID), project, req.Model)
+
+	// 每张图片单独计量，提交前按整批检查配额与额度
+	call := usageCall(userID.(uuid.UUID), project, "batch", req.Model, req.Size, 1, keySource)
+	batchCall := call
+	batchCall.N = len(req.Prompts)
+	if err := usage.Check(batchCall); err != nil {
+		usageError(c, err)
+		return
+	}
+
+	refs, ok := projectReferences(c, project, req.UseReferences)
+	if !ok {
+		return
+	}
+
+	var records []*models.Image
+	for _, prompt := range req.Prompts {
 		// 创建图片记录
 		image := &models.Image{
 			ID:        uuid.New(),
+			UserID:    userID.(uuid.UUID),
+			ProjectID: job.ProjectID,
 			Prompt:    prompt,
 			Model:     req.Model,
 			Size:      req.Size,
@@ -159,195 +333,337 @@ func GenerateBatchImages(c *gin.Context) {
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
-
-		// 关联到项目
-		if req.ProjectID != "" {
-			projectID, err := uuid.Parse(req.ProjectID)
-			if err == nil {
-				image.ProjectID = projectID
+		if req.Template != "" {
+			template, rendered, ok := renderPrompt(c, userID.(uuid.UUID), req.Template, prompt, req.Variables)
+			if !ok {
+				return
 			}
+			image.Prompt = rendered
+			image.Template = template.Name
+			image.TemplateVersion = template.Version
 		}
+		records = append(records, image)
+	}
 
-		// 保存到存储
-		config.Storage.CreateImage(image)
-
-		// 调用API生成图片
-		generatedImages, err := callQiniuImageAPI(prompt, req.Model, req.Size, 1)
+	// 并发度由 worker 池控制，不再逐张 sleep
+	run := func(ctx context.Context, image *models.Image) error {
+		ctx = providers.WithAPIKey(ctx, apiKey)
+		outputs, err := meteredReferenceGenerate(ctx, call, image.Prompt, refs)
 		if err != nil {
-			image.Status = "failed"
-			image.Error = err.Error()
-			config.Storage.UpdateImage(image)
-			images = append(images, "") // 添加空字符串表示失败
-			continue
+			return err
 		}
-
-		// 更新图片记录
-		if len(generatedImages) > 0 {
-			image.ImageURL = generatedImages[0]
-			image.Status = "completed"
-			now := time.Now()
-			image.GeneratedAt = &now
-			config.Storage.UpdateImage(image)
-			images = append(images, generatedImages[0])
-		} else {
-			images = append(images, "")
+		if len(outputs) == 0 {
+			return errors.New("no image returned")
 		}
+		return storeOutput(ctx, image, outputs[0])
+	}
 
-		// 添加延迟以避免API限流
-		if i < len(req.Prompts)-1 {
-			time.Sleep(1 * time.Second)
-		}
+	if !submitJob(c, job, records, run, asyncRequested(req.Async)) {
+		return
+	}
+
+	// 按提示词顺序返回，失败的位置为空字符串
+	var ids []uuid.UUID
+	for _, record := range records {
+		ids = append(ids, record.ID)
 	}
 
 	c.JSON(http.StatusOK, GenerateImageResponse{
 		Success: true,
-		Images:  images,
+		Images:  storedImageURLs(ids),
 		Message: "Batch images generated successfully",
+		JobID:   &job.ID,
 	})
 }
 
+// asyncRequested 所有生成接口默认异步，请求中显式传 "async": false 时才同步等待结果
+func asyncRequested(async *bool) bool {
+	return async == nil || *async
+}
+
+// submitJob 提交任务；async 时直接返回 202，否则等待任务结束并刷新 job。
+// 返回 false 表示已经写出响应，调用方应直接返回。
+func submitJob(c *gin.Context, job *models.Job, images []*models.Image, run jobs.RunFunc, async bool) bool {
+	if err := jobs.Default.Submit(job, images, run); err != nil {
+		if errors.Is(err, jobs.ErrQueueFull) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many pending jobs, please retry later"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
+		return false
+	}
+
+	if async {
+		c.JSON(http.StatusAccepted, buildJobResponse(job))
+		return false
+	}
+
+	if err := jobs.Default.Wait(c.Request.Context(), job.ID); err != nil {
+		// 客户端已断开，任务仍在后台继续
+		return false
+	}
+	if latest, err := config.Storage.GetJobByID(job.ID); err == nil && latest != nil {
+		*job = *latest
+	}
+	return true
+}
+
+// EditImageRequest 源图片三选一：image（URL、data URL 或 Base64）、source_image_id（已保存的图片）、
+// 或 multipart 上传的 image 文件；mask 同样可以是字符串或上传的文件
+type EditImageRequest struct {
+	Image         string `json:"image" form:"-"`
+	SourceImageID string `json:"source_image_id" form:"source_image_id"`
+	Prompt        string `json:"prompt" form:"prompt" binding:"required"`
+	Model         string `json:"model" form:"model"`
+	Size          string `json:"size" form:"size"`
+	Mask          string `json:"mask" form:"-"`
+	ProjectID     string `json:"project_id" form:"project_id"`
+}
+
+// resolveEditInputs 解析并校验编辑的源图片与蒙版，统一转换为 provider 接受的字符串；
+// 源是已保存的图片时同时返回该图片用于记录血缘。返回 ok=false 表示已经写出错误响应。
+func resolveEditInputs(c *gin.Context, userID uuid.UUID, req *EditImageRequest) (string, string, *models.Image, bool) {
+	ctx := c.Request.Context()
+	source, mask := req.Image, req.Mask
+
+	var upload, maskUpload *multipart.FileHeader
+	if c.Request.MultipartForm != nil {
+		upload, _ = c.FormFile("image")
+		maskUpload, _ = c.FormFile("mask")
+	}
+
+	inputs := 0
+	for _, set := range []bool{source != "", req.SourceImageID != "", upload != nil} {
+		if set {
+			inputs++
+		}
+	}
+	if inputs != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of image, source_image_id or an uploaded image file is required"})
+		return "", "", nil, false
+	}
+
+	var parent *models.Image
+	// sourceData 为直接传入或上传的图片内容，远程 URL 不会被拉取
+	var sourceData []byte
+	switch {
+	case upload != nil:
+		data, contentType, err := readImageUpload(upload)
+		if err != nil {
+			if !imageInputError(c, err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded image"})
+			}
+			return "", "", nil, false
+		}
+		source = dataURL(contentType, data)
+		sourceData = data
+
+	case req.SourceImageID != "":
+		imageID, err := uuid.Parse(req.SourceImageID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source image ID"})
+			return "", "", nil, false
+		}
+		image, _, ok := authorizeImage(c, imageID, models.RoleViewer)
+		if !ok {
+			return "", "", nil, false
+		}
+		data, contentType, err := loadImageContent(ctx, image)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image data not found"})
+			return "", "", nil, false
+		}
+		source = dataURL(contentType, data)
+		parent = image
+
+	case !isRemoteImage(source):
+		data, _, err := decodeInlineImage(source)
+		if err != nil {
+			imageInputError(c, err)
+			return "", "", nil, false
+		}
+		sourceData = data
+	}
+
+	switch {
+	case maskUpload != nil:
+		data, contentType, err := readImageUpload(maskUpload)
+		if err != nil {
+			if !imageInputError(c, err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded mask"})
+			}
+			return "", "", nil, false
+		}
+		mask = dataURL(contentType, data)
+	case mask != "" && !isRemoteImage(mask):
+		if _, _, err := decodeInlineImage(mask); err != nil {
+			imageInputError(c, err)
+			return "", "", nil, false
+		}
+	}
+
+	// 直接传入或上传的图片与某张已保存的图片内容相同时同样记录血缘；远程 URL 不记录
+	if parent == nil && sourceData != nil {
+		parent = findSourceImage(userID, sourceData)
+	}
+	return source, mask, parent, true
+}
+
 func EditImage(c *gin.Context) {
-	_, exists := c.Get("userID")
+	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
 
-	var req struct {
-		Image     string `json:"image" binding:"required"`
-		Prompt    string `json:"prompt" binding:"required"`
-		Model     string `json:"model"`
-		Size      string `json:"size"`
-		Mask      string `json:"mask"`
-		ProjectID string `json:"project_id"`
+	// multipart 上传时 image、mask 为文件，其余字段为表单字段
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 2*config.Config.MaxImageSize+1<<20)
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	var req EditImageRequest
+	if err := c.ShouldBind(&req); err != nil {
+		if imageInputError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// 设置默认值
 	if req.Model == "" {
-		req.Model = "gemini-2.5-flash-image"
+		req.Model = config.Config.DefaultImageModel
 	}
 	if req.Size == "" {
 		req.Size = "1024x1024"
 	}
 
-	// 调用七牛云图生图API
-	payload := map[string]interface{}{
-		"model":  req.Model,
-		"image":  req.Image,
-		"prompt": req.Prompt,
-		"size":   req.Size,
-		"n":      1,
+	var project *models.Project
+	if req.ProjectID != "" {
+		if projectID, err := uuid.Parse(req.ProjectID); err == nil {
+			var ok bool
+			if project, _, ok = authorizeProject(c, projectID, models.RoleEditor); !ok {
+				return
+			}
+		}
 	}
+	// 使用组织或用户自己的上游 key，没有时回退到全局 key
+	apiKey, keySource := resolveAPIKey(userID.(uuid.UUID), project, req.Model)
+	ctx := providers.WithAPIKey(c.Request.Context(), apiKey)
 
-	if req.Mask != "" {
-		payload["mask"] = req.Mask
+	// 调用模型对应 provider 的图生图接口
+	provider, ok := modelProvider(c, req.Model)
+	if !ok {
+		return
 	}
 
-	jsonData, _ := json.Marshal(payload)
-
-	apiReq, err := http.NewRequest("POST", config.Config.QiniuBaseURL+"/images/edits", bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+	source, mask, parent, ok := resolveEditInputs(c, userID.(uuid.UUID), &req)
+	if !ok {
 		return
 	}
 
-	apiReq.Header.Set("Content-Type", "application/json")
-	apiReq.Header.Set("Authorization", "Bearer "+config.Config.QiniuAPIKey)
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(apiReq)
+	charge, err := usage.Begin(usageCall(userID.(uuid.UUID), project, "edit", req.Model, req.Size, 1, keySource))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to call image API"})
+		usageError(c, err)
 		return
 	}
-	defer resp.Body.Close()
-
-	var result QiniuImageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse response"})
+	outputs, err := provider.Edit(ctx, providers.EditRequest{
+		Model:  req.Model,
+		Prompt: req.Prompt,
+		Size:   req.Size,
+		N:      1,
+		Image:  source,
+		Mask:   mask,
+	})
+	charge.Finish(len(outputs), err)
+	if err != nil {
+		if upstreamUnavailable(c, err) || forbiddenImageURL(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to call image API"})
 		return
 	}
-
+	// 编辑结果与生成结果一样保存为图片记录，上游链接会过期，内容同样保存到 blob 存储；
+	// 记录保存失败的输出直接返回上游链接或 data URL
 	var images []string
-	src := result.Images
-	if len(src) == 0 {
-		src = result.Data
-	}
-	for _, img := range src {
-		if img.URL != "" {
-			images = append(images, img.URL)
-		} else if img.B64JSON != "" {
-			// 处理Base64图片数据
-			dataURL := fmt.Sprintf("data:image/png;base64,%s", img.B64JSON)
-			images = append(images, dataURL)
+	var imageIDs []uuid.UUID
+	for i, out := range outputs {
+		now := time.Now()
+		image := &models.Image{
+			ID:          uuid.New(),
+			UserID:      userID.(uuid.UUID),
+			OutputIndex: i,
+			Prompt:      req.Prompt,
+			Model:       req.Model,
+			Size:        req.Size,
+			Status:      "completed",
+			GeneratedAt: &now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if project != nil {
+			image.ProjectID = project.ID
+		}
+		if parent != nil {
+			image.ParentImageID = &parent.ID
+		}
+		if err := storeOutput(ctx, image, out); err != nil {
+			image.Status = "failed"
+			image.Error = err.Error()
+			image.GeneratedAt = nil
+		}
+		if err := config.Storage.CreateImage(image); err != nil {
+			log.Printf("edit: failed to save output %d: %v", i, err)
+			images = append(images, out.String())
+			continue
+		}
+		imageIDs = append(imageIDs, image.ID)
+		if image.Status == "completed" {
+			images = append(images, imageURL(image))
+		} else {
+			images = append(images, out.String())
 		}
 	}
 
 	c.JSON(http.StatusOK, GenerateImageResponse{
-		Success: true,
-		Images:  images,
-		Message: "Image edited successfully",
+		Success:  true,
+		Images:   images,
+		Message:  "Image edited successfully",
+		ImageIDs: imageIDs,
 	})
 }
 
-func callQiniuImageAPI(prompt, model, size string, n int) ([]string, error) {
-	payload := map[string]interface{}{
-		"model":           model,
-		"prompt":          prompt,
-		"size":            size,
-		"n":               n,
-		"response_format": "url",
-	}
-
-	jsonData, err := json.Marshal(payload)
+// generateOutputs 通过模型对应的 provider 生成图片
+func generateOutputs(ctx context.Context, prompt, model, size string, n int) ([]providers.Output, error) {
+	provider, err := providers.Default.ForModel(model)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", config.Config.QiniuBaseURL+"/images/generations", bytes.NewBuffer(jsonData))
+	outputs, err := provider.Generate(ctx, providers.GenerateRequest{
+		Model:  model,
+		Prompt: prompt,
+		Size:   size,
+		N:      n,
+	})
 	if err != nil {
 		return nil, err
 	}
+	return outputs, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+config.Config.QiniuAPIKey)
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+// referenceOutputs 通过 provider 的图生图接口，以参考图为输入生成图片
+func referenceOutputs(ctx context.Context, prompt, model, size string, n int, images []string) ([]providers.Output, error) {
+	provider, err := providers.Default.ForModel(model)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(body))
-	}
-
-	var result QiniuImageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	var images []string
-	src := result.Images
-	if len(src) == 0 {
-		src = result.Data
-	}
-	for _, img := range src {
-		if img.URL != "" {
-			images = append(images, img.URL)
-		} else if img.B64JSON != "" {
-			// 处理Base64图片数据
-			dataURL := fmt.Sprintf("data:image/png;base64,%s", img.B64JSON)
-			images = append(images, dataURL)
-		}
-	}
 
-	return images, nil
+	return provider.Edit(ctx, providers.EditRequest{
+		Model:  model,
+		Prompt: prompt,
+		Size:   size,
+		N:      n,
+		Images: images,
+	})
 }
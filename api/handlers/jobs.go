@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/jobs"
+	"ai-design-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type JobResponse struct {
+	models.Job
+	// JobID 与 id 相同，便于异步提交的调用方与同步响应统一读取
+	JobID    uuid.UUID      `json:"job_id"`
+	Progress float64        `json:"progress"`
+	Images   []models.Image `json:"images"`
+}
+
+func buildJobResponse(job *models.Job) JobResponse {
+	response := JobResponse{Job: *job, JobID: job.ID, Images: []models.Image{}}
+	if job.Total > 0 {
+		response.Progress = float64(job.Completed+job.Failed) / float64(job.Total)
+	}
+
+	images, _ := config.Storage.GetImagesByJobID(job.ID)
+	for _, img := range images {
+		response.Images = append(response.Images, *img)
+	}
+	return response
+}
+
+func GetJob(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := config.Storage.GetJobByID(jobID)
+	if err != nil || job == nil || job.UserID != userID.(uuid.UUID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildJobResponse(job))
+}
+
+// sseHeartbeat 空闲时发送注释行，防止代理断开长连接
+const sseHeartbeat = 15 * time.Second
+
+// StreamJobEvents 通过 Server-Sent Events 推送任务进度：
+// 首先发送一次完整快照（snapshot），之后每张图片状态变化推送 image 事件，
+// 进度变化推送 job 事件，结束时推送 done 事件并关闭连接。
+func StreamJobEvents(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := config.Storage.GetJobByID(jobID)
+	if err != nil || job == nil || job.UserID != userID.(uuid.UUID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	// 先订阅再读取快照，避免错过两者之间发生的变化
+	events, cancel := jobs.Default.Subscribe(jobID)
+	defer cancel()
+
+	if latest, err := config.Storage.GetJobByID(jobID); err == nil && latest != nil {
+		job = latest
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	c.SSEvent("snapshot", buildJobResponse(job))
+	c.Writer.Flush()
+
+	if job.FinishedAt != nil || !jobs.Default.Running(jobID) {
+		c.SSEvent(jobs.EventDone, jobs.Event{Type: jobs.EventDone, Job: job})
+		c.Writer.Flush()
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+			if event.Type == jobs.EventDone {
+				return
+			}
+		case <-heartbeat.C:
+			c.Writer.WriteString(": ping\n\n")
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
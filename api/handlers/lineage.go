@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ai-design-backend/blobstore"
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxLineageDepth 防止异常数据（环）导致无限遍历
+const maxLineageDepth = 100
+
+// findSourceImage 按内容哈希查找编辑源对应的已保存图片，只返回用户有权查看的最新一条；
+// 源不是已保存的图片时返回 nil。只接受调用方已经持有的图片内容，不会为此拉取 URL
+func findSourceImage(userID uuid.UUID, data []byte) *models.Image {
+	candidates, err := config.Storage.GetImagesByBlobKey(blobstore.KeyOf(data))
+	if err != nil {
+		return nil
+	}
+	for _, image := range candidates {
+		if canViewImage(image, userID) {
+			return image
+		}
+	}
+	return nil
+}
+
+func canViewImage(image *models.Image, userID uuid.UUID) bool {
+	_, role, err := imageRole(image, userID)
+	return err == nil && role != ""
+}
+
+// LineageNode 后代树中的一个节点
+type LineageNode struct {
+	models.Image
+	Children []*LineageNode `json:"children"`
+}
+
+type LineageResponse struct {
+	Image models.Image `json:"image"`
+	// Ancestors 从最初的原图到直接父图片；遇到无权查看的祖先时截断，并将 Truncated 置为 true
+	Ancestors   []models.Image `json:"ancestors"`
+	Truncated   bool           `json:"truncated"`
+	Descendants []*LineageNode `json:"descendants"`
+}
+
+// GetImageLineage 返回图片的编辑血缘：向上追溯到原图，向下展开所有编辑结果。
+// 其他项目中无权查看的图片不会出现在结果中。
+func GetImageLineage(c *gin.Context) {
+	imageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	image, _, ok := authorizeImage(c, imageID, models.RoleViewer)
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	response := LineageResponse{Image: *image, Ancestors: []models.Image{}}
+	seen := map[uuid.UUID]bool{image.ID: true}
+
+	// 向上追溯
+	var ancestors []models.Image
+	for current := image; current.ParentImageID != nil && len(ancestors) < maxLineageDepth; {
+		parent, err := config.Storage.GetImageByID(*current.ParentImageID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lineage"})
+			return
+		}
+		if parent == nil || seen[parent.ID] || !canViewImage(parent, userID) {
+			response.Truncated = parent != nil
+			break
+		}
+		seen[parent.ID] = true
+		ancestors = append(ancestors, *parent)
+		current = parent
+	}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		response.Ancestors = append(response.Ancestors, ancestors[i])
+	}
+
+	// 向下展开
+	descendants, err := lineageChildren(image.ID, userID, seen, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lineage"})
+		return
+	}
+	response.Descendants = descendants
+
+	c.JSON(http.StatusOK, response)
+}
+
+func lineageChildren(parentID, userID uuid.UUID, seen map[uuid.UUID]bool, depth int) ([]*LineageNode, error) {
+	nodes := []*LineageNode{}
+	if depth >= maxLineageDepth {
+		return nodes, nil
+	}
+
+	children, err := config.Storage.GetImagesByParentID(parentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if seen[child.ID] || !canViewImage(child, userID) {
+			continue
+		}
+		seen[child.ID] = true
+		grandchildren, err := lineageChildren(child.ID, userID, seen, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &LineageNode{Image: *child, Children: grandchildren})
+	}
+	return nodes, nil
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// MemberResponse 成员信息，附带用户的邮箱与用户名
+type MemberResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InvitationResponse 待接受的项目邀请
+type InvitationResponse struct {
+	models.ProjectMember
+	ProjectTitle string `json:"project_title"`
+}
+
+func memberResponse(member *models.ProjectMember) MemberResponse {
+	resp := MemberResponse{
+		UserID:    member.UserID,
+		Role:      member.Role,
+		Status:    member.Status,
+		CreatedAt: member.CreatedAt,
+	}
+	if user, err := config.Storage.GetUserByID(member.UserID); err == nil && user != nil {
+		resp.Email = user.Email
+		resp.Username = user.Username
+	}
+	return resp
+}
+
+func GetProjectMembers(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	project, _, ok := authorizeProject(c, projectID, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	members, err := config.Storage.GetProjectMembers(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	// 项目创建者不在成员表中，始终作为 owner 列在第一位
+	response := []MemberResponse{memberResponse(&models.ProjectMember{
+		UserID:    project.UserID,
+		Role:      models.RoleOwner,
+		Status:    "accepted",
+		CreatedAt: project.CreatedAt,
+	})}
+	for _, member := range members {
+		response = append(response, memberResponse(member))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func InviteProjectMember(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var req InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	project, _, ok := authorizeProject(c, projectID, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	invitee, err := config.Storage.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil || invitee == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	// 组织项目只能邀请同一组织的成员，保证组织之间的隔离
+	if project.OrgID != nil {
+		orgMember, err := config.Storage.GetOrgMember(*project.OrgID, invitee.ID)
+		if err != nil || orgMember == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User is not a member of the organization"})
+			return
+		}
+	}
+	if invitee.ID == project.UserID {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	}
+
+	existing, err := config.Storage.GetProjectMember(projectID, invitee.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite member"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	}
+
+	member := &models.ProjectMember{
+		ProjectID: projectID,
+		UserID:    invitee.ID,
+		Role:      req.Role,
+		Status:    "pending",
+		InvitedBy: userID.(uuid.UUID),
+	}
+	if err := config.Storage.CreateProjectMember(member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite member"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, memberResponse(member))
+}
+
+func AcceptProjectInvitation(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	member, err := config.Storage.GetProjectMember(projectID, userID.(uuid.UUID))
+	if err != nil || member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	if member.Status != "accepted" {
+		member.Status = "accepted"
+		if err := config.Storage.UpdateProjectMember(member); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, memberResponse(member))
+}
+
+func UpdateProjectMember(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	memberUserID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	project, _, ok := authorizeProject(c, projectID, models.RoleOwner)
+	if !ok {
+		return
+	}
+	if memberUserID == project.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change the role of the project creator"})
+		return
+	}
+
+	member, err := config.Storage.GetProjectMember(projectID, memberUserID)
+	if err != nil || member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	member.Role = req.Role
+	if err := config.Storage.UpdateProjectMember(member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, memberResponse(member))
+}
+
+// RemoveProjectMember owner 可以移除任意成员；成员也可以移除自己（退出项目或拒绝邀请）
+func RemoveProjectMember(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	memberUserID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	project, err := config.Storage.GetProjectByID(projectID)
+	if err != nil || project == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if memberUserID == project.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot remove the project creator"})
+		return
+	}
+
+	if memberUserID != userID.(uuid.UUID) {
+		if _, _, ok := authorizeProject(c, projectID, models.RoleOwner); !ok {
+			return
+		}
+	}
+
+	member, err := config.Storage.GetProjectMember(projectID, memberUserID)
+	if err != nil || member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if err := config.Storage.DeleteProjectMember(member.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// GetInvitations 当前用户待接受的项目邀请
+func GetInvitations(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	memberships, err := config.Storage.GetMembershipsByUserID(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	response := []InvitationResponse{}
+	for _, member := range memberships {
+		if member.Status != "pending" {
+			continue
+		}
+		project, err := config.Storage.GetProjectByID(member.ProjectID)
+		if err != nil || project == nil {
+			continue
+		}
+		response = append(response, InvitationResponse{
+			ProjectMember: *member,
+			ProjectTitle:  project.Title,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
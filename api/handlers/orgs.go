@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+	"ai-design-backend/vault"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type OrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+	// 为 nil 时不修改；空字符串表示清除组织 key，改用全局 key
+	UpstreamAPIKey *string `json:"upstream_api_key"`
+}
+
+type OrgMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type OrganizationResponse struct {
+	models.Organization
+	Role           string `json:"role"`             // 当前用户在组织中的角色
+	HasUpstreamKey bool   `json:"has_upstream_key"` // key 本身不会返回
+}
+
+type OrgMemberResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func organizationResponse(org *models.Organization, role string) OrganizationResponse {
+	return OrganizationResponse{
+		Organization:   *org,
+		Role:           role,
+		HasUpstreamKey: org.UpstreamCiphertext != "",
+	}
+}
+
+// sealOrgKey 加密组织的上游 key，以组织 ID 作为附加认证数据；apiKey 为空时清除
+func sealOrgKey(org *models.Organization, apiKey string) error {
+	org.UpstreamKeyID, org.UpstreamWrappedKey, org.UpstreamCiphertext = "", "", ""
+	if apiKey == "" {
+		return nil
+	}
+	sealed, err := vault.Default.Seal([]byte(apiKey), org.ID[:])
+	if err != nil {
+		return err
+	}
+	org.UpstreamKeyID = sealed.KeyID
+	org.UpstreamWrappedKey = sealed.WrappedKey
+	org.UpstreamCiphertext = sealed.Ciphertext
+	return nil
+}
+
+// setOrgAPIKey 按请求设置或清除组织 key；设置 key 需要启用 vault。返回 false 表示已经写出响应
+func setOrgAPIKey(c *gin.Context, org *models.Organization, apiKey *string) bool {
+	if apiKey == nil {
+		return true
+	}
+	key := strings.TrimSpace(*apiKey)
+	if key != "" && !requireVault(c) {
+		return false
+	}
+	if err := sealOrgKey(org, key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt API key"})
+		return false
+	}
+	return true
+}
+
+// orgAPIKey 解密组织的上游 key，没有配置、未启用 vault 或无法解密时返回空字符串。
+// 旧主密钥加密的 key 会顺便用当前主密钥重新加密。
+func orgAPIKey(org *models.Organization) string {
+	if org.UpstreamCiphertext == "" || !vault.Enabled() {
+		return ""
+	}
+
+	sealed := vault.Sealed{KeyID: org.UpstreamKeyID, WrappedKey: org.UpstreamWrappedKey, Ciphertext: org.UpstreamCiphertext}
+	plaintext, err := vault.Default.Open(sealed, org.ID[:])
+	if err != nil {
+		log.Printf("vault: failed to open upstream key of organization %s: %v", org.ID, err)
+		return ""
+	}
+	if vault.Default.NeedsRewrap(sealed) {
+		if err := sealOrgKey(org, string(plaintext)); err == nil {
+			config.Storage.UpdateOrganization(org)
+		}
+	}
+	return string(plaintext)
+}
+
+func orgMemberResponse(member *models.OrgMember) OrgMemberResponse {
+	resp := OrgMemberResponse{
+		UserID:    member.UserID,
+		Role:      member.Role,
+		CreatedAt: member.CreatedAt,
+	}
+	if user, err := config.Storage.GetUserByID(member.UserID); err == nil && user != nil {
+		resp.Email = user.Email
+		resp.Username = user.Username
+	}
+	return resp
+}
+
+// countOrgOwners 统计组织 owner 数量，保证组织至少保留一个 owner
+func countOrgOwners(orgID uuid.UUID) (int, error) {
+	members, err := config.Storage.GetOrgMembers(orgID)
+	if err != nil {
+		return 0, err
+	}
+	owners := 0
+	for _, member := range members {
+		if member.Role == models.OrgRoleOwner {
+			owners++
+		}
+	}
+	return owners, nil
+}
+
+func GetOrganizations(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	memberships, err := config.Storage.GetOrgMembershipsByUserID(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		return
+	}
+
+	response := []OrganizationResponse{}
+	for _, member := range memberships {
+		org, err := config.Storage.GetOrganizationByID(member.OrgID)
+		if err != nil || org == nil {
+			continue
+		}
+		response = append(response, organizationResponse(org, member.Role))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func CreateOrganization(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 预先分配 ID，作为加密 key 的附加认证数据
+	org := &models.Organization{
+		ID:        uuid.New(),
+		Name:      strings.TrimSpace(req.Name),
+		CreatedBy: userID.(uuid.UUID),
+	}
+	if !setOrgAPIKey(c, org, req.UpstreamAPIKey) {
+		return
+	}
+	if err := config.Storage.CreateOrganization(org); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+
+	// 创建者成为组织 owner
+	member := &models.OrgMember{
+		OrgID:  org.ID,
+		UserID: userID.(uuid.UUID),
+		Role:   models.OrgRoleOwner,
+	}
+	if err := config.Storage.CreateOrgMember(member); err != nil {
+		config.Storage.DeleteOrganization(org.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, organizationResponse(org, member.Role))
+}
+
+func GetOrganization(c *gin.Context) {
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	org, member, ok := authorizeOrg(c, orgID, models.OrgRoleMember)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, organizationResponse(org, member.Role))
+}
+
+func UpdateOrganization(c *gin.Context) {
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, member, ok := authorizeOrg(c, orgID, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	org.Name = strings.TrimSpace(req.Name)
+	if !setOrgAPIKey(c, org, req.UpstreamAPIKey) {
+		return
+	}
+	if err := config.Storage.UpdateOrganization(org); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizationResponse(org, member.Role))
+}
+
+// DeleteOrganization 只能删除没有项目的组织，避免误删客户数据
+func DeleteOrganization(c *gin.Context) {
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	if _, _, ok := authorizeOrg(c, orgID, models.OrgRoleOwner); !ok {
+		return
+	}
+
+	projects, err := config.Storage.GetProjectsByOrgID(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+	if len(projects) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization still has projects"})
+		return
+	}
+
+	members, _ := config.Storage.GetOrgMembers(orgID)
+	for _, member := range members {
+		config.Storage.DeleteOrgMember(member.ID)
+	}
+	if err := config.Storage.DeleteOrganization(orgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+func GetOrgMembers(c *gin.Context) {
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	if _, _, ok := authorizeOrg(c, orgID, models.OrgRoleMember); !ok {
+		return
+	}
+
+	members, err := config.Storage.GetOrgMembers(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	response := []OrgMemberResponse{}
+	for _, member := range members {
+		response = append(response, orgMemberResponse(member))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AddOrgMember 将已注册用户直接加入组织；只有 owner 可以添加 owner
+func AddOrgMember(c *gin.Context) {
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	var req OrgMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validOrgRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	_, current, ok := authorizeOrg(c, orgID, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+	if req.Role == models.OrgRoleOwner && current.Role != models.OrgRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient organization permissions"})
+		return
+	}
+
+	user, err := config.Storage.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	existing, err := config.Storage.GetOrgMember(orgID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	}
+
+	member := &models.OrgMember{
+		OrgID:  orgID,
+		UserID: user.ID,
+		Role:   req.Role,
+	}
+	if err := config.Storage.CreateOrgMember(member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, orgMemberResponse(member))
+}
+
+func UpdateOrgMember(c *gin.Context) {
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	memberUserID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validOrgRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	// 角色调整只允许 owner 操作
+	if _, _, ok := authorizeOrg(c, orgID, models.OrgRoleOwner); !ok {
+		return
+	}
+
+	member, err := config.Storage.GetOrgMember(orgID, memberUserID)
+	if err != nil || member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if member.Role == models.OrgRoleOwner && req.Role != models.OrgRoleOwner {
+		owners, err := countOrgOwners(orgID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+			return
+		}
+		if owners <= 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Organization must keep at least one owner"})
+			return
+		}
+	}
+
+	member.Role = req.Role
+	if err := config.Storage.UpdateOrgMember(member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, orgMemberResponse(member))
+}
+
+// RemoveOrgMember admin 可以移除成员（owner 只能由 owner 移除）；成员也可以自己退出
+func RemoveOrgMember(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	memberUserID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	minRole := models.OrgRoleAdmin
+	if memberUserID == userID.(uuid.UUID) {
+		minRole = models.OrgRoleMember
+	}
+	_, current, ok := authorizeOrg(c, orgID, minRole)
+	if !ok {
+		return
+	}
+
+	member, err := config.Storage.GetOrgMember(orgID, memberUserID)
+	if err != nil || member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if member.Role == models.OrgRoleOwner {
+		if current.Role != models.OrgRoleOwner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient organization permissions"})
+			return
+		}
+		owners, err := countOrgOwners(orgID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+			return
+		}
+		if owners <= 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Organization must keep at least one owner"})
+			return
+		}
+	}
+
+	if err := config.Storage.DeleteOrgMember(member.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
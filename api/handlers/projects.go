@@ -17,7 +17,8 @@ type ProjectRequest struct {
 
 type ProjectResponse struct {
 	models.Project
-	ImageCount int `json:"image_count"`
+	ImageCount int    `json:"image_count"`
+	Role       string `json:"role"` // 当前用户在项目中的角色
 }
 
 func GetProjects(c *gin.Context) {
@@ -27,7 +28,13 @@ func GetProjects(c *gin.Context) {
 		return
 	}
 
-	projects, err := config.Storage.GetProjectsByUserID(userID.(uuid.UUID))
+	orgID, _, ok := workspaceScope(c)
+	if !ok {
+		return
+	}
+
+	// 包括自己创建的项目与被邀请加入的项目，限定在当前工作区内
+	projects, roles, err := accessibleProjects(userID.(uuid.UUID), orgID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
 		return
@@ -40,6 +47,7 @@ func GetProjects(c *gin.Context) {
 		response = append(response, ProjectResponse{
 			Project:    *project,
 			ImageCount: len(images),
+			Role:       roles[project.ID],
 		})
 	}
 
@@ -59,8 +67,15 @@ func CreateProject(c *gin.Context) {
 		return
 	}
 
+	// 在组织工作区中创建的项目归组织所有
+	orgID, _, ok := workspaceScope(c)
+	if !ok {
+		return
+	}
+
 	project := &models.Project{
 		UserID:      userID.(uuid.UUID),
+		OrgID:       orgID,
 		Title:       req.Title,
 		Description: req.Description,
 		Type:        req.Type,
@@ -75,40 +90,26 @@ func CreateProject(c *gin.Context) {
 }
 
 func GetProject(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return
-	}
-
 	projectID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
 		return
 	}
 
-	project, err := config.Storage.GetProjectByID(projectID)
-	if err != nil || project == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-		return
-	}
-	
-	// 检查项目是否属于当前用户
-	if project.UserID != userID.(uuid.UUID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	project, role, ok := authorizeProject(c, projectID, models.RoleViewer)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, project)
+	images, _ := config.Storage.GetImagesByProjectID(project.ID)
+	c.JSON(http.StatusOK, ProjectResponse{
+		Project:    *project,
+		ImageCount: len(images),
+		Role:       role,
+	})
 }
 
 func UpdateProject(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return
-	}
-
 	projectID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
@@ -121,15 +122,8 @@ func UpdateProject(c *gin.Context) {
 		return
 	}
 
-	project, err := config.Storage.GetProjectByID(projectID)
-	if err != nil || project == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-		return
-	}
-	
-	// 检查项目是否属于当前用户
-	if project.UserID != userID.(uuid.UUID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	project, _, ok := authorizeProject(c, projectID, models.RoleEditor)
+	if !ok {
 		return
 	}
 
@@ -146,27 +140,13 @@ func UpdateProject(c *gin.Context) {
 }
 
 func DeleteProject(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return
-	}
-
 	projectID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
 		return
 	}
 
-	project, err := config.Storage.GetProjectByID(projectID)
-	if err != nil || project == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-		return
-	}
-	
-	// 检查项目是否属于当前用户
-	if project.UserID != userID.(uuid.UUID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	if _, _, ok := authorizeProject(c, projectID, models.RoleOwner); !ok {
 		return
 	}
 
@@ -175,5 +155,19 @@ func DeleteProject(c *gin.Context) {
 		return
 	}
 
+	// 同时移除成员关系、分镜镜头与参考素材
+	members, _ := config.Storage.GetProjectMembers(projectID)
+	for _, member := range members {
+		config.Storage.DeleteProjectMember(member.ID)
+	}
+	scenes, _ := config.Storage.GetScenesByProjectID(projectID)
+	for _, scene := range scenes {
+		config.Storage.DeleteScene(scene.ID)
+	}
+	refs, _ := config.Storage.GetReferencesByProjectID(projectID)
+	for _, ref := range refs {
+		config.Storage.DeleteReference(ref.ID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Project deleted successfully"})
 }
\ No newline at end of file
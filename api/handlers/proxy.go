@@ -1,96 +1,225 @@
 package handlers
 
 import (
-    "bytes"
+    "context"
     "encoding/json"
-    "io"
+    "errors"
     "net/http"
+    "strconv"
+    "time"
 
     "ai-design-backend/config"
+    "ai-design-backend/models"
+    "ai-design-backend/providers"
+    "ai-design-backend/upstream"
+    "ai-design-backend/usage"
 
     "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
 )
 
-func getAPIKey(c *gin.Context) string {
-    auth := c.GetHeader("Authorization")
-    if len(auth) > 7 && auth[:7] == "Bearer " {
-        return auth[7:]
+// passthroughProvider 透传的 key 是调用方的七牛云 key，只能发给七牛云
+const passthroughProvider = "qiniu"
+
+// proxyContext 决定代理调用使用的上游 key：透传模式只使用调用方自带的 key，
+// 且只允许七牛云的模型；已认证用户使用自己保存的 key，没有时使用服务端配置的 key。
+// 已认证的调用会计入用量，配额或额度不足时写出错误响应并返回 ok=false。
+func proxyContext(c *gin.Context, provider providers.ImageProvider, model, size string, n int) (context.Context, *usage.Charge, bool) {
+    ctx := c.Request.Context()
+    c.Set("proxyModel", model)
+
+    if c.GetString("proxyAuthMode") == models.ProxyAuthPassthrough {
+        if provider.Name() != passthroughProvider {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Passthrough keys can only be used with Qiniu models"})
+            return nil, nil, false
+        }
+        return providers.WithAPIKey(ctx, c.GetString("upstreamAPIKey")), nil, true
+    }
+    userID, ok := c.Get("userID")
+    if !ok {
+        return ctx, nil, true
     }
-    return config.Config.QiniuAPIKey
-}
-
-func forwardPost(c *gin.Context, path string) {
-    base := config.Config.QiniuBaseURL
-    apiKey := getAPIKey(c)
 
-    raw, err := io.ReadAll(c.Request.Body)
+    apiKey, keySource := resolveAPIKey(userID.(uuid.UUID), nil, model)
+    charge, err := usage.Begin(usageCall(userID.(uuid.UUID), nil, "proxy", model, size, n, keySource))
     if err != nil {
+        usageError(c, err)
+        return nil, nil, false
+    }
+    return providers.WithAPIKey(ctx, apiKey), charge, true
+}
+
+// decodeProxyBody 解析请求体，已知字段由 take* 取出，剩余字段原样透传
+func decodeProxyBody(c *gin.Context) (map[string]interface{}, bool) {
+    body := map[string]interface{}{}
+    if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+        return nil, false
+    }
+    return body, true
+}
+
+func takeString(body map[string]interface{}, key string) string {
+    v, ok := body[key].(string)
+    if ok {
+        delete(body, key)
+    }
+    return v
+}
+
+func takeInt(body map[string]interface{}, key string) int {
+    v, ok := body[key].(float64)
+    if ok {
+        delete(body, key)
+    }
+    return int(v)
+}
+
+// takeStrings 支持单个字符串或字符串数组
+func takeStrings(body map[string]interface{}, key string) (string, []string) {
+    switch v := body[key].(type) {
+    case string:
+        delete(body, key)
+        return v, nil
+    case []interface{}:
+        var list []string
+        for _, item := range v {
+            if s, ok := item.(string); ok {
+                list = append(list, s)
+            }
+        }
+        delete(body, key)
+        return "", list
+    }
+    return "", nil
+}
+
+// upstreamUnavailable 熔断期间返回 503，调用方可在冷却结束后重试
+func upstreamUnavailable(c *gin.Context, err error) bool {
+    if !errors.Is(err, upstream.ErrCircuitOpen) {
+        return false
+    }
+    c.Header("Retry-After", strconv.Itoa(int(config.Config.BreakerCooldown.Seconds())))
+    c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Upstream temporarily unavailable"})
+    return true
+}
+
+// forbiddenImageURL 图片 URL 解析到内网等不允许拉取的地址时返回 400
+func forbiddenImageURL(c *gin.Context, err error) bool {
+    if !errors.Is(err, upstream.ErrForbiddenAddress) {
+        return false
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Image URL points to a forbidden address"})
+    return true
+}
+
+// writeProxyError 上游错误保留原状态码、响应体与 Retry-After
+func writeProxyError(c *gin.Context, err error) {
+    if upstreamUnavailable(c, err) || forbiddenImageURL(c, err) {
+        return
+    }
+    var upstreamErr *providers.UpstreamError
+    if errors.As(err, &upstreamErr) {
+        if upstreamErr.RetryAfter > 0 {
+            c.Header("Retry-After", strconv.Itoa(int(upstreamErr.RetryAfter.Seconds())))
+        }
+        c.Data(upstreamErr.StatusCode, "application/json", upstreamErr.Body)
         return
     }
+    c.JSON(http.StatusBadGateway, gin.H{"error": "upstream request failed"})
+}
 
-    req, err := http.NewRequest("POST", base+path, bytes.NewReader(raw))
+// writeProxyResult 以 OpenAI 兼容格式返回结果，上游错误保留原状态码与响应体
+func writeProxyResult(c *gin.Context, outputs []providers.Output, err error) {
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "request init failed"})
+        writeProxyError(c, err)
         return
     }
-    req.Header.Set("Content-Type", "application/json")
-    if apiKey != "" {
-        req.Header.Set("Authorization", "Bearer "+apiKey)
+    if outputs == nil {
+        outputs = []providers.Output{}
     }
+    c.JSON(http.StatusOK, gin.H{"created": time.Now().Unix(), "data": outputs})
+}
 
-    resp, err := http.DefaultClient.Do(req)
-    if err != nil {
-        c.JSON(http.StatusBadGateway, gin.H{"error": "upstream request failed"})
+func ProxyGenerateImage(c *gin.Context) {
+    body, ok := decodeProxyBody(c)
+    if !ok {
         return
     }
-    defer resp.Body.Close()
 
-    body, err := io.ReadAll(resp.Body)
+    req := providers.GenerateRequest{
+        Model:          takeString(body, "model"),
+        Prompt:         takeString(body, "prompt"),
+        Size:           takeString(body, "size"),
+        N:              takeInt(body, "n"),
+        ResponseFormat: takeString(body, "response_format"),
+        Extra:          body,
+    }
+
+    provider, err := providers.Default.ForModel(req.Model)
     if err != nil {
-        c.JSON(http.StatusBadGateway, gin.H{"error": "upstream read failed"})
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "request init failed"})
         return
     }
 
-    c.Status(resp.StatusCode)
-    c.Header("Content-Type", "application/json")
-    c.Writer.Write(body)
-}
-
-func ProxyGenerateImage(c *gin.Context) {
-    forwardPost(c, "/images/generations")
+    ctx, charge, ok := proxyContext(c, provider, req.Model, req.Size, req.N)
+    if !ok {
+        return
+    }
+    outputs, err := provider.Generate(ctx, req)
+    charge.Finish(len(outputs), err)
+    writeProxyResult(c, outputs, err)
 }
 
 func ProxyEditImage(c *gin.Context) {
-    forwardPost(c, "/images/edits")
-}
+    body, ok := decodeProxyBody(c)
+    if !ok {
+        return
+    }
 
-func ProxyModels(c *gin.Context) {
-    base := config.Config.QiniuBaseURL
-    apiKey := getAPIKey(c)
+    req := providers.EditRequest{
+        Model:  takeString(body, "model"),
+        Prompt: takeString(body, "prompt"),
+        Size:   takeString(body, "size"),
+        N:      takeInt(body, "n"),
+        Mask:   takeString(body, "mask"),
+    }
+    req.Image, req.Images = takeStrings(body, "image")
+    req.Extra = body
 
-    req, err := http.NewRequest("GET", base+"/models", nil)
+    provider, err := providers.Default.ForModel(req.Model)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "request init failed"})
         return
     }
-    if apiKey != "" {
-        req.Header.Set("Authorization", "Bearer "+apiKey)
-    }
 
-    resp, err := http.DefaultClient.Do(req)
-    if err != nil {
-        c.JSON(http.StatusBadGateway, gin.H{"error": "upstream request failed"})
+    ctx, charge, ok := proxyContext(c, provider, req.Model, req.Size, req.N)
+    if !ok {
         return
     }
-    defer resp.Body.Close()
+    outputs, err := provider.Edit(ctx, req)
+    charge.Finish(len(outputs), err)
+    writeProxyResult(c, outputs, err)
+}
 
-    var data any
-    dec := json.NewDecoder(resp.Body)
-    if err := dec.Decode(&data); err != nil {
-        c.JSON(http.StatusBadGateway, gin.H{"error": "upstream decode failed"})
+func ProxyModels(c *gin.Context) {
+    // 模型列表会请求所有 provider，用户保存的 key 只属于单个 provider，这里不使用
+    ctx := c.Request.Context()
+    if c.GetString("proxyAuthMode") == models.ProxyAuthPassthrough {
+        // 透传的 key 只用于查询七牛云自己的模型列表
+        list, err := providers.Default.Get(passthroughProvider).ListModels(providers.WithAPIKey(ctx, c.GetString("upstreamAPIKey")))
+        if err != nil {
+            writeProxyError(c, err)
+            return
+        }
+        c.JSON(http.StatusOK, gin.H{"object": "list", "data": list})
+        return
+    }
+    list, err := providers.Default.ListModels(ctx)
+    if err != nil {
+        writeProxyError(c, err)
         return
     }
 
-    c.JSON(resp.StatusCode, data)
-}
\ No newline at end of file
+    c.JSON(http.StatusOK, gin.H{"object": "list", "data": list})
+}
@@ -0,0 +1,82 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"ai-design-backend/providers"
+	"ai-design-backend/upstream"
+)
+
+// fakeQiniu 记录收到的 Authorization 头
+func fakeQiniu(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var auths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auths = append(auths, r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/models":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": []map[string]string{{"id": "qiniu-image", "object": "model"}},
+			})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": []map[string]string{{"url": "https://example.com/a.png"}},
+			})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), auths...)
+	}
+}
+
+func TestProxyPassthroughOnlyReachesQiniu(t *testing.T) {
+	srv, auths := fakeQiniu(t)
+	t.Setenv("QINIU_BASE_URL", srv.URL)
+	t.Setenv("QINIU_API_KEY", "server-key")
+	t.Setenv("UPSTREAM_MAX_RETRIES", "0")
+	r := newTestRouter(t)
+	upstream.Init()
+	providers.Init()
+
+	code, resp := do(t, r, "POST", "/api/v1/proxy/images/generations", "caller-key", map[string]interface{}{
+		"model": "mock-image", "prompt": "a cat",
+	})
+	if code != http.StatusBadRequest {
+		t.Fatalf("mock model: %d %v", code, resp)
+	}
+	if got := auths(); len(got) != 0 {
+		t.Fatalf("upstream called for rejected request: %v", got)
+	}
+
+	code, resp = do(t, r, "POST", "/api/v1/proxy/images/generations", "caller-key", map[string]interface{}{
+		"model": "qiniu-image", "prompt": "a cat",
+	})
+	if code != http.StatusOK {
+		t.Fatalf("qiniu model: %d %v", code, resp)
+	}
+
+	code, resp = do(t, r, "GET", "/api/v1/proxy/models", "caller-key", nil)
+	if code != http.StatusOK {
+		t.Fatalf("models: %d %v", code, resp)
+	}
+	if data, _ := resp["data"].([]interface{}); len(data) != 1 {
+		t.Fatalf("passthrough models should only list qiniu models: %v", resp)
+	}
+
+	for _, auth := range auths() {
+		if auth != "Bearer caller-key" {
+			t.Fatalf("upstream got %q, want the caller's key", auth)
+		}
+	}
+}
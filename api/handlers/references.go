@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ai-design-backend/blobstore"
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxReferenceAssets 每个项目的参考素材上限，生成时全部作为参考图发送，过多会超出上游限制
+const maxReferenceAssets = 6
+
+// CreateReferenceRequest 图片二选一：image_id（本项目中已完成的图片）或 multipart 上传的 image 文件
+type CreateReferenceRequest struct {
+	ImageID     string `json:"image_id" form:"image_id"`
+	Kind        string `json:"kind" form:"kind" binding:"required"`
+	Name        string `json:"name" form:"name"`
+	Description string `json:"description" form:"description"`
+}
+
+type UpdateReferenceRequest struct {
+	Kind        *string `json:"kind"`
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+type ReferenceResponse struct {
+	models.ReferenceAsset
+	Image *models.Image `json:"image,omitempty"`
+}
+
+func validReferenceKind(kind string) bool {
+	switch kind {
+	case models.ReferenceCharacter, models.ReferenceStyle, models.ReferenceLocation:
+		return true
+	}
+	return false
+}
+
+// projectReference 加载 :refId 指向的参考素材，不属于该项目时返回 404
+func projectReference(c *gin.Context, project *models.Project) (*models.ReferenceAsset, bool) {
+	refID, err := uuid.Parse(c.Param("refId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reference ID"})
+		return nil, false
+	}
+
+	ref, err := config.Storage.GetReferenceByID(refID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reference"})
+		return nil, false
+	}
+	if ref == nil || ref.ProjectID != project.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reference not found"})
+		return nil, false
+	}
+	return ref, true
+}
+
+func GetReferences(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	if _, _, ok := authorizeProject(c, projectID, models.RoleViewer); !ok {
+		return
+	}
+
+	refs, err := config.Storage.GetReferencesByProjectID(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch references"})
+		return
+	}
+
+	response := []ReferenceResponse{}
+	for _, ref := range refs {
+		item := ReferenceResponse{ReferenceAsset: *ref}
+		item.Image, _ = config.Storage.GetImageByID(ref.ImageID)
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateReference 将已生成的图片或上传的图片登记为参考素材；上传的图片保存为项目中的一张图片
+func CreateReference(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	project, _, ok := authorizeProject(c, projectID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.Config.MaxImageSize+1<<20)
+	}
+	var req CreateReferenceRequest
+	if err := c.ShouldBind(&req); err != nil {
+		if !imageInputError(c, err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if !validReferenceKind(req.Kind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be one of character, style, location"})
+		return
+	}
+
+	refs, err := config.Storage.GetReferencesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch references"})
+		return
+	}
+	if len(refs) >= maxReferenceAssets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A project can have at most %d reference assets", maxReferenceAssets)})
+		return
+	}
+
+	var upload bool
+	if c.Request.MultipartForm != nil {
+		_, err := c.FormFile("image")
+		upload = err == nil
+	}
+	if upload == (req.ImageID != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of image_id or an uploaded image file is required"})
+		return
+	}
+
+	var image *models.Image
+	if upload {
+		fh, _ := c.FormFile("image")
+		data, contentType, err := readImageUpload(fh)
+		if err != nil {
+			if !imageInputError(c, err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded image"})
+			}
+			return
+		}
+		info, err := blobstore.Default.Put(c.Request.Context(), data, contentType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+			return
+		}
+
+		now := time.Now()
+		image = &models.Image{
+			ID:          uuid.New(),
+			ProjectID:   project.ID,
+			UserID:      userID,
+			Model:       "upload",
+			Status:      "completed",
+			BlobKey:     info.Key,
+			ContentType: info.ContentType,
+			FileSize:    info.Size,
+			GeneratedAt: &now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := config.Storage.CreateImage(image); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create image"})
+			return
+		}
+	} else {
+		imageID, err := uuid.Parse(req.ImageID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+			return
+		}
+		image, err = config.Storage.GetImageByID(imageID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+			return
+		}
+		if image == nil || image.ProjectID != project.ID || image.Status != "completed" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Image must be a completed image in this project"})
+			return
+		}
+	}
+
+	ref := &models.ReferenceAsset{
+		ProjectID:   project.ID,
+		ImageID:     image.ID,
+		Kind:        req.Kind,
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		CreatedBy:   userID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := config.Storage.CreateReference(ref); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reference"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ReferenceResponse{ReferenceAsset: *ref, Image: image})
+}
+
+func UpdateReference(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	project, _, ok := authorizeProject(c, projectID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	ref, ok := projectReference(c, project)
+	if !ok {
+		return
+	}
+
+	var req UpdateReferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := *ref
+	if req.Kind != nil {
+		if !validReferenceKind(*req.Kind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be one of character, style, location"})
+			return
+		}
+		updated.Kind = *req.Kind
+	}
+	if req.Name != nil {
+		updated.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		updated.Description = strings.TrimSpace(*req.Description)
+	}
+	updated.UpdatedAt = time.Now()
+
+	if err := config.Storage.UpdateReference(&updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reference"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteReference 只移除参考素材，图片本身保留在项目中
+func DeleteReference(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+	project, _, ok := authorizeProject(c, projectID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	ref, ok := projectReference(c, project)
+	if !ok {
+		return
+	}
+
+	if err := config.Storage.DeleteReference(ref.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reference"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reference deleted successfully"})
+}
+
+// referenceSet 一次生成要附带的参考图及其说明
+type referenceSet struct {
+	Images []string // data URL，顺序与 Note 中的编号一致
+	Note   string
+}
+
+// prompt 在提示词后附上参考图说明
+func (r *referenceSet) prompt(prompt string) string {
+	if r == nil {
+		return prompt
+	}
+	return prompt + "\n\n" + r.Note
+}
+
+// loadReferences 读取项目的参考素材；项目没有参考素材时返回 nil
+func loadReferences(ctx context.Context, projectID uuid.UUID) (*referenceSet, error) {
+	refs, err := config.Storage.GetReferencesByProjectID(projectID)
+	if err != nil || len(refs) == 0 {
+		return nil, err
+	}
+
+	set := &referenceSet{}
+	var notes []string
+	for _, ref := range refs {
+		image, err := config.Storage.GetImageByID(ref.ImageID)
+		if err != nil {
+			return nil, err
+		}
+		if image == nil {
+			continue
+		}
+		data, contentType, err := loadImageContent(ctx, image)
+		if err != nil {
+			return nil, fmt.Errorf("load reference %s: %w", ref.ID, err)
+		}
+		set.Images = append(set.Images, dataURL(contentType, data))
+
+		note := fmt.Sprintf("reference image %d is the %s", len(set.Images), ref.Kind)
+		if ref.Name != "" {
+			note += fmt.Sprintf(" %q", ref.Name)
+		}
+		if ref.Description != "" {
+			note += " (" + ref.Description + ")"
+		}
+		notes = append(notes, note)
+	}
+	if len(set.Images) == 0 {
+		return nil, nil
+	}
+
+	set.Note = "Keep characters, visual style and locations consistent with the reference images: " +
+		strings.Join(notes, "; ") + "."
+	return set, nil
+}
+
+// projectReferences 按请求决定是否附带参考素材，读取失败时写出错误响应并返回 ok=false
+func projectReferences(c *gin.Context, project *models.Project, use *bool) (*referenceSet, bool) {
+	if project == nil || (use != nil && !*use) {
+		return nil, true
+	}
+	refs, err := loadReferences(c.Request.Context(), project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load reference assets"})
+		return nil, false
+	}
+	return refs, true
+}
@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+	"ai-design-backend/providers"
+	"ai-design-backend/scripts"
+	"ai-design-backend/usage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxProjectScenes 每个项目的镜头数上限，与脚本生成的上限一致
+	maxProjectScenes = scripts.MaxScenes
+	// maxSceneDurationMs 单个镜头的时长上限，与脚本的总时长上限一致
+	maxSceneDurationMs = scripts.MaxDurationMs
+)
+
+var (
+	tooManyScenesMessage      = fmt.Sprintf("A project can have at most %d scenes", maxProjectScenes)
+	invalidSceneTimingMessage = fmt.Sprintf("Invalid scene time range: end_ms must not precede start_ms and a scene can last at most %d ms", maxSceneDurationMs)
+)
+
+type CreateSceneRequest struct {
+	// Position 插入位置（从 0 开始），为空或超出范围时追加到末尾
+	Position     *int   `json:"position"`
+	StartMs      int64  `json:"start_ms"`
+	EndMs        int64  `json:"end_ms"`
+	Description  string `json:"description"`
+	VisualPrompt string `json:"visual_prompt"`
+	CameraNotes  string `json:"camera_notes"`
+}
+
+type UpdateSceneRequest struct {
+	StartMs      *int64  `json:"start_ms"`
+	EndMs        *int64  `json:"end_ms"`
+	Description  *string `json:"description"`
+	VisualPrompt *string `json:"visual_prompt"`
+	CameraNotes  *string `json:"camera_notes"`
+	// SelectedImageID 为空字符串时取消选择
+	SelectedImageID *string `json:"selected_image_id"`
+}
+
+type ReorderScenesRequest struct {
+	SceneIDs []uuid.UUID `json:"scene_ids" binding:"required"`
+}
+
+type GenerateScenesRequest struct {
+	Model     string            `json:"model"`
+	Size      string            `json:"size"`
+	Template  string            `json:"template"`
+	Variables map[string]string `json:"variables"`
+	// Async 默认为 true：立即返回 202 及任务ID；显式传 false 时同步等待结果
+	Async *bool `json:"async"`
+	// UseReferences 为 false 时不附带项目的参考素材，默认附带
+	UseReferences *bool `json:"use_references"`
+}
+
+type SceneResponse struct {
+	models.Scene
+	SelectedImage *models.Image `json:"selected_image,omitempty"`
+}
+
+// storyboardProject 校验项目权限，并要求项目类型为 storyboard
+func storyboardProject(c *gin.Context, minRole string) (*models.Project, bool) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return nil, false
+	}
+
+	project, _, ok := authorizeProject(c, projectID, minRole)
+	if !ok {
+		return nil, false
+	}
+	if project.Type != "storyboard" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scenes are only available for storyboard projects"})
+		return nil, false
+	}
+	return project, true
+}
+
+// projectScene 加载 :sceneId 指向的镜头，不属于该项目时返回 404
+func projectScene(c *gin.Context, project *models.Project) (*models.Scene, bool) {
+	sceneID, err := uuid.Parse(c.Param("sceneId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scene ID"})
+		return nil, false
+	}
+
+	scene, err := config.Storage.GetSceneByID(sceneID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scene"})
+		return nil, false
+	}
+	if scene == nil || scene.ProjectID != project.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scene not found"})
+		return nil, false
+	}
+	return scene, true
+}
+
+func validSceneTiming(startMs, endMs int64) bool {
+	return startMs >= 0 && endMs >= startMs && endMs-startMs <= maxSceneDurationMs
+}
+
+func sceneIDs(scenes []*models.Scene) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(scenes))
+	for _, scene := range scenes {
+		ids = append(ids, scene.ID)
+	}
+	return ids
+}
+
+// scenePrompt 生成画面使用的提示词：优先 VisualPrompt，其次 Description，并附上镜头说明
+func scenePrompt(scene *models.Scene) string {
+	prompt := strings.TrimSpace(scene.VisualPrompt)
+	if prompt == "" {
+		prompt = strings.TrimSpace(scene.Description)
+	}
+	if prompt == "" {
+		return ""
+	}
+	if notes := strings.TrimSpace(scene.CameraNotes); notes != "" {
+		prompt += ", " + notes
+	}
+	return prompt
+}
+
+// GetScenes 按顺序返回项目的所有镜头及其选用的画面
+func GetScenes(c *gin.Context) {
+	project, ok := storyboardProject(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	scenes, err := config.Storage.GetScenesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scenes"})
+		return
+	}
+
+	response := []SceneResponse{}
+	for _, scene := range scenes {
+		item := SceneResponse{Scene: *scene}
+		if scene.SelectedImageID != nil {
+			item.SelectedImage, _ = config.Storage.GetImageByID(*scene.SelectedImageID)
+		}
+		response = append(response, item)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateScene 在指定位置插入镜头，其后的镜头顺延
+func CreateScene(c *gin.Context) {
+	project, ok := storyboardProject(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var req CreateSceneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validSceneTiming(req.StartMs, req.EndMs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidSceneTimingMessage})
+		return
+	}
+
+	scenes, err := config.Storage.GetScenesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create scene"})
+		return
+	}
+	if len(scenes) >= maxProjectScenes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": tooManyScenesMessage})
+		return
+	}
+	position := len(scenes)
+	if req.Position != nil && *req.Position >= 0 && *req.Position < len(scenes) {
+		position = *req.Position
+	}
+
+	scene := &models.Scene{
+		ProjectID:    project.ID,
+		OrderIndex:   position,
+		StartMs:      req.StartMs,
+		EndMs:        req.EndMs,
+		Description:  req.Description,
+		VisualPrompt: req.VisualPrompt,
+		CameraNotes:  req.CameraNotes,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	if err := config.Storage.CreateScene(scene); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create scene"})
+		return
+	}
+
+	// 重写顺序，保证 OrderIndex 连续
+	ids := sceneIDs(scenes)
+	ids = append(ids[:position], append([]uuid.UUID{scene.ID}, ids[position:]...)...)
+	if err := config.Storage.SetSceneOrder(project.ID, ids); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder scenes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, scene)
+}
+
+func UpdateScene(c *gin.Context) {
+	project, ok := storyboardProject(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scene, ok := projectScene(c, project)
+	if !ok {
+		return
+	}
+
+	var req UpdateSceneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := *scene
+	if req.StartMs != nil {
+		updated.StartMs = *req.StartMs
+	}
+	if req.EndMs != nil {
+		updated.EndMs = *req.EndMs
+	}
+	if !validSceneTiming(updated.StartMs, updated.EndMs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidSceneTimingMessage})
+		return
+	}
+	if req.Description != nil {
+		updated.Description = *req.Description
+	}
+	if req.VisualPrompt != nil {
+		updated.VisualPrompt = *req.VisualPrompt
+	}
+	if req.CameraNotes != nil {
+		updated.CameraNotes = *req.CameraNotes
+	}
+
+	// 只能选用同一项目中已生成完成的图片
+	if req.SelectedImageID != nil {
+		if *req.SelectedImageID == "" {
+			updated.SelectedImageID = nil
+		} else {
+			imageID, err := uuid.Parse(*req.SelectedImageID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+				return
+			}
+			image, err := config.Storage.GetImageByID(imageID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+				return
+			}
+			if image == nil || image.ProjectID != project.ID || image.Status != "completed" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Image must be a completed image in this project"})
+				return
+			}
+			updated.SelectedImageID = &image.ID
+		}
+	}
+	updated.UpdatedAt = time.Now()
+
+	if err := config.Storage.UpdateScene(&updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update scene"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteScene 删除镜头，已生成的图片保留在项目中
+func DeleteScene(c *gin.Context) {
+	project, ok := storyboardProject(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scene, ok := projectScene(c, project)
+	if !ok {
+		return
+	}
+
+	if err := config.Storage.DeleteScene(scene.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scene"})
+		return
+	}
+
+	// 压缩剩余镜头的顺序
+	scenes, err := config.Storage.GetScenesByProjectID(project.ID)
+	if err == nil {
+		err = config.Storage.SetSceneOrder(project.ID, sceneIDs(scenes))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder scenes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scene deleted successfully"})
+}
+
+// ReorderScenes scene_ids 必须恰好包含项目中的每个镜头一次
+func ReorderScenes(c *gin.Context) {
+	project, ok := storyboardProject(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var req ReorderScenesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scenes, err := config.Storage.GetScenesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scenes"})
+		return
+	}
+	remaining := make(map[uuid.UUID]bool, len(scenes))
+	for _, scene := range scenes {
+		remaining[scene.ID] = true
+	}
+	for _, id := range req.SceneIDs {
+		if !remaining[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scene_ids must list every scene in the project exactly once"})
+			return
+		}
+		delete(remaining, id)
+	}
+	if len(remaining) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scene_ids must list every scene in the project exactly once"})
+		return
+	}
+
+	if err := config.Storage.SetSceneOrder(project.ID, req.SceneIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder scenes"})
+		return
+	}
+
+	GetScenes(c)
+}
+
+// GenerateMissingFrames 为每个还没有选用画面、也没有进行中图片的镜头生成一张图片，
+// 生成成功后自动设为该镜头的画面
+func GenerateMissingFrames(c *gin.Context) {
+	project, ok := storyboardProject(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req GenerateScenesRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Model == "" {
+		req.Model = config.Config.DefaultImageModel
+	}
+	if req.Size == "" {
+		req.Size = "1024x1024"
+	}
+
+	scenes, err := config.Storage.GetScenesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scenes"})
+		return
+	}
+	images, err := config.Storage.GetImagesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+	pending := make(map[uuid.UUID]bool)
+	for _, image := range images {
+		if image.SceneID != nil && image.Status == "pending" {
+			pending[*image.SceneID] = true
+		}
+	}
+
+	job := &models.Job{
+		UserID:    userID,
+		ProjectID: project.ID,
+		Type:      "storyboard",
+	}
+
+	var records []*models.Image
+	for _, scene := range scenes {
+		if scene.SelectedImageID != nil || pending[scene.ID] {
+			continue
+		}
+		prompt := scenePrompt(scene)
+		if prompt == "" {
+			continue
+		}
+
+		sceneID := scene.ID
+		image := &models.Image{
+			ID:        uuid.New(),
+			UserID:    userID,
+			ProjectID: project.ID,
+			SceneID:   &sceneID,
+			Prompt:    prompt,
+			Model:     req.Model,
+			Size:      req.Size,
+			Status:    "pending",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if req.Template != "" {
+			template, rendered, ok := renderPrompt(c, userID, req.Template, prompt, req.Variables)
+			if !ok {
+				return
+			}
+			image.Prompt = rendered
+			image.Template = template.Name
+			image.TemplateVersion = template.Version
+		}
+		records = append(records, image)
+	}
+
+	if len(records) == 0 {
+		c.JSON(http.StatusOK, GenerateImageResponse{
+			Success: true,
+			Images:  []string{},
+			Message: "No scenes are missing frames",
+		})
+		return
+	}
+
+	apiKey, keySource := resolveAPIKey(userID, project, req.Model)
+	call := usageCall(userID, project, "storyboard", req.Model, req.Size, 1, keySource)
+	batchCall := call
+	batchCall.N = len(records)
+	if err := usage.Check(batchCall); err != nil {
+		usageError(c, err)
+		return
+	}
+
+	refs, ok := projectReferences(c, project, req.UseReferences)
+	if !ok {
+		return
+	}
+
+	run := func(ctx context.Context, image *models.Image) error {
+		ctx = providers.WithAPIKey(ctx, apiKey)
+		outputs, err := meteredReferenceGenerate(ctx, call, image.Prompt, refs)
+		if err != nil {
+			return err
+		}
+		if len(outputs) == 0 {
+			return errors.New("no image returned")
+		}
+		if err := storeOutput(ctx, image, outputs[0]); err != nil {
+			return err
+		}
+		// 期间用户可能已手动选择了画面，只填补仍然空缺的镜头
+		config.Storage.SelectSceneImage(*image.SceneID, image.ID)
+		return nil
+	}
+
+	if !submitJob(c, job, records, run, asyncRequested(req.Async)) {
+		return
+	}
+
+	var ids []uuid.UUID
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+
+	c.JSON(http.StatusOK, GenerateImageResponse{
+		Success:  true,
+		Images:   storedImageURLs(ids),
+		Message:  "Scene frames generated successfully",
+		JobID:    &job.ID,
+		ImageIDs: ids,
+	})
+}
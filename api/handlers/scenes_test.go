@@ -0,0 +1,48 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"ai-design-backend/scripts"
+)
+
+func TestSceneLimits(t *testing.T) {
+	r := newTestRouter(t)
+
+	code, resp := do(t, r, "POST", "/api/v1/auth/register", "", map[string]string{
+		"email": "scenes@example.com", "username": "scener", "password": "secret123",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("register: %d %v", code, resp)
+	}
+	token, _ := resp["token"].(string)
+
+	code, resp = do(t, r, "POST", "/api/v1/projects", token, map[string]string{"title": "board", "type": "storyboard"})
+	if code != http.StatusCreated {
+		t.Fatalf("create project: %d %v", code, resp)
+	}
+	projectID, _ := resp["id"].(string)
+	scenesPath := "/api/v1/projects/" + projectID + "/scenes"
+
+	code, resp = do(t, r, "POST", scenesPath, token, map[string]int64{"start_ms": 0, "end_ms": scripts.MaxDurationMs + 1})
+	if code != http.StatusBadRequest {
+		t.Fatalf("overlong scene: %d %v", code, resp)
+	}
+
+	for i := 0; i < scripts.MaxScenes; i++ {
+		code, resp = do(t, r, "POST", scenesPath, token, map[string]int64{"start_ms": int64(i) * 1000, "end_ms": int64(i+1) * 1000})
+		if code != http.StatusCreated {
+			t.Fatalf("scene %d: %d %v", i, code, resp)
+		}
+	}
+	code, resp = do(t, r, "POST", scenesPath, token, map[string]int64{"start_ms": 0, "end_ms": 1000})
+	if code != http.StatusBadRequest {
+		t.Fatalf("scene over the limit: %d %v", code, resp)
+	}
+
+	code, resp = do(t, r, "POST", "/api/v1/projects/"+projectID+"/script", token, map[string]interface{}{"synopsis": "more shots", "scene_count": 1})
+	if code != http.StatusBadRequest {
+		t.Fatalf("appending a script over the limit: %d %v", code, resp)
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+	"ai-design-backend/providers"
+	"ai-design-backend/scripts"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type GenerateScriptRequest struct {
+	Synopsis   string `json:"synopsis" binding:"required"`
+	DurationMs int64  `json:"duration_ms"`
+	SceneCount int    `json:"scene_count"`
+	Model      string `json:"model"`
+	// Replace 为 true 时替换项目中已有的镜头，否则追加在其后，时间轴顺延
+	Replace bool `json:"replace"`
+}
+
+type GenerateScriptResponse struct {
+	Title  string         `json:"title"`
+	Scenes []models.Scene `json:"scenes"`
+}
+
+// GenerateScript 根据梗概调用文本模型生成分镜脚本，并保存为项目的镜头
+func GenerateScript(c *gin.Context) {
+	project, ok := storyboardProject(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req GenerateScriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Model == "" {
+		req.Model = config.Config.ScriptModel
+	}
+
+	scriptReq := scripts.Request{
+		Model:      req.Model,
+		Synopsis:   req.Synopsis,
+		DurationMs: req.DurationMs,
+		SceneCount: req.SceneCount,
+	}
+	if err := scriptReq.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := config.Storage.GetScenesByProjectID(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scenes"})
+		return
+	}
+	if !req.Replace && len(existing)+scriptReq.SceneCount > maxProjectScenes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": tooManyScenesMessage})
+		return
+	}
+
+	// 与图片生成相同：优先组织 key，其次用户自己的 key
+	ctx := c.Request.Context()
+	if providers.Chat != nil {
+		apiKey := projectAPIKey(project)
+		if apiKey == "" {
+			apiKey = userAPIKey(userID, providers.Chat.Name())
+		}
+		ctx = providers.WithAPIKey(ctx, apiKey)
+	}
+
+	script, err := scripts.Generate(ctx, providers.Chat, scriptReq)
+	if err != nil {
+		if upstreamUnavailable(c, err) {
+			return
+		}
+		if errors.Is(err, scripts.ErrInvalidScript) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to call chat API"})
+		return
+	}
+
+	// 替换时新镜头从头排列，旧镜头在新镜头全部保存成功后才删除
+	var offset int64
+	first := len(existing)
+	if req.Replace {
+		first = 0
+	} else if len(existing) > 0 {
+		offset = existing[len(existing)-1].EndMs
+	}
+
+	response := GenerateScriptResponse{Title: script.Title, Scenes: []models.Scene{}}
+	for i, s := range script.Scenes {
+		scene := &models.Scene{
+			ProjectID:    project.ID,
+			OrderIndex:   first + i,
+			StartMs:      offset + s.StartMs,
+			EndMs:        offset + s.EndMs,
+			Description:  s.Description,
+			VisualPrompt: s.VisualPrompt,
+			CameraNotes:  s.CameraNotes,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		}
+		if err := config.Storage.CreateScene(scene); err != nil {
+			// 撤销已保存的新镜头，项目保持原状
+			for _, created := range response.Scenes {
+				config.Storage.DeleteScene(created.ID)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save scenes"})
+			return
+		}
+		response.Scenes = append(response.Scenes, *scene)
+	}
+
+	if req.Replace {
+		// 删除镜头会同时清除其图片上的 scene_id，图片本身保留
+		for _, scene := range existing {
+			if err := config.Storage.DeleteScene(scene.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replace scenes"})
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
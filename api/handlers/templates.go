@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+	"ai-design-backend/prompts"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateTemplateRequest struct {
+	Name        string                    `json:"name" binding:"required"`
+	Description string                    `json:"description"`
+	Body        string                    `json:"body" binding:"required"`
+	Variables   []models.TemplateVariable `json:"variables"`
+}
+
+type UpdateTemplateRequest struct {
+	Name        *string                    `json:"name"`
+	Description *string                    `json:"description"`
+	Body        *string                    `json:"body"`
+	Variables   *[]models.TemplateVariable `json:"variables"`
+}
+
+type RenderTemplateRequest struct {
+	Prompt    string            `json:"prompt"`
+	Variables map[string]string `json:"variables"`
+}
+
+// resolveTemplate 按 ID 或名称查找模板：ID 只匹配用户自己的模板，
+// 名称先匹配用户模板再匹配内置预设。找不到时返回 nil。
+func resolveTemplate(userID uuid.UUID, ref string) (*models.PromptTemplate, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		template, err := config.Storage.GetTemplateByID(id)
+		if err != nil || template == nil || template.UserID != userID {
+			return nil, err
+		}
+		return template, nil
+	}
+
+	template, err := config.Storage.GetTemplateByName(userID, ref)
+	if err != nil || template != nil {
+		return template, err
+	}
+	return prompts.Preset(ref), nil
+}
+
+// renderPrompt 渲染生成请求中的模板，写出错误响应时返回 ok=false
+func renderPrompt(c *gin.Context, userID uuid.UUID, ref, prompt string, variables map[string]string) (*models.PromptTemplate, string, bool) {
+	template, err := resolveTemplate(userID, ref)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template"})
+		return nil, "", false
+	}
+	if template == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template not found"})
+		return nil, "", false
+	}
+
+	rendered, err := prompts.Render(template, prompt, variables)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+	return template, rendered, true
+}
+
+// userTemplate 加载当前用户可修改的模板；内置预设返回 403
+func userTemplate(c *gin.Context) (*models.PromptTemplate, bool) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	ref := c.Param("id")
+
+	if _, err := uuid.Parse(ref); err != nil {
+		if prompts.Preset(ref) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "System templates cannot be modified"})
+			return nil, false
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return nil, false
+	}
+
+	template, err := resolveTemplate(userID, ref)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template"})
+		return nil, false
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return nil, false
+	}
+	return template, true
+}
+
+// GetTemplates 内置预设在前，其后是用户自己的模板
+func GetTemplates(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	templates := prompts.Presets()
+	own, err := config.Storage.GetTemplatesByUserID(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+	for _, template := range own {
+		templates = append(templates, *template)
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplate :id 为用户模板的 ID 或模板名称（包括内置预设）
+func GetTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	template, err := resolveTemplate(userID.(uuid.UUID), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template"})
+		return
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func CreateTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req CreateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := &models.PromptTemplate{
+		UserID:      userID.(uuid.UUID),
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Body:        req.Body,
+		Variables:   req.Variables,
+		Version:     1,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if template.Variables == nil {
+		template.Variables = []models.TemplateVariable{}
+	}
+	if err := prompts.Validate(template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := config.Storage.GetTemplateByName(template.UserID, template.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Template name already exists"})
+		return
+	}
+
+	if err := config.Storage.CreateTemplate(template); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateTemplate 修改正文或变量时版本号加一，已生成的图片记录保留当时的版本号
+func UpdateTemplate(c *gin.Context) {
+	template, ok := userTemplate(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := *template
+	if req.Name != nil {
+		updated.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		updated.Description = *req.Description
+	}
+	if req.Body != nil {
+		updated.Body = *req.Body
+	}
+	if req.Variables != nil {
+		updated.Variables = *req.Variables
+	}
+	if err := prompts.Validate(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if updated.Name != template.Name {
+		existing, err := config.Storage.GetTemplateByName(updated.UserID, updated.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+			return
+		}
+		if existing != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Template name already exists"})
+			return
+		}
+	}
+	if req.Body != nil || req.Variables != nil {
+		updated.Version++
+	}
+	updated.UpdatedAt = time.Now()
+
+	if err := config.Storage.UpdateTemplate(&updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func DeleteTemplate(c *gin.Context) {
+	template, ok := userTemplate(c)
+	if !ok {
+		return
+	}
+
+	if err := config.Storage.DeleteTemplate(template.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// RenderTemplate 预览渲染结果，不调用上游
+func RenderTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req RenderTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, rendered, ok := renderPrompt(c, userID.(uuid.UUID), c.Param("id"), req.Prompt, req.Variables)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"template": template.Name,
+		"version":  template.Version,
+		"prompt":   rendered,
+	})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	// 注册 validateImage 读取尺寸所需的解码器
+	_ "image/jpeg"
+	_ "image/png"
+
+	"ai-design-backend/config"
+
+	"github.com/gin-gonic/gin"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	errImageTooLarge    = errors.New("image exceeds maximum size")
+	errUnsupportedImage = errors.New("unsupported image type")
+	errInvalidImage     = errors.New("invalid image data")
+)
+
+// sniffImageType 按文件头识别 PNG、JPEG、WebP，不信任客户端声明的 Content-Type
+func sniffImageType(data []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png", true
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg", true
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "image/webp", true
+	}
+	return "", false
+}
+
+// validateImage 检查大小（MAX_IMAGE_SIZE）、真实类型与文件头声明的尺寸（MAX_IMAGE_PIXELS）
+func validateImage(data []byte) (string, error) {
+	if int64(len(data)) > config.Config.MaxImageSize {
+		return "", errImageTooLarge
+	}
+	contentType, ok := sniffImageType(data)
+	if !ok {
+		return "", errUnsupportedImage
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", errInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return "", errInvalidImage
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > config.Config.MaxImagePixels {
+		return "", errImageTooLarge
+	}
+	return contentType, nil
+}
+
+// readImageUpload 读取并校验 multipart 上传的图片
+func readImageUpload(fh *multipart.FileHeader) ([]byte, string, error) {
+	if fh.Size > config.Config.MaxImageSize {
+		return nil, "", errImageTooLarge
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, config.Config.MaxImageSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	contentType, err := validateImage(data)
+	return data, contentType, err
+}
+
+// decodeInlineImage 解码并校验 JSON 中的 data URL 或裸 Base64 图片
+func decodeInlineImage(src string) ([]byte, string, error) {
+	if strings.HasPrefix(src, "data:") {
+		if i := strings.Index(src, ","); i >= 0 {
+			src = src[i+1:]
+		}
+	}
+	// Base64 每 4 个字符对应 3 个字节，先按长度拒绝明显超限的数据
+	if int64(len(src))/4*3 > config.Config.MaxImageSize+3 {
+		return nil, "", errImageTooLarge
+	}
+	data, err := base64.StdEncoding.DecodeString(src)
+	if err != nil {
+		return nil, "", errInvalidImage
+	}
+	contentType, err := validateImage(data)
+	return data, contentType, err
+}
+
+func isRemoteImage(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+func dataURL(contentType string, data []byte) string {
+	return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data))
+}
+
+// imageInputError 将图片校验错误转换为响应；返回 false 表示不是校验错误
+func imageInputError(c *gin.Context, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errImageTooLarge), errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image exceeds maximum size"})
+	case errors.Is(err, errUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported image type, expected PNG, JPEG or WebP"})
+	case errors.Is(err, errInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image data"})
+	default:
+		return false
+	}
+	return true
+}
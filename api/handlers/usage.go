@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+	"ai-design-backend/providers"
+	"ai-design-backend/storage"
+	"ai-design-backend/usage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// usageCall 构造用量记录，项目与组织用于按客户归集费用
+func usageCall(userID uuid.UUID, project *models.Project, source, model, size string, n int, keySource string) usage.Call {
+	call := usage.Call{
+		UserID:    userID,
+		Source:    source,
+		Model:     model,
+		Size:      size,
+		N:         n,
+		KeySource: keySource,
+	}
+	if provider, err := providers.Default.ForModel(model); err == nil {
+		call.Provider = provider.Name()
+	}
+	if project != nil {
+		call.ProjectID = &project.ID
+		call.OrgID = project.OrgID
+	}
+	return call
+}
+
+// usageError 将配额与额度错误转换为响应
+func usageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, usage.ErrQuotaExceeded):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Monthly image quota exceeded"})
+	case errors.Is(err, usage.ErrInsufficientCredits):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Insufficient credits"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check usage"})
+	}
+}
+
+// meteredGenerate 计量一次生成调用
+func meteredGenerate(ctx context.Context, call usage.Call, prompt string) ([]providers.Output, error) {
+	charge, err := usage.Begin(call)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := generateOutputs(ctx, prompt, call.Model, call.Size, call.N)
+	charge.Finish(len(outputs), err)
+	return outputs, err
+}
+
+// meteredReferenceGenerate 有参考素材时通过图生图接口附带参考图生成，否则等同于 meteredGenerate
+func meteredReferenceGenerate(ctx context.Context, call usage.Call, prompt string, refs *referenceSet) ([]providers.Output, error) {
+	if refs == nil {
+		return meteredGenerate(ctx, call, prompt)
+	}
+	charge, err := usage.Begin(call)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := referenceOutputs(ctx, refs.prompt(prompt), call.Model, call.Size, call.N, refs.Images)
+	charge.Finish(len(outputs), err)
+	return outputs, err
+}
+
+// UsageBucket 一个时间段或项目的用量汇总
+type UsageBucket struct {
+	Key       string     `json:"key"` // 日期（2006-01-02）、月份（2006-01）或项目 ID
+	ProjectID *uuid.UUID `json:"project_id,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Requests  int        `json:"requests"`
+	Failed    int        `json:"failed"`
+	Images    int        `json:"images"`
+	Cost      float64    `json:"cost"`
+	costMicro int64
+}
+
+type UsageResponse struct {
+	From           time.Time     `json:"from"`
+	To             time.Time     `json:"to"`
+	CreditsEnabled bool          `json:"credits_enabled"`
+	Balance        *float64      `json:"balance,omitempty"`
+	MonthlyQuota   int           `json:"monthly_quota"` // 0 表示不限
+	MonthlyUsed    int           `json:"monthly_used"`
+	Total          UsageBucket   `json:"total"`
+	Daily          []UsageBucket `json:"daily"`
+	Monthly        []UsageBucket `json:"monthly"`
+	ByProject      []UsageBucket `json:"by_project"`
+}
+
+func addToBucket(buckets map[string]*UsageBucket, key string, entry *models.UsageEntry) *UsageBucket {
+	bucket, ok := buckets[key]
+	if !ok {
+		bucket = &UsageBucket{Key: key}
+		buckets[key] = bucket
+	}
+	bucket.Requests++
+	if entry.Status == "failed" {
+		bucket.Failed++
+	}
+	bucket.Images += entry.Images
+	bucket.costMicro += entry.CostMicros
+	return bucket
+}
+
+func sortedBuckets(buckets map[string]*UsageBucket) []UsageBucket {
+	list := make([]UsageBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		bucket.Cost = usage.ToAmount(bucket.costMicro)
+		list = append(list, *bucket)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// GetUsage 返回用量的按日、按月与按项目汇总。
+// 个人工作区统计当前用户的全部调用；组织工作区（需要 admin）统计该组织所有成员的调用。
+// ?from=2006-01-02&to=2006-01-02 指定范围（含 to 当天），默认最近 30 天。
+func GetUsage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	orgID, orgMember, ok := workspaceScope(c)
+	if !ok {
+		return
+	}
+	if orgID != nil && !orgRoleAtLeast(orgMember.Role, models.OrgRoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient organization permissions"})
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today.AddDate(0, 0, 1)
+	from := today.AddDate(0, 0, -29)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return
+		}
+		from = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	filter := storage.UsageFilter{Since: from, Until: to}
+	if orgID != nil {
+		filter.OrgID = orgID
+	} else {
+		id := userID.(uuid.UUID)
+		filter.UserID = &id
+	}
+	entries, err := config.Storage.GetUsageEntries(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage"})
+		return
+	}
+
+	daily := map[string]*UsageBucket{}
+	monthly := map[string]*UsageBucket{}
+	byProject := map[string]*UsageBucket{}
+	total := map[string]*UsageBucket{}
+	for _, entry := range entries {
+		created := entry.CreatedAt.UTC()
+		addToBucket(daily, created.Format("2006-01-02"), entry)
+		addToBucket(monthly, created.Format("2006-01"), entry)
+		addToBucket(total, "total", entry)
+
+		key := "none"
+		if entry.ProjectID != nil {
+			key = entry.ProjectID.String()
+		}
+		bucket := addToBucket(byProject, key, entry)
+		if entry.ProjectID != nil && bucket.ProjectID == nil {
+			bucket.ProjectID = entry.ProjectID
+			if project, err := config.Storage.GetProjectByID(*entry.ProjectID); err == nil && project != nil {
+				bucket.Title = project.Title
+			}
+		}
+	}
+
+	response := UsageResponse{
+		From:           from,
+		To:             to,
+		CreditsEnabled: config.Config.CreditsEnabled,
+		Total:          UsageBucket{Key: "total"},
+		Daily:          sortedBuckets(daily),
+		Monthly:        sortedBuckets(monthly),
+		ByProject:      sortedBuckets(byProject),
+	}
+	if totals := sortedBuckets(total); len(totals) > 0 {
+		response.Total = totals[0]
+	}
+
+	// 余额与配额只对个人有意义
+	if orgID == nil {
+		account, err := usage.Account(userID.(uuid.UUID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage"})
+			return
+		}
+		if config.Config.CreditsEnabled {
+			balance := usage.ToAmount(account.CreditMicros)
+			response.Balance = &balance
+		}
+		response.MonthlyQuota = usage.QuotaLimit(account)
+		response.MonthlyUsed, _ = config.Storage.CountImagesSince(account.UserID, usage.MonthStart(time.Now()))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+type AdminUsageRequest struct {
+	AddCredits        *float64 `json:"add_credits"`         // 可以为负数
+	MonthlyImageQuota *int     `json:"monthly_image_quota"` // 0 使用全局配置，-1 不限
+}
+
+// AdminUpdateUsage 管理员为用户充值额度或调整月度配额
+func AdminUpdateUsage(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req AdminUsageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := config.Storage.GetUserByID(targetID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	account, err := usage.Account(targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update usage account"})
+		return
+	}
+
+	if req.MonthlyImageQuota != nil {
+		account.MonthlyImageQuota = *req.MonthlyImageQuota
+		if err := config.Storage.UpdateUsageAccount(account); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update usage account"})
+			return
+		}
+	}
+	if req.AddCredits != nil {
+		delta := int64(math.Round(*req.AddCredits * usage.Micros))
+		if _, err := config.Storage.AdjustCredits(targetID, delta, true); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update usage account"})
+			return
+		}
+	}
+
+	account, err = usage.Account(targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update usage account"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":             account.UserID,
+		"balance":             usage.ToAmount(account.CreditMicros),
+		"monthly_image_quota": account.MonthlyImageQuota,
+	})
+}
+
+type AdminRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+// AdminUpdateRole 管理员授予或撤销其他用户的管理员角色；不能修改自己的角色，避免误操作后无人可以管理
+func AdminUpdateRole(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if targetID == c.MustGet("userID").(uuid.UUID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change your own role"})
+		return
+	}
+
+	var req AdminRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := config.Storage.GetUserByID(targetID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	user.Role = req.Role
+	if err := config.Storage.UpdateUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"log"
+	"sync"
+
+	"ai-design-backend/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventJob   = "job"   // 任务状态或进度变化
+	EventImage = "image" // 某张图片的状态变化（pending -> completed/failed）
+	EventDone  = "done"  // 任务结束，之后不会再有事件
+)
+
+type Event struct {
+	Type  string        `json:"type"`
+	Job   *models.Job   `json:"job,omitempty"`
+	Image *models.Image `json:"image,omitempty"`
+}
+
+// subscriberBuffer 订阅者的事件缓冲，慢消费者超出后事件会被丢弃
+const subscriberBuffer = 64
+
+type broker struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan Event]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{subs: make(map[uuid.UUID]map[chan Event]struct{})}
+}
+
+func (b *broker) subscribe(jobID uuid.UUID) (chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[jobID] == nil {
+		b.subs[jobID] = make(map[chan Event]struct{})
+	}
+	b.subs[jobID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if subs, ok := b.subs[jobID]; ok {
+			if _, ok := subs[ch]; ok {
+				delete(subs, ch)
+				close(ch)
+			}
+			if len(subs) == 0 {
+				delete(b.subs, jobID)
+			}
+		}
+	}
+	return ch, cancel
+}
+
+func (b *broker) publish(jobID uuid.UUID, event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[jobID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("job %s: dropping %s event for slow subscriber", jobID, event.Type)
+		}
+	}
+}
+
+// closeJob 通知所有订阅者任务已结束并关闭通道
+func (b *broker) closeJob(jobID uuid.UUID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[jobID] {
+		close(ch)
+	}
+	delete(b.subs, jobID)
+}
+
+// Subscribe 订阅任务事件。任务结束后通道会被关闭；
+// 调用方不再需要时应调用 cancel。对已结束或不存在的任务返回的通道不会收到事件，
+// 调用方应先订阅再读取任务快照，以免错过两者之间的变化。
+func (q *Queue) Subscribe(jobID uuid.UUID) (<-chan Event, func()) {
+	return q.events.subscribe(jobID)
+}
+
+// PublishImage 为不在队列中跟踪的图片（如 n>1 时同一次生成的其余输出）发布状态事件
+func (q *Queue) PublishImage(jobID uuid.UUID, image *models.Image) {
+	snapshot := *image
+	q.events.publish(jobID, Event{Type: EventImage, Image: &snapshot})
+}
+
+// Running 返回任务是否仍在队列中执行
+func (q *Queue) Running(jobID uuid.UUID) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.trackers[jobID]
+	return ok
+}
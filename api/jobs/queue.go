@@ -0,0 +1,223 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrQueueFull = errors.New("job queue is full")
+
+// RunFunc 负责为一张图片调用上游并填充结果字段（ImageURL 等），
+// 状态与任务进度由队列统一维护。
+type RunFunc func(ctx context.Context, image *models.Image) error
+
+type task struct {
+	job   *tracker
+	image models.Image
+	run   RunFunc
+}
+
+// tracker 保存单个任务的进度，多个 worker 并发更新同一任务时通过它加锁
+type tracker struct {
+	mu   sync.Mutex
+	job  models.Job
+	done chan struct{}
+}
+
+// Queue 有界的 worker 池，按提交顺序执行图片生成
+type Queue struct {
+	tasks    chan task
+	submitMu sync.Mutex
+
+	mu       sync.Mutex
+	trackers map[uuid.UUID]*tracker
+
+	events *broker
+}
+
+var Default *Queue
+
+// Init 创建默认队列并启动 worker
+func Init(workers, size int) {
+	Default = NewQueue(workers, size)
+}
+
+func NewQueue(workers, size int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < workers {
+		size = workers
+	}
+	q := &Queue{
+		tasks:    make(chan task, size),
+		trackers: make(map[uuid.UUID]*tracker),
+		events:   newBroker(),
+	}
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+// Submit 保存任务与待生成的图片记录，并将每张图片放入队列。
+// 队列剩余容量不足以容纳整个任务时返回 ErrQueueFull，不会只提交一部分。
+func (q *Queue) Submit(job *models.Job, images []*models.Image, run RunFunc) error {
+	q.submitMu.Lock()
+	defer q.submitMu.Unlock()
+
+	if cap(q.tasks)-len(q.tasks) < len(images) {
+		return ErrQueueFull
+	}
+
+	now := time.Now()
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
+	job.Status = "queued"
+	job.Total = len(images)
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	if err := config.Storage.CreateJob(job); err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		image.JobID = &job.ID
+		image.Status = "pending"
+		if err := config.Storage.CreateImage(image); err != nil {
+			return err
+		}
+	}
+
+	t := &tracker{job: *job, done: make(chan struct{})}
+	q.mu.Lock()
+	q.trackers[job.ID] = t
+	q.mu.Unlock()
+
+	if len(images) == 0 {
+		q.finish(t)
+		return nil
+	}
+	for _, image := range images {
+		q.tasks <- task{job: t, image: *image, run: run}
+	}
+	return nil
+}
+
+// Wait 阻塞直到任务结束或 ctx 取消
+func (q *Queue) Wait(ctx context.Context, jobID uuid.UUID) error {
+	q.mu.Lock()
+	t, ok := q.trackers[jobID]
+	q.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) worker() {
+	for t := range q.tasks {
+		q.execute(t)
+	}
+}
+
+func (q *Queue) execute(t task) {
+	t.job.mu.Lock()
+	if t.job.job.Status == "queued" {
+		t.job.job.Status = "running"
+		q.saveJob(t.job)
+	}
+	t.job.mu.Unlock()
+
+	// worker 只修改自己的副本，再整体写回存储，避免与读取方竞争
+	image := t.image
+	err := q.runSafely(t.run, &image)
+
+	now := time.Now()
+	image.UpdatedAt = now
+	if err != nil {
+		image.Status = "failed"
+		image.Error = err.Error()
+	} else {
+		image.Status = "completed"
+		image.Error = ""
+		image.GeneratedAt = &now
+	}
+	if updateErr := config.Storage.UpdateImage(&image); updateErr != nil {
+		log.Printf("job %s: failed to update image %s: %v", t.job.job.ID, image.ID, updateErr)
+	}
+	q.events.publish(t.job.job.ID, Event{Type: EventImage, Image: &image})
+
+	t.job.mu.Lock()
+	defer t.job.mu.Unlock()
+	if err != nil {
+		t.job.job.Failed++
+		t.job.job.Error = err.Error()
+	} else {
+		t.job.job.Completed++
+	}
+	if t.job.job.Completed+t.job.job.Failed >= t.job.job.Total {
+		q.finish(t.job)
+		return
+	}
+	q.saveJob(t.job)
+}
+
+// runSafely 防止单个任务的 panic 拖垮整个 worker
+func (q *Queue) runSafely(run RunFunc, image *models.Image) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("job worker panic: %v", r)
+			err = errors.New("internal error while generating image")
+		}
+	}()
+	return run(context.Background(), image)
+}
+
+// finish 计算最终状态；调用方需持有 t.mu（提交空任务时除外）
+func (q *Queue) finish(t *tracker) {
+	switch {
+	case t.job.Failed == 0:
+		t.job.Status = "completed"
+	case t.job.Completed == 0:
+		t.job.Status = "failed"
+	default:
+		t.job.Status = "partial"
+	}
+	now := time.Now()
+	t.job.FinishedAt = &now
+	q.saveJob(t)
+	close(t.done)
+
+	job := t.job
+	q.events.publish(job.ID, Event{Type: EventDone, Job: &job})
+	q.events.closeJob(job.ID)
+
+	q.mu.Lock()
+	delete(q.trackers, t.job.ID)
+	q.mu.Unlock()
+}
+
+func (q *Queue) saveJob(t *tracker) {
+	t.job.UpdatedAt = time.Now()
+	job := t.job
+	if err := config.Storage.UpdateJob(&job); err != nil {
+		log.Printf("job %s: failed to update status: %v", job.ID, err)
+	}
+	q.events.publish(job.ID, Event{Type: EventJob, Job: &job})
+}
@@ -1,8 +1,17 @@
 package main
 
 import (
+	"ai-design-backend/blobstore"
 	"ai-design-backend/config"
+	"ai-design-backend/export"
+	"ai-design-backend/jobs"
+	"ai-design-backend/providers"
+	"ai-design-backend/ratelimit"
 	"ai-design-backend/routes"
+	"ai-design-backend/upstream"
+	"ai-design-backend/usage"
+	"ai-design-backend/utils"
+	"ai-design-backend/vault"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -11,12 +20,44 @@ import (
 func main() {
 	// 初始化配置
 	config.InitConfig()
+
+	// 加载 JWT 签名密钥
+	utils.InitKeys()
 	
 	// 初始化数据库
 	config.InitDB()
+	config.AutoMigrate()
+
+	// 初始化图片 blob 存储
+	blobstore.Init()
+
+	// 上游 HTTP 客户端（连接池、重试与熔断）
+	upstream.Init()
+
+	// 注册图片 provider
+	providers.Init()
+
+	// 初始化用户 API Key 加密
+	vault.Init()
+
+	// 加载价格表
+	usage.Init()
+
+	// 初始化限流存储
+	ratelimit.Init()
+
+	// 加载导出字幕字体
+	export.Init()
+
+	// 启动生成任务队列
+	jobs.Init(config.Config.JobWorkers, config.Config.JobQueueSize)
 	
 	// 创建Gin实例
 	r := gin.Default()
+	// 只采信可信反向代理转发的 X-Forwarded-For，否则客户端可以伪造 IP 绕过按 IP 的限流
+	if err := r.SetTrustedProxies(config.Config.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 	
 	// 配置中间件
 	r.Use(gin.Logger())
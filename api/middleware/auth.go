@@ -4,9 +4,12 @@ import (
 	"net/http"
 	"strings"
 
+	"ai-design-backend/config"
+	"ai-design-backend/models"
 	"ai-design-backend/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 func JWTAuth() gin.HandlerFunc {
@@ -29,9 +32,9 @@ func JWTAuth() gin.HandlerFunc {
 		tokenString := tokenParts[1]
 		
 		// 验证token
-		claims, err := utils.ValidateJWT(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		claims, message := verifyAccessToken(tokenString)
+		if claims == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": message})
 			c.Abort()
 			return
 		}
@@ -39,15 +42,67 @@ func JWTAuth() gin.HandlerFunc {
 		// 将用户信息存入上下文
 		c.Set("userID", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("tokenID", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
+		}
 		c.Next()
 	}
 }
 
+// verifyAccessToken 校验 JWT 签名以及令牌、会话是否已被注销；失败时返回错误信息
+func verifyAccessToken(tokenString string) (*utils.JWTClaims, string) {
+	claims, err := utils.ValidateJWT(tokenString)
+	if err != nil {
+		return nil, "Invalid token"
+	}
+
+	// 检查令牌或其会话是否已被注销
+	if claims.ID != "" {
+		revoked, err := config.Storage.IsAccessTokenRevoked(claims.ID)
+		if err != nil || revoked {
+			return nil, "Token revoked"
+		}
+	}
+	if claims.SessionID != uuid.Nil {
+		session, err := config.Storage.GetSessionByID(claims.SessionID)
+		if err != nil || session == nil || session.RevokedAt != nil {
+			return nil, "Token revoked"
+		}
+	}
+	return claims, ""
+}
+
+// RequireAdmin 只允许角色为 admin 或在 ADMIN_USER_IDS 中的用户访问，需要放在 JWTAuth 之后。
+// 角色每次从数据库读取，撤销后立即生效
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := c.Get("userID")
+		if ok && isAdmin(userID.(uuid.UUID)) {
+			c.Next()
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		c.Abort()
+	}
+}
+
+func isAdmin(userID uuid.UUID) bool {
+	for _, id := range config.Config.AdminUserIDs {
+		if strings.EqualFold(id, userID.String()) {
+			return true
+		}
+	}
+	user, err := config.Storage.GetUserByID(userID)
+	return err == nil && user != nil && user.Role == models.UserRoleAdmin
+}
+
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Workspace-ID")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+	"ai-design-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// looksLikeJWT 判断 token 是否为 JWT 格式；JWT 校验失败时直接拒绝，不会当作上游 key 透传出去
+func looksLikeJWT(token string) bool {
+	return strings.HasPrefix(token, "eyJ") && strings.Count(token, ".") == 2
+}
+
+// ProxyAuth 代理接口的访问策略：
+//   - JWT 或服务签发的 API Key（adk_ 前缀）：认证为用户，可以使用服务端配置的上游 key；
+//   - 其他 Bearer token：视为调用方自己的七牛云 key，只透传给七牛云，不会使用服务端 key；
+//   - 没有 Authorization 头：拒绝。
+func ProxyAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+		if authHeader == "" || token == "" || token == authHeader {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			c.Abort()
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(token, utils.AccessKeyPrefix):
+			key, err := config.Storage.GetAccessKeyByHash(utils.HashToken(token))
+			if err != nil || key == nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+				c.Abort()
+				return
+			}
+			touchAccessKey(key)
+			c.Set("userID", key.UserID)
+			c.Set("accessKeyID", key.ID)
+			c.Set("proxyAuthMode", models.ProxyAuthAccessKey)
+
+		case looksLikeJWT(token):
+			claims, message := verifyAccessToken(token)
+			if claims == nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+				c.Abort()
+				return
+			}
+			c.Set("userID", claims.UserID)
+			c.Set("email", claims.Email)
+			c.Set("proxyAuthMode", models.ProxyAuthJWT)
+
+		default:
+			c.Set("upstreamAPIKey", token)
+			c.Set("proxyAuthMode", models.ProxyAuthPassthrough)
+		}
+
+		c.Next()
+	}
+}
+
+// touchAccessKey 更新最近使用时间，每分钟最多写一次
+func touchAccessKey(key *models.AccessKey) {
+	now := time.Now()
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < time.Minute {
+		return
+	}
+	key.LastUsedAt = &now
+	if err := config.Storage.UpdateAccessKey(key); err != nil {
+		log.Printf("proxy: failed to update access key %s: %v", key.ID, err)
+	}
+}
+
+// ProxyLogger 记录每次代理调用的用户、认证方式、模型、状态码与耗时，
+// 需要放在 ProxyAuth 之前，这样认证失败的请求也会被记录
+func ProxyLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		entry := &models.ProxyLog{
+			AuthMode:   c.GetString("proxyAuthMode"),
+			Method:     c.Request.Method,
+			Path:       c.FullPath(),
+			Model:      c.GetString("proxyModel"),
+			StatusCode: c.Writer.Status(),
+			LatencyMs:  time.Since(start).Milliseconds(),
+			ClientIP:   c.ClientIP(),
+			CreatedAt:  start,
+		}
+		if userID, ok := c.Get("userID"); ok {
+			id := userID.(uuid.UUID)
+			entry.UserID = &id
+		}
+		if keyID, ok := c.Get("accessKeyID"); ok {
+			id := keyID.(uuid.UUID)
+			entry.AccessKeyID = &id
+		}
+		if err := config.Storage.CreateProxyLog(entry); err != nil {
+			log.Printf("proxy: failed to record call: %v", err)
+		}
+	}
+}
@@ -13,6 +13,7 @@ type User struct {
 	Password  string    `json:"-" gorm:"not null"`
 	Avatar    string    `json:"avatar"`
 	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	Role      string    `json:"role" gorm:"not null;default:user"` // user, admin
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	
@@ -21,8 +22,9 @@ type User struct {
 }
 
 type Project struct {
-	ID          uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
-	UserID      uuid.UUID `json:"user_id" gorm:"type:char(36);not null"`
+	ID          uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"type:char(36);not null"`     // 创建者
+	OrgID       *uuid.UUID `json:"org_id,omitempty" gorm:"type:char(36);index"` // 所属组织，为空表示个人项目
 	Title       string    `json:"title" gorm:"not null"`
 	Description string    `json:"description"`
 	Type        string    `json:"type" gorm:"default:'single'"` // single, storyboard
@@ -38,12 +40,23 @@ type Project struct {
 type Image struct {
 	ID          uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
 	ProjectID   uuid.UUID `json:"project_id" gorm:"type:char(36);not null"`
+	UserID      uuid.UUID `json:"user_id" gorm:"type:char(36);index"` // 创建者，不属于项目的图片只有创建者可见
+	ParentImageID *uuid.UUID `json:"parent_image_id,omitempty" gorm:"type:char(36);index"` // 编辑结果的源图片
 	Prompt      string    `json:"prompt" gorm:"type:text"`
 	Model       string    `json:"model"`
 	Size        string    `json:"size"`
 	ImageURL    string    `json:"image_url"`
 	ImageData   string    `json:"image_data" gorm:"type:text"` // Base64 or URL
 	Status      string    `json:"status" gorm:"default:'pending'"` // pending, completed, failed
+	JobID       *uuid.UUID `json:"job_id,omitempty" gorm:"type:char(36);index"`
+	GenerationID *uuid.UUID `json:"generation_id,omitempty" gorm:"type:char(36);index"`
+	Template        string `json:"template,omitempty"`
+	TemplateVersion int    `json:"template_version,omitempty"`
+	OutputIndex int        `json:"output_index"` // 在同一次生成中的序号
+	SceneID     *uuid.UUID `json:"scene_id,omitempty" gorm:"type:char(36);index"` // 为分镜镜头生成的画面
+	BlobKey     string     `json:"blob_key,omitempty" gorm:"index"` // 图片内容在 blob 存储中的 SHA-256
+	ContentType string     `json:"content_type,omitempty"`
+	FileSize    int64      `json:"file_size,omitempty"`
 	Error       string    `json:"error,omitempty"`
 	GeneratedAt *time.Time `json:"generated_at"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -53,6 +66,266 @@ type Image struct {
 	Project Project `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
 }
 
+// 用户的系统角色，admin 可以访问 /admin 接口
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
+// 项目成员角色，权限依次递增
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+// ProjectMember 项目协作成员；项目创建者（Project.UserID）始终是 owner，不在此表中
+type ProjectMember struct {
+	ID        uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	ProjectID uuid.UUID `json:"project_id" gorm:"type:char(36);not null;uniqueIndex:idx_project_member"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:char(36);not null;uniqueIndex:idx_project_member;index"`
+	Role      string    `json:"role" gorm:"not null"`                 // viewer, editor, owner
+	Status    string    `json:"status" gorm:"default:'pending'"`      // pending, accepted
+	InvitedBy uuid.UUID `json:"invited_by" gorm:"type:char(36)"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 组织成员角色
+const (
+	OrgRoleMember = "member"
+	OrgRoleAdmin  = "admin"
+	OrgRoleOwner  = "owner"
+)
+
+// Organization 团队工作区，拥有其下的项目；不同组织之间的数据完全隔离
+type Organization struct {
+	ID        uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	Name      string    `json:"name" gorm:"not null"`
+	CreatedBy uuid.UUID `json:"created_by" gorm:"type:char(36)"`
+	// 组织自己的上游 API Key，组织项目中的生成请求优先使用；由 vault 加密保存，不在接口中返回
+	UpstreamKeyID      string    `json:"-"`
+	UpstreamWrappedKey string    `json:"-" gorm:"type:text"`
+	UpstreamCiphertext string    `json:"-" gorm:"type:text"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// OrgMember 组织成员
+type OrgMember struct {
+	ID        uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	OrgID     uuid.UUID `json:"org_id" gorm:"type:char(36);not null;uniqueIndex:idx_org_member"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:char(36);not null;uniqueIndex:idx_org_member;index"`
+	Role      string    `json:"role" gorm:"not null"` // member, admin, owner
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProviderCredential 用户自己的上游 provider API Key，信封加密后保存，接口只返回掩码
+type ProviderCredential struct {
+	ID         uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	UserID     uuid.UUID `json:"user_id" gorm:"type:char(36);not null;uniqueIndex:idx_user_provider"`
+	Provider   string    `json:"provider" gorm:"not null;uniqueIndex:idx_user_provider"` // qiniu, openai ...
+	Label      string    `json:"label"`
+	MaskedKey  string    `json:"masked_key"`
+	KeyID      string    `json:"-"` // 加密数据密钥所用的主密钥
+	WrappedKey string    `json:"-" gorm:"type:text"`
+	Ciphertext string    `json:"-" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// AccessKey 服务签发给用户的 API Key，可代替 JWT 调用代理接口；只保存哈希
+type AccessKey struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 明文前缀，便于用户辨认
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// 代理接口的认证方式
+const (
+	ProxyAuthJWT         = "jwt"
+	ProxyAuthAccessKey   = "access_key"
+	ProxyAuthPassthrough = "passthrough" // 调用方自带上游 key，不使用服务端 key
+)
+
+// ProxyLog 一次代理调用的记录
+type ProxyLog struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID      *uuid.UUID `json:"user_id,omitempty" gorm:"type:char(36);index"`
+	AccessKeyID *uuid.UUID `json:"access_key_id,omitempty" gorm:"type:char(36)"`
+	AuthMode    string     `json:"auth_mode"`
+	Method      string     `json:"method"`
+	Path        string     `json:"path"`
+	Model       string     `json:"model"`
+	StatusCode  int        `json:"status_code"`
+	LatencyMs   int64      `json:"latency_ms"`
+	ClientIP    string     `json:"client_ip"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"index"`
+}
+
+// 上游 key 来源：只有使用服务端 key 的调用才扣除额度
+const (
+	KeySourceServer = "server"
+	KeySourceUser   = "user"
+	KeySourceOrg    = "org"
+)
+
+// UsageEntry 一次上游调用的用量记录，金额单位为百万分之一（micros）
+type UsageEntry struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	ProjectID  *uuid.UUID `json:"project_id,omitempty" gorm:"type:char(36);index"`
+	OrgID      *uuid.UUID `json:"org_id,omitempty" gorm:"type:char(36);index"`
+	Source     string     `json:"source"` // generate, batch, storyboard, edit, proxy
+	Provider   string     `json:"provider"`
+	Model      string     `json:"model"`
+	Size       string     `json:"size"`
+	N          int        `json:"n"`      // 请求的图片数
+	Images     int        `json:"images"` // 实际返回的图片数
+	Status     string     `json:"status"` // pending, success, failed
+	KeySource  string     `json:"key_source"`
+	CostMicros int64      `json:"cost_micros"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// UsageAccount 用户的额度余额与月度配额
+type UsageAccount struct {
+	UserID       uuid.UUID `json:"user_id" gorm:"type:char(36);primary_key"`
+	CreditMicros int64     `json:"credit_micros"`
+	// 每月可生成的图片数：0 表示使用全局配置，-1 表示不限
+	MonthlyImageQuota int       `json:"monthly_image_quota"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// AuthSession 一次登录会话，同一会话内轮换出的 refresh token 属于同一个家族；
+// 会话被吊销后，其下所有 access token 与 refresh token 都失效
+type AuthSession struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// RefreshToken 只保存令牌的 SHA-256，明文仅在签发时返回一次
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	SessionID  uuid.UUID  `json:"session_id" gorm:"type:char(36);not null;index"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at"`
+	ReplacedBy *uuid.UUID `json:"replaced_by" gorm:"type:char(36)"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RevokedToken 已注销但尚未过期的 access token（按 jti）
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primary_key"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
+
+// Job 一次异步生成任务，包含一张或多张图片
+type Job struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	ProjectID  uuid.UUID  `json:"project_id" gorm:"type:char(36)"`
+	Type       string     `json:"type"` // generate, batch, storyboard
+	Status     string     `json:"status" gorm:"default:'queued'"` // queued, running, completed, partial, failed
+	Total      int        `json:"total"`
+	Completed  int        `json:"completed"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finished_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Generation 一次生成请求的参数，请求返回的每张图片各有一条 Image 记录并通过 GenerationID 关联
+type Generation struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:char(36);primary_key"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:char(36);not null;index"`
+	ProjectID uuid.UUID  `json:"project_id" gorm:"type:char(36);index"`
+	JobID     *uuid.UUID `json:"job_id,omitempty" gorm:"type:char(36)"`
+	Prompt    string     `json:"prompt" gorm:"type:text"`
+	Model     string     `json:"model"`
+	Size      string     `json:"size"`
+	N         int        `json:"n"`
+	Template  string     `json:"template,omitempty"`
+	TemplateVersion int               `json:"template_version,omitempty"`
+	Variables       map[string]string `json:"variables,omitempty" gorm:"serializer:json"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TemplateVariable 模板变量，未提供且没有默认值的必填变量会导致渲染失败
+type TemplateVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptTemplate 用户的提示词模板，Body 为 text/template 语法，
+// 可以引用声明的变量（如 {{.style}}）以及调用方传入的 {{.prompt}}。内容每次修改 Version 加一。
+type PromptTemplate struct {
+	ID          uuid.UUID          `json:"id" gorm:"type:char(36);primary_key"`
+	UserID      uuid.UUID          `json:"user_id" gorm:"type:char(36);not null;uniqueIndex:idx_template_user_name"`
+	Name        string             `json:"name" gorm:"not null;uniqueIndex:idx_template_user_name"`
+	Description string             `json:"description"`
+	Body        string             `json:"body" gorm:"type:text"`
+	Variables   []TemplateVariable `json:"variables" gorm:"serializer:json"`
+	Version     int                `json:"version"`
+	System      bool               `json:"system" gorm:"-"` // 内置预设，不保存在数据库中
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// Scene 分镜项目中的一个镜头，按 OrderIndex 从 0 开始排列
+type Scene struct {
+	ID           uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	ProjectID    uuid.UUID `json:"project_id" gorm:"type:char(36);not null;index"`
+	OrderIndex   int       `json:"order_index"`
+	StartMs      int64     `json:"start_ms"` // 镜头在成片中的时间范围，单位毫秒
+	EndMs        int64     `json:"end_ms"`
+	Description  string    `json:"description" gorm:"type:text"`
+	VisualPrompt string    `json:"visual_prompt" gorm:"type:text"` // 生成画面使用的提示词
+	CameraNotes  string    `json:"camera_notes" gorm:"type:text"`  // 景别、机位、运镜
+	// SelectedImageID 镜头选用的画面，为空表示尚未生成或未选择
+	SelectedImageID *uuid.UUID `json:"selected_image_id,omitempty" gorm:"type:char(36);index"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// 参考素材类型
+const (
+	ReferenceCharacter = "character"
+	ReferenceStyle     = "style"
+	ReferenceLocation  = "location"
+)
+
+// ReferenceAsset 项目的参考素材（角色、风格、场景），批量与分镜生成时作为参考图发送给上游，
+// 使同一项目的画面保持一致；图片内容保存在 ImageID 指向的图片记录中
+type ReferenceAsset struct {
+	ID          uuid.UUID `json:"id" gorm:"type:char(36);primary_key"`
+	ProjectID   uuid.UUID `json:"project_id" gorm:"type:char(36);not null;index"`
+	ImageID     uuid.UUID `json:"image_id" gorm:"type:char(36);not null;index"`
+	Kind        string    `json:"kind" gorm:"not null"` // character, style, location
+	Name        string    `json:"name"`
+	Description string    `json:"description" gorm:"type:text"`
+	CreatedBy   uuid.UUID `json:"created_by" gorm:"type:char(36)"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
 // 在创建前生成UUID
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == uuid.Nil {
@@ -73,4 +346,102 @@ func (i *Image) BeforeCreate(tx *gorm.DB) error {
 		i.ID = uuid.New()
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return nil
+}
+
+func (g *Generation) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return nil
+}
+
+func (s *Scene) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+func (r *ReferenceAsset) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+func (t *PromptTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+func (s *AuthSession) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+func (m *ProjectMember) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+func (m *OrgMember) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+func (p *ProviderCredential) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+func (k *AccessKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
+
+func (l *ProxyLog) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+func (u *UsageEntry) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	return nil
+}
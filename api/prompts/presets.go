@@ -0,0 +1,79 @@
+package prompts
+
+import "ai-design-backend/models"
+
+// presets 内置的系统模板，所有用户可用；用户模板与预设重名时优先使用用户模板
+var presets = []*models.PromptTemplate{
+	{
+		Name:        "cinematic",
+		Description: "电影感画面，适合分镜与海报",
+		Body:        "{{.subject}}, cinematic still, {{.camera}}, {{.lighting}}, {{.style}}{{if .prompt}}, {{.prompt}}{{end}}",
+		Variables: []models.TemplateVariable{
+			{Name: "subject", Description: "画面主体", Required: true},
+			{Name: "style", Description: "整体风格", Default: "film grain, rich color grading"},
+			{Name: "camera", Description: "镜头与景别", Default: "35mm lens, medium shot"},
+			{Name: "lighting", Description: "光线", Default: "dramatic lighting"},
+		},
+	},
+	{
+		Name:        "product",
+		Description: "电商产品图",
+		Body:        "product photo of {{.subject}}, {{.style}}, {{.camera}}, {{.lighting}}{{if .prompt}}, {{.prompt}}{{end}}",
+		Variables: []models.TemplateVariable{
+			{Name: "subject", Description: "产品", Required: true},
+			{Name: "style", Description: "背景与风格", Default: "clean white background, minimal"},
+			{Name: "camera", Description: "机位", Default: "eye-level, centered composition"},
+			{Name: "lighting", Description: "光线", Default: "soft studio lighting"},
+		},
+	},
+	{
+		Name:        "illustration",
+		Description: "扁平插画",
+		Body:        "{{.style}} illustration of {{.subject}}{{if .prompt}}, {{.prompt}}{{end}}, {{.lighting}}",
+		Variables: []models.TemplateVariable{
+			{Name: "subject", Description: "画面主体", Required: true},
+			{Name: "style", Description: "插画风格", Default: "flat vector"},
+			{Name: "lighting", Description: "光线与配色", Default: "soft pastel palette"},
+		},
+	},
+	{
+		Name:        "storyboard",
+		Description: "分镜草图",
+		Body:        "storyboard frame, {{.style}}, {{.camera}}: {{.subject}}{{if .prompt}}. {{.prompt}}{{end}}",
+		Variables: []models.TemplateVariable{
+			{Name: "subject", Description: "镜头内容", Required: true},
+			{Name: "style", Description: "画风", Default: "rough pencil sketch, black and white"},
+			{Name: "camera", Description: "景别", Default: "wide shot"},
+		},
+	},
+}
+
+func init() {
+	for _, p := range presets {
+		p.System = true
+		p.Version = 1
+		if err := Validate(p); err != nil {
+			panic("invalid prompt preset " + p.Name + ": " + err.Error())
+		}
+	}
+}
+
+// Presets 返回内置模板的副本
+func Presets() []models.PromptTemplate {
+	list := make([]models.PromptTemplate, 0, len(presets))
+	for _, p := range presets {
+		list = append(list, *p)
+	}
+	return list
+}
+
+// Preset 按名称查找内置模板
+func Preset(name string) *models.PromptTemplate {
+	for _, p := range presets {
+		if p.Name == name {
+			preset := *p
+			return &preset
+		}
+	}
+	return nil
+}
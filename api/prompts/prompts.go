@@ -0,0 +1,235 @@
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"ai-design-backend/models"
+)
+
+const (
+	// MaxBodyLength 模板正文的长度上限
+	MaxBodyLength = 4000
+	// MaxPromptLength 渲染结果的长度上限，防止模板通过循环等方式产生超长提示词
+	MaxPromptLength = 8000
+	// PromptVariable 调用方传入的 prompt 在模板中的变量名
+	PromptVariable = "prompt"
+	// RenderTimeout 单次渲染的时间上限
+	RenderTimeout = 100 * time.Millisecond
+)
+
+var (
+	ErrUnknownVariable = errors.New("unknown template variable")
+	ErrMissingVariable = errors.New("missing required template variable")
+	ErrPromptTooLong   = errors.New("rendered prompt is too long")
+	ErrRenderTimeout   = errors.New("rendering the template took too long")
+)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,31}$`)
+
+// funcs 模板中可用的函数，只包含无副作用的字符串处理
+var funcs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"default": func(fallback, value string) string {
+		if strings.TrimSpace(value) == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// compile 解析模板正文并拒绝可能无限执行的语法
+func compile(body string) (*template.Template, error) {
+	tpl, err := template.New("prompt").Funcs(funcs).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(tpl.Templates()) > 1 {
+		return nil, errors.New("define and block are not allowed")
+	}
+	if err := checkNode(tpl.Tree.Root); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// checkNode 只允许文本、字段引用、白名单函数与 if，
+// range、with、template 以及变量声明都会被拒绝，保证渲染耗时与正文长度成正比
+func checkNode(node parse.Node) error {
+	switch n := node.(type) {
+	case nil, *parse.TextNode, *parse.CommentNode, *parse.FieldNode, *parse.StringNode:
+		return nil
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkNode(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.ActionNode:
+		return checkNode(n.Pipe)
+	case *parse.IfNode:
+		if err := checkNode(n.Pipe); err != nil {
+			return err
+		}
+		if err := checkNode(n.List); err != nil {
+			return err
+		}
+		return checkNode(n.ElseList)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		if len(n.Decl) > 0 {
+			return errors.New("variables are not allowed")
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkNode(cmd); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkNode(arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.IdentifierNode:
+		if _, ok := funcs[n.Ident]; !ok {
+			return fmt.Errorf("function %q is not allowed", n.Ident)
+		}
+		return nil
+	case *parse.RangeNode:
+		return errors.New("range is not allowed")
+	case *parse.WithNode:
+		return errors.New("with is not allowed")
+	case *parse.TemplateNode:
+		return errors.New("template is not allowed")
+	}
+	return fmt.Errorf("unsupported template syntax %q", node.String())
+}
+
+// limitedWriter 超过长度上限或渲染超时时返回错误，终止模板执行
+type limitedWriter struct {
+	ctx context.Context
+	buf bytes.Buffer
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.ctx.Err() != nil {
+		return 0, ErrRenderTimeout
+	}
+	if w.buf.Len()+len(p) > MaxPromptLength {
+		return 0, ErrPromptTooLong
+	}
+	return w.buf.Write(p)
+}
+
+func execute(body string, data map[string]string) (string, error) {
+	tpl, err := compile(body)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), RenderTimeout)
+	defer cancel()
+	w := &limitedWriter{ctx: ctx}
+	done := make(chan error, 1)
+	go func() {
+		done <- tpl.Execute(w, data)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// 超时后执行中的模板会在下一次写入时终止
+		return "", ErrRenderTimeout
+	}
+	if err != nil {
+		for _, target := range []error{ErrPromptTooLong, ErrRenderTimeout} {
+			if errors.Is(err, target) {
+				return "", target
+			}
+		}
+		return "", err
+	}
+	return strings.TrimSpace(w.buf.String()), nil
+}
+
+// Validate 检查模板名称、变量声明与正文语法，并用示例值试渲染一次，
+// 确保正文只引用了声明过的变量
+func Validate(t *models.PromptTemplate) error {
+	if strings.TrimSpace(t.Name) == "" {
+		return errors.New("name is required")
+	}
+	if strings.TrimSpace(t.Body) == "" {
+		return errors.New("body is required")
+	}
+	if len(t.Body) > MaxBodyLength {
+		return fmt.Errorf("body must be at most %d characters", MaxBodyLength)
+	}
+
+	sample := map[string]string{PromptVariable: "sample prompt"}
+	for _, v := range t.Variables {
+		if !variableName.MatchString(v.Name) || v.Name == PromptVariable {
+			return fmt.Errorf("invalid variable name %q", v.Name)
+		}
+		if _, dup := sample[v.Name]; dup {
+			return fmt.Errorf("duplicate variable %q", v.Name)
+		}
+		sample[v.Name] = "sample"
+	}
+
+	if _, err := execute(t.Body, sample); err != nil {
+		return fmt.Errorf("invalid template body: %w", err)
+	}
+	return nil
+}
+
+// Render 用变量值渲染模板：未提供的变量使用默认值，未声明的变量与缺失的必填变量返回错误
+func Render(t *models.PromptTemplate, prompt string, values map[string]string) (string, error) {
+	declared := make(map[string]bool, len(t.Variables))
+	for _, v := range t.Variables {
+		declared[v.Name] = true
+	}
+	for name := range values {
+		if !declared[name] {
+			return "", fmt.Errorf("%w %q", ErrUnknownVariable, name)
+		}
+	}
+
+	data := map[string]string{PromptVariable: prompt}
+	for _, v := range t.Variables {
+		value, ok := values[v.Name]
+		if !ok || strings.TrimSpace(value) == "" {
+			value = v.Default
+		}
+		if v.Required && strings.TrimSpace(value) == "" {
+			return "", fmt.Errorf("%w %q", ErrMissingVariable, v.Name)
+		}
+		data[v.Name] = value
+	}
+
+	rendered, err := execute(t.Body, data)
+	if err != nil {
+		return "", err
+	}
+	if rendered == "" {
+		return "", errors.New("rendered prompt is empty")
+	}
+	return rendered, nil
+}
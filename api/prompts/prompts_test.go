@@ -0,0 +1,67 @@
+package prompts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ai-design-backend/models"
+)
+
+func TestValidateAllowsFieldsFuncsAndIf(t *testing.T) {
+	for _, p := range Presets() {
+		if err := Validate(&p); err != nil {
+			t.Errorf("preset %s: %v", p.Name, err)
+		}
+	}
+
+	tpl := &models.PromptTemplate{
+		Name:      "ok",
+		Body:      `{{.subject | upper}}, {{if .prompt}}{{trim .prompt}}{{else if .style}}{{.style}}{{else}}plain{{end}}, {{default "soft" (lower .style)}}`,
+		Variables: []models.TemplateVariable{{Name: "subject"}, {Name: "style"}},
+	}
+	if err := Validate(tpl); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Render(tpl, "", map[string]string{"subject": "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "CAT, plain, soft"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateRejectsUnboundedSyntax(t *testing.T) {
+	bodies := map[string]string{
+		"range":    `{{range 300000000}}{{end}}x`,
+		"with":     `{{with .prompt}}{{.}}{{end}}`,
+		"template": `{{define "a"}}x{{end}}{{template "a"}}`,
+		"block":    `{{block "a" .}}x{{end}}`,
+		"variable": `{{$x := .prompt}}{{$x}}`,
+		"builtin":  `{{printf "%s" .prompt}}`,
+		"dot":      `{{.}}`,
+	}
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			err := Validate(&models.PromptTemplate{Name: name, Body: body})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if time.Since(start) > RenderTimeout {
+				t.Fatalf("validation took %v", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestLimitedWriterStopsAfterDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := &limitedWriter{ctx: ctx}
+	if _, err := w.Write([]byte("x")); !errors.Is(err, ErrRenderTimeout) {
+		t.Fatalf("got %v, want ErrRenderTimeout", err)
+	}
+}
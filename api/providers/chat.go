@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ai-design-backend/upstream"
+)
+
+var ErrNoChatProvider = errors.New("no chat provider configured")
+
+type ChatMessage struct {
+	Role    string `json:"role"` // system, user, assistant
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	Model       string
+	Messages    []ChatMessage
+	Temperature float64
+	// JSONMode 要求上游以 JSON 对象回复（response_format: json_object），不支持的上游会忽略
+	JSONMode bool
+}
+
+// ChatProvider 文本模型后端，用于分镜脚本等结构化文本生成
+type ChatProvider interface {
+	Name() string
+	// Chat 返回助手回复的文本内容
+	Chat(ctx context.Context, req ChatRequest) (string, error)
+}
+
+// Chat 全局文本 provider，由 Init 根据 SCRIPT_PROVIDER 选择
+var Chat ChatProvider
+
+type chatResponse struct {
+	Choices []struct {
+		Message ChatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// Chat 调用 OpenAI 风格的 /chat/completions 接口
+func (p *OpenAICompatible) Chat(ctx context.Context, req ChatRequest) (string, error) {
+	payload := map[string]interface{}{
+		"model":    req.Model,
+		"messages": req.Messages,
+	}
+	if req.Temperature > 0 {
+		payload["temperature"] = req.Temperature
+	}
+	if req.JSONMode {
+		payload["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(jsonData))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if key := apiKeyFrom(ctx, p.apiKey); key != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := upstream.Default.Do(p.name, httpReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", newUpstreamError(p.name, resp, raw)
+	}
+
+	var result chatResponse
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("decode %s chat response: %w", p.name, err)
+	}
+	if len(result.Choices) == 0 || strings.TrimSpace(result.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("%s returned an empty chat response", p.name)
+	}
+	return result.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,148 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Mock 本地占位图 provider：相同输入总是得到相同的 PNG，便于离线开发与测试
+type Mock struct {
+	models []string
+}
+
+func NewMock(models ...string) *Mock {
+	return &Mock{models: models}
+}
+
+func (m *Mock) Name() string {
+	return "mock"
+}
+
+func (m *Mock) Generate(ctx context.Context, req GenerateRequest) ([]Output, error) {
+	return m.render(req.Model, req.Prompt, req.Size, req.N, "")
+}
+
+func (m *Mock) Edit(ctx context.Context, req EditRequest) ([]Output, error) {
+	source := req.Image + strings.Join(req.Images, "|")
+	if source == "" {
+		return nil, fmt.Errorf("image is required")
+	}
+	return m.render(req.Model, req.Prompt, req.Size, req.N, source)
+}
+
+func (m *Mock) ListModels(ctx context.Context) ([]Model, error) {
+	list := make([]Model, 0, len(m.models))
+	for _, id := range m.models {
+		list = append(list, Model{ID: id, Object: "model", OwnedBy: "mock", Provider: m.Name()})
+	}
+	return list, nil
+}
+
+func (m *Mock) render(model, prompt, size string, n int, source string) ([]Output, error) {
+	if n <= 0 {
+		n = 1
+	}
+	width, height := parseSize(size)
+
+	outputs := make([]Output, 0, n)
+	for i := 0; i < n; i++ {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s|%s|%s|%d|%s", model, prompt, size, i, source)
+		data, err := placeholderPNG(width, height, h.Sum64())
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, Output{B64JSON: base64.StdEncoding.EncodeToString(data)})
+	}
+	return outputs, nil
+}
+
+// parseSize 解析 "1024x1024"，占位图最大 1024，解析失败时为 512
+func parseSize(size string) (int, int) {
+	clamp := func(v int) int {
+		if v < 16 {
+			return 16
+		}
+		if v > 1024 {
+			return 1024
+		}
+		return v
+	}
+
+	parts := strings.SplitN(strings.ToLower(size), "x", 2)
+	if len(parts) == 2 {
+		w, errW := strconv.Atoi(strings.TrimSpace(parts[0]))
+		h, errH := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if errW == nil && errH == nil {
+			return clamp(w), clamp(h)
+		}
+	}
+	return 512, 512
+}
+
+// placeholderPNG 由种子决定的双色渐变加棋盘格
+func placeholderPNG(width, height int, seed uint64) ([]byte, error) {
+	from := color.RGBA{uint8(seed), uint8(seed >> 8), uint8(seed >> 16), 255}
+	to := color.RGBA{uint8(seed >> 24), uint8(seed >> 32), uint8(seed >> 40), 255}
+	cell := 16 + int(seed>>48)%48
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		t := float64(y) / float64(height)
+		row := color.RGBA{
+			R: uint8(float64(from.R)*(1-t) + float64(to.R)*t),
+			G: uint8(float64(from.G)*(1-t) + float64(to.G)*t),
+			B: uint8(float64(from.B)*(1-t) + float64(to.B)*t),
+			A: 255,
+		}
+		for x := 0; x < width; x++ {
+			c := row
+			if (x/cell+y/cell)%2 == 0 {
+				c.R, c.G, c.B = c.R/2+64, c.G/2+64, c.B/2+64
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mockScripts 预置的分镜脚本，刻意混用不同的字段写法，用于覆盖脚本解析与修复逻辑
+var mockScripts = []string{
+	`{"title": "Mixtape", "scenes": [
+  {"start_ms": 0, "end_ms": 5000, "description": "She found his voice in a shoebox", "visual_prompt": "an old Converse shoebox opens on a bedroom floor", "camera_notes": "static shot"},
+  {"start_ms": 5000, "end_ms": 10000, "description": "Some memories don't fade", "visual_prompt": "a woman slips a cassette tape into a boombox", "camera_notes": "close-up"},
+  {"start_ms": 10000, "end_ms": 15000, "description": "Her name is Jaya", "visual_prompt": "a woman walks through an old colony neighborhood", "camera_notes": "tracking shot"},
+  {"start_ms": 15000, "end_ms": 20000, "description": "They used to trade mixtapes", "visual_prompt": "two teenagers at a bus stop sharing headphones", "camera_notes": "medium shot, warm flashback tones"},
+  {"start_ms": 20000, "end_ms": 25000, "description": "Then life got louder", "visual_prompt": "a crowded college hostel corridor", "camera_notes": "wide shot"}
+]}`,
+	"```json\n" + `{"scenes": [
+  {"time": "[0-6s]", "description": "Opening", "visual": "a lighthouse at dawn, mist over the sea", "camera": "aerial wide shot"},
+  {"time": "[6-12s]", "description": "Development", "visual": "the keeper climbs the spiral stairs", "camera": "low angle"},
+  {"time": "[12-18s]", "description": "Climax", "visual": "a storm hits, the lamp flickers", "camera": "handheld close-up"},
+  {"time": "[18-24s]", "description": "Resolution", "visual": "a ship passes safely in the beam", "camera": "long shot"},
+  {"time": "[24-30s]", "description": "Ending", "visual": "calm sea under a clear morning sky", "camera": "slow pull back"}
+]}` + "\n```",
+}
+
+// Chat 按最后一条消息选择一份预置脚本，相同输入总是得到相同结果
+func (m *Mock) Chat(ctx context.Context, req ChatRequest) (string, error) {
+	if len(req.Messages) == 0 {
+		return "", fmt.Errorf("messages are required")
+	}
+	h := fnv.New32a()
+	h.Write([]byte(req.Messages[len(req.Messages)-1].Content))
+	return mockScripts[int(h.Sum32()%uint32(len(mockScripts)))], nil
+}
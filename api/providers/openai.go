@@ -0,0 +1,300 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"ai-design-backend/upstream"
+)
+
+// maxImageDownload 拉取远端图片时的大小上限
+const maxImageDownload = 50 << 20
+
+// OpenAICompatible 调用 OpenAI 风格的 /images/generations、/images/edits、/models 接口。
+// 七牛云 QNAIGC 也是这种接口，区别在于其 edits 接收 JSON 而不是 multipart。
+type OpenAICompatible struct {
+	name    string
+	baseURL string
+	apiKey  string
+	// multipartEdits 为 true 时按 OpenAI 规范以 multipart/form-data 上传图片
+	multipartEdits bool
+}
+
+func NewOpenAICompatible(name, baseURL, apiKey string) *OpenAICompatible {
+	return &OpenAICompatible{
+		name:           name,
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		apiKey:         apiKey,
+		multipartEdits: true,
+	}
+}
+
+// NewQiniu 七牛云 AI 图片接口
+func NewQiniu(baseURL, apiKey string) *OpenAICompatible {
+	p := NewOpenAICompatible("qiniu", baseURL, apiKey)
+	p.multipartEdits = false
+	return p
+}
+
+func (p *OpenAICompatible) Name() string {
+	return p.name
+}
+
+// imagesResponse 兼容七牛云的 images 字段与 OpenAI 的 data 字段
+type imagesResponse struct {
+	Images  []Output `json:"images"`
+	Data    []Output `json:"data"`
+	Created int      `json:"created"`
+	Model   string   `json:"model"`
+}
+
+func (p *OpenAICompatible) Generate(ctx context.Context, req GenerateRequest) ([]Output, error) {
+	payload := map[string]interface{}{}
+	for k, v := range req.Extra {
+		payload[k] = v
+	}
+	payload["model"] = req.Model
+	payload["prompt"] = req.Prompt
+	if req.Size != "" {
+		payload["size"] = req.Size
+	}
+	if req.N > 0 {
+		payload["n"] = req.N
+	}
+	payload["response_format"] = "url"
+	if req.ResponseFormat != "" {
+		payload["response_format"] = req.ResponseFormat
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return p.postImages(ctx, "/images/generations", "application/json", jsonData)
+}
+
+func (p *OpenAICompatible) Edit(ctx context.Context, req EditRequest) ([]Output, error) {
+	if p.multipartEdits {
+		body, contentType, err := p.multipartEditBody(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return p.postImages(ctx, "/images/edits", contentType, body)
+	}
+
+	payload := map[string]interface{}{}
+	for k, v := range req.Extra {
+		payload[k] = v
+	}
+	payload["model"] = req.Model
+	payload["prompt"] = req.Prompt
+	if req.Size != "" {
+		payload["size"] = req.Size
+	}
+	payload["n"] = 1
+	if req.N > 0 {
+		payload["n"] = req.N
+	}
+	if len(req.Images) > 0 {
+		payload["image"] = req.Images
+	} else {
+		payload["image"] = req.Image
+	}
+	if req.Mask != "" {
+		payload["mask"] = req.Mask
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return p.postImages(ctx, "/images/edits", "application/json", jsonData)
+}
+
+func (p *OpenAICompatible) multipartEditBody(ctx context.Context, req EditRequest) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	fields := map[string]string{"model": req.Model, "prompt": req.Prompt, "size": req.Size}
+	if req.N > 0 {
+		fields["n"] = fmt.Sprint(req.N)
+	}
+	for k, v := range req.Extra {
+		fields[k] = fmt.Sprint(v)
+	}
+	for k, v := range fields {
+		if v != "" {
+			w.WriteField(k, v)
+		}
+	}
+
+	sources := req.Images
+	if len(sources) == 0 {
+		sources = []string{req.Image}
+	}
+	field := "image"
+	if len(sources) > 1 {
+		field = "image[]"
+	}
+	for i, src := range sources {
+		if err := writeImagePart(ctx, w, field, fmt.Sprintf("image-%d", i), src); err != nil {
+			return nil, "", err
+		}
+	}
+	if req.Mask != "" {
+		if err := writeImagePart(ctx, w, "mask", "mask", req.Mask); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+func writeImagePart(ctx context.Context, w *multipart.Writer, field, name, src string) error {
+	data, contentType, err := LoadImageSource(ctx, src)
+	if err != nil {
+		return err
+	}
+	h := make(textproto.MIMEHeader)
+	ext := strings.TrimPrefix(contentType, "image/")
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename="%s.%s"`, field, name, ext))
+	h.Set("Content-Type", contentType)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(data)
+	return err
+}
+
+// LoadImageSource 将 URL、data URL 或裸 Base64 解析为图片字节；URL 通过 upstream.Fetch 拉取，不会访问内网地址
+func LoadImageSource(ctx context.Context, src string) ([]byte, string, error) {
+	switch {
+	case src == "":
+		return nil, "", errors.New("image is required")
+	case strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		resp, err := upstream.Fetch.Do("", req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", fmt.Errorf("fetch image: unexpected status %d", resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageDownload+1))
+		if err != nil {
+			return nil, "", err
+		}
+		if len(data) > maxImageDownload {
+			return nil, "", errors.New("fetch image: image too large")
+		}
+		return data, http.DetectContentType(data), nil
+	default:
+		if strings.HasPrefix(src, "data:") {
+			if i := strings.Index(src, ","); i >= 0 {
+				src = src[i+1:]
+			}
+		}
+		data, err := base64.StdEncoding.DecodeString(src)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid base64 image: %w", err)
+		}
+		return data, http.DetectContentType(data), nil
+	}
+}
+
+func (p *OpenAICompatible) postImages(ctx context.Context, path, contentType string, body []byte) ([]Output, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if key := apiKeyFrom(ctx, p.apiKey); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := upstream.Default.Do(p.name, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newUpstreamError(p.name, resp, raw)
+	}
+
+	var result imagesResponse
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("decode %s response: %w", p.name, err)
+	}
+
+	src := result.Images
+	if len(src) == 0 {
+		src = result.Data
+	}
+	var outputs []Output
+	for _, img := range src {
+		if img.URL != "" || img.B64JSON != "" {
+			outputs = append(outputs, img)
+		}
+	}
+	return outputs, nil
+}
+
+func (p *OpenAICompatible) ListModels(ctx context.Context) ([]Model, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	if key := apiKeyFrom(ctx, p.apiKey); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := upstream.Default.Do(p.name, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newUpstreamError(p.name, resp, raw)
+	}
+
+	var result struct {
+		Data []Model `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("decode %s models: %w", p.name, err)
+	}
+	for i := range result.Data {
+		result.Data[i].Provider = p.name
+		if result.Data[i].Object == "" {
+			result.Data[i].Object = "model"
+		}
+	}
+	return result.Data, nil
+}
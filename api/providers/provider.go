@@ -0,0 +1,217 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-design-backend/upstream"
+)
+
+// Output 上游返回的一张图片，URL 与 B64JSON 二选一
+type Output struct {
+	URL     string `json:"url,omitempty"`
+	B64JSON string `json:"b64_json,omitempty"`
+}
+
+// String 返回可直接给前端使用的地址，Base64 数据转换为 data URL
+func (o Output) String() string {
+	if o.URL != "" {
+		return o.URL
+	}
+	if o.B64JSON == "" {
+		return ""
+	}
+	contentType := "image/png"
+	if head, err := base64.StdEncoding.DecodeString(prefix(o.B64JSON, 64)); err == nil {
+		if sniffed := http.DetectContentType(head); strings.HasPrefix(sniffed, "image/") {
+			contentType = sniffed
+		}
+	}
+	return fmt.Sprintf("data:%s;base64,%s", contentType, o.B64JSON)
+}
+
+// prefix 截取 Base64 前缀用于类型探测，长度保持为 4 的倍数
+func prefix(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+type GenerateRequest struct {
+	Model          string
+	Prompt         string
+	Size           string
+	N              int
+	ResponseFormat string
+	// Extra 透传给上游的其他字段（如 quality、style）
+	Extra map[string]interface{}
+}
+
+type EditRequest struct {
+	Model  string
+	Prompt string
+	Size   string
+	N      int
+	// Image 为 URL、data URL 或裸 Base64；Images 用于多张参考图
+	Image  string
+	Images []string
+	Mask   string
+	Extra  map[string]interface{}
+}
+
+type Model struct {
+	ID       string `json:"id"`
+	Object   string `json:"object"`
+	OwnedBy  string `json:"owned_by,omitempty"`
+	Provider string `json:"provider"`
+}
+
+// ImageProvider 图片生成后端的统一接口
+type ImageProvider interface {
+	Name() string
+	Generate(ctx context.Context, req GenerateRequest) ([]Output, error)
+	Edit(ctx context.Context, req EditRequest) ([]Output, error)
+	ListModels(ctx context.Context) ([]Model, error)
+}
+
+// UpstreamError 上游返回了非 2xx 响应，代理接口据此原样返回状态码与响应体
+type UpstreamError struct {
+	Provider   string
+	StatusCode int
+	Body       []byte
+	RetryAfter time.Duration // 上游通过 Retry-After 要求的等待时间
+}
+
+func newUpstreamError(provider string, resp *http.Response, body []byte) *UpstreamError {
+	e := &UpstreamError{Provider: provider, StatusCode: resp.StatusCode, Body: body}
+	e.RetryAfter, _ = upstream.RetryAfter(resp.Header)
+	return e
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("API error: %s", string(e.Body))
+}
+
+var ErrNoProvider = errors.New("no image provider configured")
+
+type apiKeyContextKey struct{}
+
+// WithAPIKey 让本次调用使用指定的上游 API Key，而不是 provider 自身配置的 key
+func WithAPIKey(ctx context.Context, apiKey string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, apiKey)
+}
+
+func apiKeyFrom(ctx context.Context, fallback string) string {
+	if key, ok := ctx.Value(apiKeyContextKey{}).(string); ok && key != "" {
+		return key
+	}
+	return fallback
+}
+
+// Registry 按模型ID选择 provider，未注册的模型交给默认 provider
+type Registry struct {
+	mu          sync.RWMutex
+	providers   map[string]ImageProvider
+	models      map[string]string
+	defaultName string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[string]ImageProvider),
+		models:    make(map[string]string),
+	}
+}
+
+// Register 注册 provider 以及它负责的模型
+func (r *Registry) Register(p ImageProvider, models ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.providers[p.Name()] = p
+	for _, m := range models {
+		if m = strings.TrimSpace(m); m != "" {
+			r.models[m] = p.Name()
+		}
+	}
+}
+
+func (r *Registry) SetDefault(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.providers[name]; !ok {
+		return fmt.Errorf("unknown image provider %q", name)
+	}
+	r.defaultName = name
+	return nil
+}
+
+func (r *Registry) Get(name string) ImageProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.providers[name]
+}
+
+func (r *Registry) Default() ImageProvider {
+	return r.Get(r.defaultProviderName())
+}
+
+func (r *Registry) defaultProviderName() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultName
+}
+
+// ForModel 返回负责该模型的 provider
+func (r *Registry) ForModel(model string) (ImageProvider, error) {
+	r.mu.RLock()
+	name, ok := r.models[model]
+	if !ok {
+		name = r.defaultName
+	}
+	p := r.providers[name]
+	r.mu.RUnlock()
+
+	if p == nil {
+		return nil, ErrNoProvider
+	}
+	return p, nil
+}
+
+// ListModels 汇总默认 provider 的远端模型列表与其他 provider 显式注册的模型
+func (r *Registry) ListModels(ctx context.Context) ([]Model, error) {
+	var list []Model
+	seen := make(map[string]bool)
+
+	if p := r.Default(); p != nil {
+		remote, err := p.ListModels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range remote {
+			seen[m.ID] = true
+			list = append(list, m)
+		}
+	}
+
+	r.mu.RLock()
+	var extra []Model
+	for id, name := range r.models {
+		if !seen[id] {
+			extra = append(extra, Model{ID: id, Object: "model", OwnedBy: name, Provider: name})
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(extra, func(i, j int) bool { return extra[i].ID < extra[j].ID })
+	return append(list, extra...), nil
+}
@@ -0,0 +1,42 @@
+package providers
+
+import (
+	"log"
+
+	"ai-design-backend/config"
+	"ai-design-backend/upstream"
+)
+
+// Default 全局 provider 注册表，由 Init 根据配置创建
+var Default *Registry
+
+func Init() {
+	cfg := config.Config
+	r := NewRegistry()
+
+	qiniu := NewQiniu(cfg.QiniuBaseURL, cfg.QiniuAPIKey)
+	r.Register(qiniu)
+	upstream.Default.Track("qiniu")
+	if cfg.OpenAIAPIKey != "" {
+		r.Register(NewOpenAICompatible("openai", cfg.OpenAIBaseURL, cfg.OpenAIAPIKey), cfg.OpenAIImageModels...)
+		upstream.Default.Track("openai")
+	}
+	mock := NewMock(cfg.MockImageModels...)
+	r.Register(mock, cfg.MockImageModels...)
+
+	if err := r.SetDefault(cfg.ImageProvider); err != nil {
+		log.Fatal("Failed to configure image provider:", err)
+	}
+	Default = r
+	log.Printf("Using %s as default image provider", cfg.ImageProvider)
+
+	// 分镜脚本使用七牛云的 chat/completions 接口，mock 返回预置脚本
+	switch cfg.ScriptProvider {
+	case "qiniu":
+		Chat = qiniu
+	case "mock":
+		Chat = mock
+	default:
+		log.Fatalf("Failed to configure script provider: unknown provider %q", cfg.ScriptProvider)
+	}
+}
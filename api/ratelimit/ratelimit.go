@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai-design-backend/config"
+)
+
+// Limit 令牌桶参数：桶容量为 Burst，每个 Period 补充 Burst 个令牌
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Disabled 为 true 时不限流
+func (l Limit) Disabled() bool {
+	return l.Burst <= 0 || l.Period <= 0
+}
+
+// interval 补充一个令牌所需的时间
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Burst)
+}
+
+// Result 一次取令牌的结果
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // 令牌桶补满所需时间
+	RetryAfter time.Duration // 被拒绝时距离下一个可用令牌的时间
+}
+
+// Backend 令牌桶的存储。内存实现只在单实例内生效，
+// 多实例部署时可以用 Redis 等共享存储实现该接口。
+type Backend interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+var Default Backend
+
+func Init() {
+	switch config.Config.RateLimitBackend {
+	case "memory", "":
+		Default = NewMemoryBackend()
+	default:
+		log.Fatalf("Unknown RATE_LIMIT_BACKEND %q (expected memory)", config.Config.RateLimitBackend)
+	}
+}
+
+var periods = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+	"h": time.Hour, "hour": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour,
+}
+
+// ParseLimit 解析 "10/m"、"100/h"、"5/30s" 形式的限制；空字符串、"0" 或 "off" 表示不限流
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" || s == "0" || s == "off" {
+		return Limit{}, nil
+	}
+
+	count, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+	burst, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || burst < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+
+	unit = strings.TrimSpace(unit)
+	period, ok := periods[unit]
+	if !ok {
+		if period, err = time.ParseDuration(unit); err != nil || period <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+		}
+	}
+	return Limit{Burst: burst, Period: period}, nil
+}
+
+// MustParseLimit 用于启动时解析配置，格式错误直接退出
+func MustParseLimit(name, s string) Limit {
+	limit, err := ParseLimit(s)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return limit
+}
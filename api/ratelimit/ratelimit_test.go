@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+	}{
+		{"10/m", Limit{10, time.Minute}},
+		{" 100 / Hour ", Limit{100, time.Hour}},
+		{"5/30s", Limit{5, 30 * time.Second}},
+		{"2/day", Limit{2, 24 * time.Hour}},
+		{"", Limit{}},
+		{"0", Limit{}},
+		{"off", Limit{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"10", "x/m", "-1/m", "10/week", "10/-5s", "10/0s"} {
+		if _, err := ParseLimit(bad); err == nil {
+			t.Errorf("ParseLimit(%q) succeeded, want error", bad)
+		}
+	}
+	if !(Limit{}).Disabled() || !(Limit{Burst: 0, Period: time.Minute}).Disabled() || (Limit{1, time.Second}).Disabled() {
+		t.Error("Disabled reports the wrong state")
+	}
+}
+
+// clock 可手动推进的时间
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBackend() (*MemoryBackend, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewMemoryBackend()
+	m.now = c.now
+	return m, c
+}
+
+func TestMemoryBackendTake(t *testing.T) {
+	m, c := newTestBackend()
+	ctx := context.Background()
+	limit := Limit{Burst: 3, Period: 3 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		res, _ := m.Take(ctx, "a", limit)
+		if !res.Allowed || res.Remaining != 2-i || res.Limit != 3 {
+			t.Fatalf("take %d = %+v", i, res)
+		}
+	}
+	res, _ := m.Take(ctx, "a", limit)
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Fatalf("take on empty bucket = %+v", res)
+	}
+	// 其他 key 互不影响
+	if res, _ := m.Take(ctx, "b", limit); !res.Allowed {
+		t.Fatalf("other key = %+v", res)
+	}
+
+	// 每秒补充一个令牌
+	c.advance(500 * time.Millisecond)
+	if res, _ := m.Take(ctx, "a", limit); res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("take after 0.5s = %+v", res)
+	}
+	c.advance(500 * time.Millisecond)
+	if res, _ := m.Take(ctx, "a", limit); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("take after 1s = %+v", res)
+	}
+
+	// 补满后不会超过桶容量
+	c.advance(time.Hour)
+	if res, _ := m.Take(ctx, "a", limit); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("take after refill = %+v", res)
+	}
+
+	if res, _ := m.Take(ctx, "a", Limit{}); !res.Allowed {
+		t.Fatalf("disabled limit = %+v", res)
+	}
+}
+
+func TestMemoryBackendSweep(t *testing.T) {
+	m, c := newTestBackend()
+	ctx := context.Background()
+	limit := Limit{Burst: 1, Period: time.Second}
+
+	m.Take(ctx, "idle", limit)
+	c.advance(2 * time.Minute)
+	m.Take(ctx, "busy", limit)
+	if _, ok := m.buckets["idle"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+	if _, ok := m.buckets["busy"]; !ok {
+		t.Error("active bucket was swept")
+	}
+}
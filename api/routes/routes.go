@@ -1,33 +1,68 @@
 package routes
 
 import (
+	"ai-design-backend/config"
 	"ai-design-backend/handlers"
 	"ai-design-backend/middleware"
+	"ai-design-backend/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(router *gin.Engine) {
+    // 各路由组的限流配置
+    authLimit := ratelimit.MustParseLimit("RATE_LIMIT_AUTH", config.Config.RateLimitAuth)
+    apiLimit := ratelimit.MustParseLimit("RATE_LIMIT_API", config.Config.RateLimitAPI)
+    generateLimit := ratelimit.MustParseLimit("RATE_LIMIT_GENERATE", config.Config.RateLimitGenerate)
+    proxyLimit := ratelimit.MustParseLimit("RATE_LIMIT_PROXY", config.Config.RateLimitProxy)
+    proxyIPLimit := ratelimit.MustParseLimit("RATE_LIMIT_PROXY_IP", config.Config.RateLimitProxyIP)
+
     // 健康检查
     router.GET("/health", handlers.HealthCheck)
+    router.GET("/.well-known/jwks.json", handlers.JWKS)
 
     // API路由组
     api := router.Group("/api/v1")
     {
-        // 公开路由
-        api.POST("/auth/register", handlers.Register)
-        api.POST("/auth/login", handlers.Login)
-        api.POST("/proxy/images/generations", handlers.ProxyGenerateImage)
-        api.POST("/proxy/images/edits", handlers.ProxyEditImage)
-        api.GET("/proxy/models", handlers.ProxyModels)
+        // 公开路由，按 IP 限流
+        auth := api.Group("/auth")
+        auth.Use(middleware.RateLimit("auth", authLimit))
+        {
+            auth.POST("/register", handlers.Register)
+            auth.POST("/login", handlers.Login)
+            auth.POST("/refresh", handlers.RefreshToken)
+        }
+
+        // 代理接口：JWT 或用户 API Key 可使用服务端 key，否则只透传调用方自己的上游 key。
+        // 认证之前先按 IP 限流，猜测 API Key 或令牌的失败请求同样计数
+        proxy := api.Group("/proxy")
+        proxy.Use(middleware.ProxyLogger(), middleware.RateLimitByIP("proxy-ip", proxyIPLimit), middleware.ProxyAuth(), middleware.RateLimit("proxy", proxyLimit))
+        {
+            proxy.POST("/images/generations", handlers.ProxyGenerateImage)
+            proxy.POST("/images/edits", handlers.ProxyEditImage)
+            proxy.GET("/models", handlers.ProxyModels)
+        }
 
         // 需要认证的路由
         protected := api.Group("")
-        protected.Use(middleware.JWTAuth())
+        protected.Use(middleware.JWTAuth(), middleware.RateLimit("api", apiLimit))
         {
             // 用户相关
+            protected.POST("/auth/logout", handlers.Logout)
             protected.GET("/user/profile", handlers.GetUserProfile)
             protected.PUT("/user/profile", handlers.UpdateUserProfile)
+            protected.GET("/user/invitations", handlers.GetInvitations)
+            protected.GET("/user/api-keys", handlers.GetAPIKeys)
+            protected.POST("/user/api-keys", handlers.CreateAPIKey)
+            protected.PUT("/user/api-keys/:id", handlers.UpdateAPIKey)
+            protected.DELETE("/user/api-keys/:id", handlers.DeleteAPIKey)
+            protected.GET("/user/access-keys", handlers.GetAccessKeys)
+            protected.POST("/user/access-keys", handlers.CreateAccessKey)
+            protected.DELETE("/user/access-keys/:id", handlers.DeleteAccessKey)
+            protected.GET("/user/proxy-logs", handlers.GetProxyLogs)
+
+			// 用量与额度
+			protected.GET("/usage", handlers.GetUsage)
 
 			// 项目相关
 			protected.GET("/projects", handlers.GetProjects)
@@ -36,16 +71,82 @@ func SetupRoutes(router *gin.Engine) {
 			protected.PUT("/projects/:id", handlers.UpdateProject)
 			protected.DELETE("/projects/:id", handlers.DeleteProject)
 
-			// 图片生成
-			protected.POST("/generate/image", handlers.GenerateImage)
-			protected.POST("/generate/batch", handlers.GenerateBatchImages)
-			protected.POST("/generate/edit", handlers.EditImage)
+			// 项目归档：备份与跨实例迁移
+			protected.POST("/projects/:id/archive", handlers.ArchiveProject)
+			protected.POST("/projects/import", handlers.ImportProject)
+
+			// 组织（团队工作区）
+			protected.GET("/orgs", handlers.GetOrganizations)
+			protected.POST("/orgs", handlers.CreateOrganization)
+			protected.GET("/orgs/:id", handlers.GetOrganization)
+			protected.PUT("/orgs/:id", handlers.UpdateOrganization)
+			protected.DELETE("/orgs/:id", handlers.DeleteOrganization)
+			protected.GET("/orgs/:id/members", handlers.GetOrgMembers)
+			protected.POST("/orgs/:id/members", handlers.AddOrgMember)
+			protected.PUT("/orgs/:id/members/:userId", handlers.UpdateOrgMember)
+			protected.DELETE("/orgs/:id/members/:userId", handlers.RemoveOrgMember)
+
+			// 项目成员
+			protected.GET("/projects/:id/members", handlers.GetProjectMembers)
+			protected.POST("/projects/:id/members", handlers.InviteProjectMember)
+			protected.POST("/projects/:id/members/accept", handlers.AcceptProjectInvitation)
+			protected.PUT("/projects/:id/members/:userId", handlers.UpdateProjectMember)
+			protected.DELETE("/projects/:id/members/:userId", handlers.RemoveProjectMember)
+
+			// 分镜镜头
+			protected.GET("/projects/:id/scenes", handlers.GetScenes)
+			protected.POST("/projects/:id/scenes", handlers.CreateScene)
+			protected.PUT("/projects/:id/scenes/order", handlers.ReorderScenes)
+			protected.PUT("/projects/:id/scenes/:sceneId", handlers.UpdateScene)
+			protected.DELETE("/projects/:id/scenes/:sceneId", handlers.DeleteScene)
+			protected.GET("/projects/:id/export", handlers.ExportStoryboard)
+
+			// 参考素材
+			protected.GET("/projects/:id/references", handlers.GetReferences)
+			protected.POST("/projects/:id/references", handlers.CreateReference)
+			protected.PUT("/projects/:id/references/:refId", handlers.UpdateReference)
+			protected.DELETE("/projects/:id/references/:refId", handlers.DeleteReference)
+
+			// 批量生成画面与生成脚本，与 /generate 共用限流额度
+			protected.POST("/projects/:id/scenes/generate", middleware.RateLimit("generate", generateLimit), handlers.GenerateMissingFrames)
+			protected.POST("/projects/:id/script", middleware.RateLimit("generate", generateLimit), handlers.GenerateScript)
+
+			// 图片生成，在 api 之外单独限流
+			generate := protected.Group("/generate")
+			generate.Use(middleware.RateLimit("generate", generateLimit))
+			{
+				generate.POST("/image", handlers.GenerateImage)
+				generate.POST("/batch", handlers.GenerateBatchImages)
+				generate.POST("/edit", handlers.EditImage)
+			}
+
+			// 生成记录与任务
+			protected.GET("/generations/:id", handlers.GetGeneration)
+			protected.GET("/jobs/:id", handlers.GetJob)
+			protected.GET("/jobs/:id/events", handlers.StreamJobEvents)
+
+			// 提示词模板
+			protected.GET("/templates", handlers.GetTemplates)
+			protected.POST("/templates", handlers.CreateTemplate)
+			protected.GET("/templates/:id", handlers.GetTemplate)
+			protected.PUT("/templates/:id", handlers.UpdateTemplate)
+			protected.DELETE("/templates/:id", handlers.DeleteTemplate)
+			protected.POST("/templates/:id/render", handlers.RenderTemplate)
 
 			// 图片管理
 			protected.GET("/images", handlers.GetImages)
 			protected.GET("/images/:id", handlers.GetImage)
 			protected.DELETE("/images/:id", handlers.DeleteImage)
 			protected.GET("/images/:id/download", handlers.DownloadImage)
+			protected.GET("/images/:id/lineage", handlers.GetImageLineage)
+
+			// 管理员
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireAdmin())
+			{
+				admin.PUT("/users/:id/usage", handlers.AdminUpdateUsage)
+				admin.PUT("/users/:id/role", handlers.AdminUpdateRole)
+			}
 		}
 	}
 }
@@ -0,0 +1,202 @@
+package scripts
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// trailingComma 模型常见的 JSON 错误：数组或对象末尾多余的逗号
+	trailingComma = regexp.MustCompile(`,\s*([}\]])`)
+	// timeRange 匹配 "[0-5s]"、"5–10 s"、"0:05 - 0:10" 等时间范围，单位为秒
+	timeRange = regexp.MustCompile(`(\d+(?::\d{1,2})?(?:\.\d+)?)\s*s?\s*(?:-|–|—|~|to)\s*(\d+(?::\d{1,2})?(?:\.\d+)?)`)
+)
+
+// 各字段可接受的别名，按优先级排列
+var (
+	descriptionKeys = []string{"description", "narration", "text", "caption", "summary"}
+	visualKeys      = []string{"visual_prompt", "visual", "image_prompt", "prompt", "visuals"}
+	cameraKeys      = []string{"camera_notes", "camera", "shot", "shot_type"}
+	sceneListKeys   = []string{"scenes", "shots", "frames", "storyboard"}
+)
+
+// Parse 从模型回复中提取脚本：容忍代码块包裹、前后说明文字、末尾逗号与常见的字段别名，
+// 再裁剪到请求的镜头数并修复时间轴
+func Parse(content string, req Request) (*Script, error) {
+	raw, err := extractJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+		if err := json.Unmarshal([]byte(trailingComma.ReplaceAllString(raw, "$1")), &decoded); err != nil {
+			return nil, errors.New("reply is not valid JSON")
+		}
+	}
+
+	script := &Script{}
+	var items []interface{}
+	switch v := decoded.(type) {
+	case []interface{}:
+		items = v
+	case map[string]interface{}:
+		script.Title = stringField(v, "title", "name")
+		for _, key := range sceneListKeys {
+			if list, ok := v[key].([]interface{}); ok {
+				items = list
+				break
+			}
+		}
+	}
+
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		scene := Scene{
+			Description:  stringField(obj, descriptionKeys...),
+			VisualPrompt: stringField(obj, visualKeys...),
+			CameraNotes:  stringField(obj, cameraKeys...),
+		}
+		if scene.Description == "" && scene.VisualPrompt == "" {
+			continue
+		}
+		if scene.VisualPrompt == "" {
+			scene.VisualPrompt = scene.Description
+		}
+		if scene.Description == "" {
+			scene.Description = scene.VisualPrompt
+		}
+		scene.StartMs, scene.EndMs = sceneTiming(obj)
+		script.Scenes = append(script.Scenes, scene)
+	}
+	if len(script.Scenes) == 0 {
+		return nil, errors.New("reply contains no scenes")
+	}
+
+	if len(script.Scenes) > req.SceneCount {
+		script.Scenes = script.Scenes[:req.SceneCount]
+	}
+	repairTiming(script.Scenes, req.DurationMs)
+	return script, nil
+}
+
+// extractJSON 去掉代码块标记与前后文字，取出最外层的 JSON 对象或数组
+func extractJSON(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if i := strings.Index(content, "```"); i >= 0 {
+		rest := content[i+3:]
+		if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+			rest = rest[nl+1:]
+		}
+		if end := strings.Index(rest, "```"); end >= 0 {
+			rest = rest[:end]
+		}
+		content = strings.TrimSpace(rest)
+	}
+
+	start := strings.IndexAny(content, "{[")
+	if start < 0 {
+		return "", errors.New("reply does not contain JSON")
+	}
+	closing := "}"
+	if content[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(content, closing)
+	if end < start {
+		return "", errors.New("reply contains incomplete JSON")
+	}
+	return content[start : end+1], nil
+}
+
+func stringField(obj map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := obj[key].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+func numberField(obj map[string]interface{}, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		switch v := obj[key].(type) {
+		case float64:
+			return v, true
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// sceneTiming 读取镜头的起止时间（毫秒）；无法识别时返回 (0, 0)，交给 repairTiming 处理
+func sceneTiming(obj map[string]interface{}) (int64, int64) {
+	start, okStart := numberField(obj, "start_ms")
+	end, okEnd := numberField(obj, "end_ms")
+	if okStart && okEnd {
+		return int64(start), int64(end)
+	}
+
+	start, okStart = numberField(obj, "start", "start_time", "start_seconds")
+	end, okEnd = numberField(obj, "end", "end_time", "end_seconds")
+	if okStart && okEnd {
+		return int64(start * 1000), int64(end * 1000)
+	}
+
+	if s := stringField(obj, "time", "timestamp", "time_range"); s != "" {
+		if m := timeRange.FindStringSubmatch(s); m != nil {
+			return int64(parseSeconds(m[1]) * 1000), int64(parseSeconds(m[2]) * 1000)
+		}
+	}
+	return 0, 0
+}
+
+// parseSeconds 解析 "5"、"5.5" 或 "1:05"
+func parseSeconds(s string) float64 {
+	if minutes, seconds, ok := strings.Cut(s, ":"); ok {
+		m, _ := strconv.ParseFloat(minutes, 64)
+		sec, _ := strconv.ParseFloat(seconds, 64)
+		return m*60 + sec
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// repairTiming 时间轴有效（递增且不重叠）时按比例缩放到目标时长，否则平均分配
+func repairTiming(scenes []Scene, durationMs int64) {
+	valid := true
+	var prevEnd int64
+	for _, scene := range scenes {
+		if scene.StartMs < prevEnd || scene.EndMs <= scene.StartMs {
+			valid = false
+			break
+		}
+		prevEnd = scene.EndMs
+	}
+
+	n := int64(len(scenes))
+	if !valid {
+		for i := range scenes {
+			scenes[i].StartMs = durationMs * int64(i) / n
+			scenes[i].EndMs = durationMs * int64(i+1) / n
+		}
+		return
+	}
+
+	scale := float64(durationMs) / float64(prevEnd)
+	for i := range scenes {
+		scenes[i].StartMs = int64(math.Round(float64(scenes[i].StartMs) * scale))
+		scenes[i].EndMs = int64(math.Round(float64(scenes[i].EndMs) * scale))
+	}
+	scenes[n-1].EndMs = durationMs
+}
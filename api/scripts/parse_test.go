@@ -0,0 +1,180 @@
+package scripts
+
+import (
+	"strings"
+	"testing"
+)
+
+type span struct{ start, end int64 }
+
+func timeline(s *Script) []span {
+	spans := make([]span, len(s.Scenes))
+	for i, scene := range s.Scenes {
+		spans[i] = span{scene.StartMs, scene.EndMs}
+	}
+	return spans
+}
+
+func TestParse(t *testing.T) {
+	req := Request{SceneCount: 3, DurationMs: 30000}
+
+	tests := []struct {
+		name    string
+		content string
+		req     Request
+		title   string
+		want    []span
+		// first 为第一个镜头的 (description, visual_prompt, camera_notes)，为空时不检查
+		first []string
+	}{
+		{
+			name: "plain object",
+			content: `{"title": "Lighthouse", "scenes": [
+				{"start_ms": 0, "end_ms": 10000, "description": "a", "visual_prompt": "va", "camera_notes": "ca"},
+				{"start_ms": 10000, "end_ms": 20000, "description": "b", "visual_prompt": "vb", "camera_notes": "cb"},
+				{"start_ms": 20000, "end_ms": 30000, "description": "c", "visual_prompt": "vc", "camera_notes": "cc"}]}`,
+			req:   req,
+			title: "Lighthouse",
+			want:  []span{{0, 10000}, {10000, 20000}, {20000, 30000}},
+			first: []string{"a", "va", "ca"},
+		},
+		{
+			name: "fenced JSON with surrounding text",
+			content: "Here is your storyboard:\n```json\n" +
+				`{"title": "Fenced", "scenes": [{"start_ms": 0, "end_ms": 15000, "description": "a"}, {"start_ms": 15000, "end_ms": 30000, "description": "b"}]}` +
+				"\n```\nLet me know if you want changes.",
+			req:   req,
+			title: "Fenced",
+			want:  []span{{0, 15000}, {15000, 30000}},
+			first: []string{"a", "a", ""},
+		},
+		{
+			name:    "trailing commas",
+			content: `{"scenes": [{"start_ms": 0, "end_ms": 30000, "description": "only",},],}`,
+			req:     req,
+			want:    []span{{0, 30000}},
+		},
+		{
+			name: "aliased fields and bare array",
+			content: `[{"start": 0, "end": 5, "narration": "n1", "image_prompt": "p1", "shot": "wide"},
+				{"start": 5, "end": 10, "text": "n2", "visual": "p2", "camera": "close"}]`,
+			req:   Request{SceneCount: 5, DurationMs: 10000},
+			want:  []span{{0, 5000}, {5000, 10000}},
+			first: []string{"n1", "p1", "wide"},
+		},
+		{
+			name:    "aliased scene list",
+			content: `{"name": "Shots", "shots": [{"caption": "x", "prompt": "px", "start_seconds": "0", "end_seconds": "30"}]}`,
+			req:     req,
+			title:   "Shots",
+			want:    []span{{0, 30000}},
+			first:   []string{"x", "px", ""},
+		},
+		{
+			name: "time range strings",
+			content: `{"scenes": [
+				{"time": "[0-6s]", "description": "a"},
+				{"time_range": "0:06 - 0:12", "description": "b"},
+				{"timestamp": "12–18 s", "description": "c"}]}`,
+			req:  Request{SceneCount: 3, DurationMs: 18000},
+			want: []span{{0, 6000}, {6000, 12000}, {12000, 18000}},
+		},
+		{
+			name:    "timeline scaled to requested duration",
+			content: `{"scenes": [{"start_ms": 0, "end_ms": 1000, "description": "a"}, {"start_ms": 1000, "end_ms": 3000, "description": "b"}]}`,
+			req:     Request{SceneCount: 2, DurationMs: 6000},
+			want:    []span{{0, 2000}, {2000, 6000}},
+		},
+		{
+			name: "too many scenes are truncated",
+			content: `{"scenes": [
+				{"start_ms": 0, "end_ms": 1000, "description": "a"},
+				{"start_ms": 1000, "end_ms": 2000, "description": "b"},
+				{"start_ms": 2000, "end_ms": 3000, "description": "c"},
+				{"start_ms": 3000, "end_ms": 4000, "description": "d"}]}`,
+			req:  Request{SceneCount: 2, DurationMs: 10000},
+			want: []span{{0, 5000}, {5000, 10000}},
+		},
+		{
+			name:    "too few scenes are kept",
+			content: `{"scenes": [{"description": "a"}, {"description": "b"}]}`,
+			req:     Request{SceneCount: 4, DurationMs: 8000},
+			want:    []span{{0, 4000}, {4000, 8000}},
+		},
+		{
+			name: "overlapping timing is spread evenly",
+			content: `{"scenes": [
+				{"start_ms": 0, "end_ms": 5000, "description": "a"},
+				{"start_ms": 3000, "end_ms": 8000, "description": "b"},
+				{"start_ms": 8000, "end_ms": 9000, "description": "c"}]}`,
+			req:  Request{SceneCount: 3, DurationMs: 9000},
+			want: []span{{0, 3000}, {3000, 6000}, {6000, 9000}},
+		},
+		{
+			name: "empty or reversed ranges are spread evenly",
+			content: `{"scenes": [
+				{"start_ms": 0, "end_ms": 0, "description": "a"},
+				{"start_ms": 9000, "end_ms": 2000, "description": "b"}]}`,
+			req:  Request{SceneCount: 2, DurationMs: 10000},
+			want: []span{{0, 5000}, {5000, 10000}},
+		},
+		{
+			name:    "scenes without text are skipped",
+			content: `{"scenes": [{"camera_notes": "wide"}, {"description": " ", "visual_prompt": "v"}, 42]}`,
+			req:     req,
+			want:    []span{{0, 30000}},
+			first:   []string{"v", "v", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := Parse(tt.content, tt.req)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if script.Title != tt.title {
+				t.Errorf("title = %q, want %q", script.Title, tt.title)
+			}
+			got := timeline(script)
+			if len(got) != len(tt.want) {
+				t.Fatalf("timeline = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("timeline = %v, want %v", got, tt.want)
+				}
+			}
+			if tt.first != nil {
+				s := script.Scenes[0]
+				if s.Description != tt.first[0] || s.VisualPrompt != tt.first[1] || s.CameraNotes != tt.first[2] {
+					t.Errorf("first scene = (%q, %q, %q), want %q", s.Description, s.VisualPrompt, s.CameraNotes, tt.first)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	req := Request{SceneCount: 3, DurationMs: 30000}
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no JSON", "I cannot help with that.", "does not contain JSON"},
+		{"incomplete JSON", `{"scenes": [`, "incomplete JSON"},
+		{"truncated JSON", `{"scenes": [{"description": "a"}`, "not valid JSON"},
+		{"invalid JSON", `{"scenes": [{"description": a}]}`, "not valid JSON"},
+		{"no scenes", `{"title": "Empty", "scenes": []}`, "no scenes"},
+		{"unknown scene list", `{"clips": [{"description": "a"}]}`, "no scenes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.content, req)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package scripts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ai-design-backend/providers"
+)
+
+const (
+	MaxSynopsisLength = 4000
+	MaxScenes         = 50
+	DefaultSceneCount = 5
+	// DefaultDurationMs 默认时长与前端的默认脚本一致：5 个 5 秒镜头
+	DefaultDurationMs = 25 * 1000
+	MaxDurationMs     = 10 * 60 * 1000
+	// maxAttempts 模型回复无法解析时，带上错误原因再请求一次
+	maxAttempts = 2
+)
+
+var ErrInvalidScript = errors.New("model returned an invalid script")
+
+// Request 一次脚本生成的输入
+type Request struct {
+	Model      string
+	Synopsis   string
+	DurationMs int64
+	SceneCount int
+}
+
+// Scene 脚本中的一个镜头，字段与 models.Scene 对应
+type Scene struct {
+	StartMs      int64  `json:"start_ms"`
+	EndMs        int64  `json:"end_ms"`
+	Description  string `json:"description"`
+	VisualPrompt string `json:"visual_prompt"`
+	CameraNotes  string `json:"camera_notes"`
+}
+
+type Script struct {
+	Title  string  `json:"title"`
+	Scenes []Scene `json:"scenes"`
+}
+
+// Normalize 校验输入并补齐默认值
+func (r *Request) Normalize() error {
+	r.Synopsis = strings.TrimSpace(r.Synopsis)
+	if r.Synopsis == "" {
+		return errors.New("synopsis is required")
+	}
+	if len(r.Synopsis) > MaxSynopsisLength {
+		return fmt.Errorf("synopsis must be at most %d characters", MaxSynopsisLength)
+	}
+	if r.SceneCount == 0 {
+		r.SceneCount = DefaultSceneCount
+	}
+	if r.SceneCount < 1 || r.SceneCount > MaxScenes {
+		return fmt.Errorf("scene_count must be between 1 and %d", MaxScenes)
+	}
+	if r.DurationMs == 0 {
+		r.DurationMs = DefaultDurationMs
+	}
+	if r.DurationMs < int64(r.SceneCount)*1000 || r.DurationMs > MaxDurationMs {
+		return fmt.Errorf("duration_ms must allow at least one second per scene and be at most %d", MaxDurationMs)
+	}
+	return nil
+}
+
+const systemPrompt = `You are a storyboard artist. Break the user's synopsis into shots for a short video.
+Reply with a single JSON object and nothing else, using exactly this shape:
+{"title": string, "scenes": [{"start_ms": integer, "end_ms": integer, "description": string, "visual_prompt": string, "camera_notes": string}]}
+Rules:
+- scenes are in playback order, do not overlap, start at 0 and end exactly at the total duration
+- description is one short line of narration or on-screen text
+- visual_prompt is a self-contained English image generation prompt describing only what is visible in the frame
+- camera_notes describes shot size, angle and camera movement`
+
+// Messages 构造结构化输出的提示词
+func Messages(req Request) []providers.ChatMessage {
+	user := fmt.Sprintf("Synopsis:\n%s\n\nTotal duration: %d ms\nNumber of scenes: %d",
+		req.Synopsis, req.DurationMs, req.SceneCount)
+	return []providers.ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: user},
+	}
+}
+
+// Generate 调用文本模型生成脚本，并将回复校验、修复为 req 要求的镜头数与时长
+func Generate(ctx context.Context, chat providers.ChatProvider, req Request) (*Script, error) {
+	if chat == nil {
+		return nil, providers.ErrNoChatProvider
+	}
+
+	messages := Messages(req)
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		content, err := chat.Chat(ctx, providers.ChatRequest{
+			Model:       req.Model,
+			Messages:    messages,
+			Temperature: 0.7,
+			JSONMode:    true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		script, err := Parse(content, req)
+		if err == nil {
+			return script, nil
+		}
+		lastErr = err
+		messages = append(messages,
+			providers.ChatMessage{Role: "assistant", Content: content},
+			providers.ChatMessage{Role: "user", Content: fmt.Sprintf("That reply could not be used (%v). Reply again with only the JSON object.", err)},
+		)
+	}
+	return nil, fmt.Errorf("%w: %v", ErrInvalidScript, lastErr)
+}
@@ -0,0 +1,120 @@
+package scripts
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ai-design-backend/providers"
+)
+
+// replayChat 依次返回预设的回复，并记录收到的请求
+type replayChat struct {
+	replies  []string
+	requests []providers.ChatRequest
+}
+
+func (r *replayChat) Name() string { return "replay" }
+
+func (r *replayChat) Chat(ctx context.Context, req providers.ChatRequest) (string, error) {
+	r.requests = append(r.requests, req)
+	reply := r.replies[0]
+	if len(r.replies) > 1 {
+		r.replies = r.replies[1:]
+	}
+	return reply, nil
+}
+
+func TestNormalize(t *testing.T) {
+	req := Request{Synopsis: "  a lighthouse keeper  "}
+	if err := req.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if req.Synopsis != "a lighthouse keeper" || req.SceneCount != DefaultSceneCount || req.DurationMs != DefaultDurationMs {
+		t.Errorf("defaults not applied: %+v", req)
+	}
+
+	for _, bad := range []Request{
+		{Synopsis: " "},
+		{Synopsis: "x", SceneCount: MaxScenes + 1},
+		{Synopsis: "x", SceneCount: 10, DurationMs: 5000},
+		{Synopsis: "x", DurationMs: MaxDurationMs + 1},
+	} {
+		if err := bad.Normalize(); err == nil {
+			t.Errorf("Normalize(%+v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestGenerateWithMock(t *testing.T) {
+	mock := providers.NewMock()
+	for _, synopsis := range []string{"a lighthouse keeper", "two friends trade mixtapes", "a storm at sea", "a quiet morning"} {
+		req := Request{Synopsis: synopsis, SceneCount: 4, DurationMs: 20000}
+		if err := req.Normalize(); err != nil {
+			t.Fatal(err)
+		}
+		script, err := Generate(context.Background(), mock, req)
+		if err != nil {
+			t.Fatalf("Generate(%q): %v", synopsis, err)
+		}
+		if n := len(script.Scenes); n == 0 || n > req.SceneCount {
+			t.Fatalf("Generate(%q) returned %d scenes, want 1..%d", synopsis, n, req.SceneCount)
+		}
+		// 时间轴从 0 开始、首尾相接并恰好结束于请求的时长
+		var prevEnd int64
+		for i, scene := range script.Scenes {
+			if scene.StartMs != prevEnd || scene.EndMs <= scene.StartMs {
+				t.Fatalf("Generate(%q): scene %d spans %d-%d after %d", synopsis, i, scene.StartMs, scene.EndMs, prevEnd)
+			}
+			if scene.Description == "" || scene.VisualPrompt == "" {
+				t.Errorf("Generate(%q): scene %d has empty text", synopsis, i)
+			}
+			prevEnd = scene.EndMs
+		}
+		if prevEnd != req.DurationMs {
+			t.Errorf("Generate(%q) ends at %d, want %d", synopsis, prevEnd, req.DurationMs)
+		}
+	}
+}
+
+func TestGenerateRetriesInvalidReply(t *testing.T) {
+	chat := &replayChat{replies: []string{
+		"Sorry, here you go!",
+		`{"title": "Retry", "scenes": [{"description": "a"}, {"description": "b"}]}`,
+	}}
+	req := Request{Model: "m", Synopsis: "x", SceneCount: 2, DurationMs: 4000}
+
+	script, err := Generate(context.Background(), chat, req)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if script.Title != "Retry" || len(script.Scenes) != 2 {
+		t.Fatalf("script = %+v", script)
+	}
+	if len(chat.requests) != 2 {
+		t.Fatalf("chat called %d times, want 2", len(chat.requests))
+	}
+	// 重试时带上上一次的回复与错误原因
+	retry := chat.requests[1].Messages
+	if len(retry) != 4 || retry[2].Role != "assistant" || retry[2].Content != "Sorry, here you go!" {
+		t.Errorf("retry messages = %+v", retry)
+	}
+	if !chat.requests[0].JSONMode || chat.requests[0].Model != "m" {
+		t.Errorf("request = %+v", chat.requests[0])
+	}
+}
+
+func TestGenerateGivesUp(t *testing.T) {
+	chat := &replayChat{replies: []string{"no JSON here"}}
+	_, err := Generate(context.Background(), chat, Request{Synopsis: "x", SceneCount: 1, DurationMs: 1000})
+	if !errors.Is(err, ErrInvalidScript) {
+		t.Fatalf("err = %v, want ErrInvalidScript", err)
+	}
+	if len(chat.requests) != maxAttempts {
+		t.Errorf("chat called %d times, want %d", len(chat.requests), maxAttempts)
+	}
+
+	if _, err := Generate(context.Background(), nil, Request{}); !errors.Is(err, providers.ErrNoChatProvider) {
+		t.Errorf("nil provider: err = %v", err)
+	}
+}
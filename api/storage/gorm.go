@@ -0,0 +1,506 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"ai-design-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormStorage 基于 GORM 的持久化存储，默认配合 SQLite 使用
+type GormStorage struct {
+	db *gorm.DB
+}
+
+func NewGormStorage(db *gorm.DB) *GormStorage {
+	return &GormStorage{db: db}
+}
+
+// first 查询单条记录，记录不存在时返回 (nil, nil)，与内存存储保持一致
+func first[T any](db *gorm.DB, query interface{}, args ...interface{}) (*T, error) {
+	var out T
+	err := db.Where(query, args...).First(&out).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// update 只更新已存在的记录，不会像 Save 那样在缺失时插入
+func (s *GormStorage) update(value interface{}) error {
+	return s.db.Model(value).Select("*").Omit(clause.Associations).Updates(value).Error
+}
+
+func (s *GormStorage) CreateUser(user *models.User) error {
+	return s.db.Omit(clause.Associations).Create(user).Error
+}
+
+func (s *GormStorage) GetUserByID(id uuid.UUID) (*models.User, error) {
+	return first[models.User](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) GetUserByEmail(email string) (*models.User, error) {
+	return first[models.User](s.db, "email = ?", email)
+}
+
+func (s *GormStorage) GetUserByUsername(username string) (*models.User, error) {
+	return first[models.User](s.db, "username = ?", username)
+}
+
+func (s *GormStorage) UpdateUser(user *models.User) error {
+	return s.update(user)
+}
+
+func (s *GormStorage) CreateProject(project *models.Project) error {
+	return s.db.Omit(clause.Associations).Create(project).Error
+}
+
+func (s *GormStorage) GetProjectByID(id uuid.UUID) (*models.Project, error) {
+	return first[models.Project](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) GetProjectsByUserID(userID uuid.UUID) ([]*models.Project, error) {
+	var projects []*models.Project
+	err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&projects).Error
+	return projects, err
+}
+
+func (s *GormStorage) GetProjectsByOrgID(orgID uuid.UUID) ([]*models.Project, error) {
+	var projects []*models.Project
+	err := s.db.Where("org_id = ?", orgID).Order("created_at").Find(&projects).Error
+	return projects, err
+}
+
+func (s *GormStorage) UpdateProject(project *models.Project) error {
+	return s.update(project)
+}
+
+func (s *GormStorage) DeleteProject(id uuid.UUID) error {
+	return s.db.Delete(&models.Project{}, "id = ?", id).Error
+}
+
+func (s *GormStorage) CreateProjectMember(member *models.ProjectMember) error {
+	return s.db.Create(member).Error
+}
+
+func (s *GormStorage) GetProjectMember(projectID, userID uuid.UUID) (*models.ProjectMember, error) {
+	return first[models.ProjectMember](s.db, "project_id = ? AND user_id = ?", projectID, userID)
+}
+
+func (s *GormStorage) GetProjectMembers(projectID uuid.UUID) ([]*models.ProjectMember, error) {
+	var members []*models.ProjectMember
+	err := s.db.Where("project_id = ?", projectID).Order("created_at").Find(&members).Error
+	return members, err
+}
+
+func (s *GormStorage) GetMembershipsByUserID(userID uuid.UUID) ([]*models.ProjectMember, error) {
+	var members []*models.ProjectMember
+	err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&members).Error
+	return members, err
+}
+
+func (s *GormStorage) UpdateProjectMember(member *models.ProjectMember) error {
+	return s.update(member)
+}
+
+func (s *GormStorage) DeleteProjectMember(id uuid.UUID) error {
+	return s.db.Delete(&models.ProjectMember{}, "id = ?", id).Error
+}
+
+func (s *GormStorage) CreateOrganization(org *models.Organization) error {
+	return s.db.Create(org).Error
+}
+
+func (s *GormStorage) GetOrganizationByID(id uuid.UUID) (*models.Organization, error) {
+	return first[models.Organization](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) UpdateOrganization(org *models.Organization) error {
+	return s.update(org)
+}
+
+func (s *GormStorage) DeleteOrganization(id uuid.UUID) error {
+	return s.db.Delete(&models.Organization{}, "id = ?", id).Error
+}
+
+func (s *GormStorage) CreateOrgMember(member *models.OrgMember) error {
+	return s.db.Create(member).Error
+}
+
+func (s *GormStorage) GetOrgMember(orgID, userID uuid.UUID) (*models.OrgMember, error) {
+	return first[models.OrgMember](s.db, "org_id = ? AND user_id = ?", orgID, userID)
+}
+
+func (s *GormStorage) GetOrgMembers(orgID uuid.UUID) ([]*models.OrgMember, error) {
+	var members []*models.OrgMember
+	err := s.db.Where("org_id = ?", orgID).Order("created_at").Find(&members).Error
+	return members, err
+}
+
+func (s *GormStorage) GetOrgMembershipsByUserID(userID uuid.UUID) ([]*models.OrgMember, error) {
+	var members []*models.OrgMember
+	err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&members).Error
+	return members, err
+}
+
+func (s *GormStorage) UpdateOrgMember(member *models.OrgMember) error {
+	return s.update(member)
+}
+
+func (s *GormStorage) DeleteOrgMember(id uuid.UUID) error {
+	return s.db.Delete(&models.OrgMember{}, "id = ?", id).Error
+}
+
+func (s *GormStorage) CreateCredential(cred *models.ProviderCredential) error {
+	return s.db.Create(cred).Error
+}
+
+func (s *GormStorage) GetCredentialByID(id uuid.UUID) (*models.ProviderCredential, error) {
+	return first[models.ProviderCredential](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) GetCredential(userID uuid.UUID, provider string) (*models.ProviderCredential, error) {
+	return first[models.ProviderCredential](s.db, "user_id = ? AND provider = ?", userID, provider)
+}
+
+func (s *GormStorage) GetCredentialsByUserID(userID uuid.UUID) ([]*models.ProviderCredential, error) {
+	var creds []*models.ProviderCredential
+	err := s.db.Where("user_id = ?", userID).Order("provider").Find(&creds).Error
+	return creds, err
+}
+
+func (s *GormStorage) UpdateCredential(cred *models.ProviderCredential) error {
+	return s.update(cred)
+}
+
+func (s *GormStorage) DeleteCredential(id uuid.UUID) error {
+	return s.db.Delete(&models.ProviderCredential{}, "id = ?", id).Error
+}
+
+func (s *GormStorage) CreateAccessKey(key *models.AccessKey) error {
+	return s.db.Create(key).Error
+}
+
+func (s *GormStorage) GetAccessKeyByID(id uuid.UUID) (*models.AccessKey, error) {
+	return first[models.AccessKey](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) GetAccessKeyByHash(hash string) (*models.AccessKey, error) {
+	return first[models.AccessKey](s.db, "key_hash = ?", hash)
+}
+
+func (s *GormStorage) GetAccessKeysByUserID(userID uuid.UUID) ([]*models.AccessKey, error) {
+	var keys []*models.AccessKey
+	err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&keys).Error
+	return keys, err
+}
+
+func (s *GormStorage) UpdateAccessKey(key *models.AccessKey) error {
+	return s.update(key)
+}
+
+func (s *GormStorage) DeleteAccessKey(id uuid.UUID) error {
+	return s.db.Delete(&models.AccessKey{}, "id = ?", id).Error
+}
+
+func (s *GormStorage) CreateProxyLog(entry *models.ProxyLog) error {
+	return s.db.Create(entry).Error
+}
+
+func (s *GormStorage) GetProxyLogsByUserID(userID uuid.UUID, limit int) ([]*models.ProxyLog, error) {
+	var entries []*models.ProxyLog
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+func (s *GormStorage) CreateUsageEntry(entry *models.UsageEntry) error {
+	return s.db.Create(entry).Error
+}
+
+func (s *GormStorage) UpdateUsageEntry(entry *models.UsageEntry) error {
+	return s.update(entry)
+}
+
+func (s *GormStorage) GetUsageEntries(filter UsageFilter) ([]*models.UsageEntry, error) {
+	query := s.db.Model(&models.UsageEntry{})
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.OrgID != nil {
+		query = query.Where("org_id = ?", *filter.OrgID)
+	}
+	if filter.ProjectID != nil {
+		query = query.Where("project_id = ?", *filter.ProjectID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	var entries []*models.UsageEntry
+	err := query.Order("created_at").Find(&entries).Error
+	return entries, err
+}
+
+func (s *GormStorage) CountImagesSince(userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	err := s.db.Model(&models.UsageEntry{}).
+		Select("COALESCE(SUM(CASE WHEN status = 'pending' THEN n ELSE images END), 0)").
+		Where("user_id = ? AND created_at >= ? AND status IN ?", userID, since, []string{"pending", "success"}).
+		Scan(&count).Error
+	return count, err
+}
+
+func (s *GormStorage) GetUsageAccount(userID uuid.UUID) (*models.UsageAccount, error) {
+	return first[models.UsageAccount](s.db, "user_id = ?", userID)
+}
+
+func (s *GormStorage) CreateUsageAccount(account *models.UsageAccount) error {
+	return s.db.Create(account).Error
+}
+
+// UpdateUsageAccount 只更新配额，余额必须通过 AdjustCredits 修改
+func (s *GormStorage) UpdateUsageAccount(account *models.UsageAccount) error {
+	return s.db.Model(&models.UsageAccount{}).Where("user_id = ?", account.UserID).
+		Updates(map[string]interface{}{"monthly_image_quota": account.MonthlyImageQuota, "updated_at": time.Now()}).Error
+}
+
+func (s *GormStorage) AdjustCredits(userID uuid.UUID, delta int64, allowNegative bool) (bool, error) {
+	query := s.db.Model(&models.UsageAccount{}).Where("user_id = ?", userID)
+	if !allowNegative && delta < 0 {
+		query = query.Where("credit_micros + ? >= 0", delta)
+	}
+	result := query.Updates(map[string]interface{}{
+		"credit_micros": gorm.Expr("credit_micros + ?", delta),
+		"updated_at":    time.Now(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *GormStorage) CreateImage(image *models.Image) error {
+	return s.db.Omit(clause.Associations).Create(image).Error
+}
+
+func (s *GormStorage) GetImageByID(id uuid.UUID) (*models.Image, error) {
+	return first[models.Image](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) GetImagesByProjectID(projectID uuid.UUID) ([]*models.Image, error) {
+	var images []*models.Image
+	err := s.db.Where("project_id = ?", projectID).Order("created_at").Find(&images).Error
+	return images, err
+}
+
+func (s *GormStorage) GetImagesByJobID(jobID uuid.UUID) ([]*models.Image, error) {
+	var images []*models.Image
+	err := s.db.Where("job_id = ?", jobID).Order("created_at").Find(&images).Error
+	return images, err
+}
+
+func (s *GormStorage) GetImagesByGenerationID(generationID uuid.UUID) ([]*models.Image, error) {
+	var images []*models.Image
+	err := s.db.Where("generation_id = ?", generationID).Order("output_index").Find(&images).Error
+	return images, err
+}
+
+func (s *GormStorage) GetImagesByParentID(parentID uuid.UUID) ([]*models.Image, error) {
+	var images []*models.Image
+	err := s.db.Where("parent_image_id = ?", parentID).Order("created_at").Find(&images).Error
+	return images, err
+}
+
+func (s *GormStorage) GetImagesByBlobKey(key string) ([]*models.Image, error) {
+	var images []*models.Image
+	err := s.db.Where("blob_key = ?", key).Order("created_at desc").Find(&images).Error
+	return images, err
+}
+
+func (s *GormStorage) UpdateImage(image *models.Image) error {
+	return s.update(image)
+}
+
+func (s *GormStorage) DeleteImage(id uuid.UUID) error {
+	return s.db.Delete(&models.Image{}, "id = ?", id).Error
+}
+
+func (s *GormStorage) CreateGeneration(generation *models.Generation) error {
+	return s.db.Create(generation).Error
+}
+
+func (s *GormStorage) GetGenerationByID(id uuid.UUID) (*models.Generation, error) {
+	return first[models.Generation](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) CreateTemplate(template *models.PromptTemplate) error {
+	return s.db.Create(template).Error
+}
+
+func (s *GormStorage) GetTemplateByID(id uuid.UUID) (*models.PromptTemplate, error) {
+	return first[models.PromptTemplate](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) GetTemplateByName(userID uuid.UUID, name string) (*models.PromptTemplate, error) {
+	return first[models.PromptTemplate](s.db, "user_id = ? AND name = ?", userID, name)
+}
+
+func (s *GormStorage) GetTemplatesByUserID(userID uuid.UUID) ([]*models.PromptTemplate, error) {
+	var templates []*models.PromptTemplate
+	err := s.db.Where("user_id = ?", userID).Order("name").Find(&templates).Error
+	return templates, err
+}
+
+func (s *GormStorage) UpdateTemplate(template *models.PromptTemplate) error {
+	return s.update(template)
+}
+
+func (s *GormStorage) DeleteTemplate(id uuid.UUID) error {
+	return s.db.Delete(&models.PromptTemplate{}, "id = ?", id).Error
+}
+
+func (s *GormStorage) CreateScene(scene *models.Scene) error {
+	return s.db.Create(scene).Error
+}
+
+func (s *GormStorage) GetSceneByID(id uuid.UUID) (*models.Scene, error) {
+	return first[models.Scene](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) GetScenesByProjectID(projectID uuid.UUID) ([]*models.Scene, error) {
+	var scenes []*models.Scene
+	err := s.db.Where("project_id = ?", projectID).Order("order_index").Order("created_at").Find(&scenes).Error
+	return scenes, err
+}
+
+func (s *GormStorage) UpdateScene(scene *models.Scene) error {
+	return s.update(scene)
+}
+
+func (s *GormStorage) DeleteScene(id uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Image{}).Where("scene_id = ?", id).
+			Updates(map[string]interface{}{"scene_id": nil, "updated_at": time.Now()}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&models.Scene{}, "id = ?", id).Error
+	})
+}
+
+func (s *GormStorage) SetSceneOrder(projectID uuid.UUID, ids []uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			err := tx.Model(&models.Scene{}).
+				Where("id = ? AND project_id = ?", id, projectID).
+				Updates(map[string]interface{}{"order_index": i, "updated_at": time.Now()}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *GormStorage) SelectSceneImage(sceneID, imageID uuid.UUID) (bool, error) {
+	result := s.db.Model(&models.Scene{}).
+		Where("id = ? AND selected_image_id IS NULL", sceneID).
+		Updates(map[string]interface{}{"selected_image_id": imageID, "updated_at": time.Now()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *GormStorage) ClearSceneImage(imageID uuid.UUID) error {
+	return s.db.Model(&models.Scene{}).
+		Where("selected_image_id = ?", imageID).
+		Updates(map[string]interface{}{"selected_image_id": nil, "updated_at": time.Now()}).Error
+}
+
+func (s *GormStorage) CreateReference(ref *models.ReferenceAsset) error {
+	return s.db.Create(ref).Error
+}
+
+func (s *GormStorage) GetReferenceByID(id uuid.UUID) (*models.ReferenceAsset, error) {
+	return first[models.ReferenceAsset](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) GetReferencesByProjectID(projectID uuid.UUID) ([]*models.ReferenceAsset, error) {
+	var refs []*models.ReferenceAsset
+	err := s.db.Where("project_id = ?", projectID).Order("created_at").Find(&refs).Error
+	return refs, err
+}
+
+func (s *GormStorage) UpdateReference(ref *models.ReferenceAsset) error {
+	return s.update(ref)
+}
+
+func (s *GormStorage) DeleteReference(id uuid.UUID) error {
+	return s.db.Delete(&models.ReferenceAsset{}, "id = ?", id).Error
+}
+
+func (s *GormStorage) DeleteReferencesByImageID(imageID uuid.UUID) error {
+	return s.db.Delete(&models.ReferenceAsset{}, "image_id = ?", imageID).Error
+}
+
+func (s *GormStorage) CreateJob(job *models.Job) error {
+	return s.db.Create(job).Error
+}
+
+func (s *GormStorage) GetJobByID(id uuid.UUID) (*models.Job, error) {
+	return first[models.Job](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) UpdateJob(job *models.Job) error {
+	return s.update(job)
+}
+
+func (s *GormStorage) CreateSession(session *models.AuthSession) error {
+	return s.db.Create(session).Error
+}
+
+func (s *GormStorage) GetSessionByID(id uuid.UUID) (*models.AuthSession, error) {
+	return first[models.AuthSession](s.db, "id = ?", id)
+}
+
+func (s *GormStorage) UpdateSession(session *models.AuthSession) error {
+	return s.update(session)
+}
+
+func (s *GormStorage) CreateRefreshToken(token *models.RefreshToken) error {
+	return s.db.Create(token).Error
+}
+
+func (s *GormStorage) GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	return first[models.RefreshToken](s.db, "token_hash = ?", hash)
+}
+
+func (s *GormStorage) MarkRefreshTokenUsed(id uuid.UUID, usedAt time.Time, replacedBy uuid.UUID) (bool, error) {
+	result := s.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Updates(map[string]interface{}{"used_at": usedAt, "replaced_by": replacedBy})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (s *GormStorage) RevokeAccessToken(token *models.RevokedToken) error {
+	// 顺便清理已过期的记录
+	s.db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (s *GormStorage) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
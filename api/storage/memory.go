@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"errors"
+	"sort"
 	"sync"
+	"time"
 	"ai-design-backend/models"
 	"github.com/google/uuid"
 )
@@ -9,7 +12,23 @@ import (
 type MemoryStorage struct {
 	users    map[uuid.UUID]*models.User
 	projects map[uuid.UUID]*models.Project
+	members  map[uuid.UUID]*models.ProjectMember
+	orgs     map[uuid.UUID]*models.Organization
+	orgUsers map[uuid.UUID]*models.OrgMember
+	creds    map[uuid.UUID]*models.ProviderCredential
+	keys     map[uuid.UUID]*models.AccessKey
+	proxyLog []*models.ProxyLog
+	usage    map[uuid.UUID]*models.UsageEntry
+	accounts map[uuid.UUID]*models.UsageAccount
 	images   map[uuid.UUID]*models.Image
+	gens     map[uuid.UUID]*models.Generation
+	prompts  map[uuid.UUID]*models.PromptTemplate
+	scenes   map[uuid.UUID]*models.Scene
+	refs     map[uuid.UUID]*models.ReferenceAsset
+	jobs     map[uuid.UUID]*models.Job
+	sessions map[uuid.UUID]*models.AuthSession
+	refresh  map[uuid.UUID]*models.RefreshToken
+	revoked  map[string]time.Time
 	mu       sync.RWMutex
 }
 
@@ -23,7 +42,22 @@ func GetMemoryStorage() *MemoryStorage {
 		instance = &MemoryStorage{
 			users:    make(map[uuid.UUID]*models.User),
 			projects: make(map[uuid.UUID]*models.Project),
+			members:  make(map[uuid.UUID]*models.ProjectMember),
+			orgs:     make(map[uuid.UUID]*models.Organization),
+			orgUsers: make(map[uuid.UUID]*models.OrgMember),
+			creds:    make(map[uuid.UUID]*models.ProviderCredential),
+			keys:     make(map[uuid.UUID]*models.AccessKey),
+			usage:    make(map[uuid.UUID]*models.UsageEntry),
+			accounts: make(map[uuid.UUID]*models.UsageAccount),
 			images:   make(map[uuid.UUID]*models.Image),
+			gens:     make(map[uuid.UUID]*models.Generation),
+			prompts:  make(map[uuid.UUID]*models.PromptTemplate),
+			scenes:   make(map[uuid.UUID]*models.Scene),
+			refs:     make(map[uuid.UUID]*models.ReferenceAsset),
+			jobs:     make(map[uuid.UUID]*models.Job),
+			sessions: make(map[uuid.UUID]*models.AuthSession),
+			refresh:  make(map[uuid.UUID]*models.RefreshToken),
+			revoked:  make(map[string]time.Time),
 		}
 	})
 	return instance
@@ -36,6 +70,11 @@ func (s *MemoryStorage) CreateUser(user *models.User) error {
 	if user.ID == uuid.Nil {
 		user.ID = uuid.New()
 	}
+	// 与 GORM 的 default 标签一致：新用户默认启用，角色为 user
+	user.IsActive = true
+	if user.Role == "" {
+		user.Role = models.UserRoleUser
+	}
 	s.users[user.ID] = user
 	return nil
 }
@@ -62,6 +101,18 @@ func (s *MemoryStorage) GetUserByEmail(email string) (*models.User, error) {
 	return nil, nil
 }
 
+func (s *MemoryStorage) GetUserByUsername(username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	for _, user := range s.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
 func (s *MemoryStorage) UpdateUser(user *models.User) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -107,6 +158,19 @@ func (s *MemoryStorage) GetProjectsByUserID(userID uuid.UUID) ([]*models.Project
 	return projects, nil
 }
 
+func (s *MemoryStorage) GetProjectsByOrgID(orgID uuid.UUID) ([]*models.Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var projects []*models.Project
+	for _, project := range s.projects {
+		if project.OrgID != nil && *project.OrgID == orgID {
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
 func (s *MemoryStorage) UpdateProject(project *models.Project) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -126,55 +190,993 @@ func (s *MemoryStorage) DeleteProject(id uuid.UUID) error {
 	return nil
 }
 
-func (s *MemoryStorage) CreateImage(image *models.Image) error {
+func (s *MemoryStorage) CreateProjectMember(member *models.ProjectMember) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
-	if image.ID == uuid.Nil {
-		image.ID = uuid.New()
+	if member.ID == uuid.Nil {
+		member.ID = uuid.New()
 	}
-	s.images[image.ID] = image
+	s.members[member.ID] = member
 	return nil
 }
 
-func (s *MemoryStorage) GetImageByID(id uuid.UUID) (*models.Image, error) {
+func (s *MemoryStorage) GetProjectMember(projectID, userID uuid.UUID) (*models.ProjectMember, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
-	if image, exists := s.images[id]; exists {
-		return image, nil
+	for _, member := range s.members {
+		if member.ProjectID == projectID && member.UserID == userID {
+			return member, nil
+		}
 	}
 	return nil, nil
 }
 
-func (s *MemoryStorage) GetImagesByProjectID(projectID uuid.UUID) ([]*models.Image, error) {
+func (s *MemoryStorage) GetProjectMembers(projectID uuid.UUID) ([]*models.ProjectMember, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
-	var images []*models.Image
-	for _, image := range s.images {
-		if image.ProjectID == projectID {
-			images = append(images, image)
+	var members []*models.ProjectMember
+	for _, member := range s.members {
+		if member.ProjectID == projectID {
+			members = append(members, member)
 		}
 	}
-	return images, nil
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].CreatedAt.Before(members[j].CreatedAt)
+	})
+	return members, nil
 }
 
-func (s *MemoryStorage) UpdateImage(image *models.Image) error {
+func (s *MemoryStorage) GetMembershipsByUserID(userID uuid.UUID) ([]*models.ProjectMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var members []*models.ProjectMember
+	for _, member := range s.members {
+		if member.UserID == userID {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (s *MemoryStorage) UpdateProjectMember(member *models.ProjectMember) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
-	if _, exists := s.images[image.ID]; !exists {
+	if _, exists := s.members[member.ID]; !exists {
 		return nil
 	}
-	s.images[image.ID] = image
+	s.members[member.ID] = member
 	return nil
 }
 
-func (s *MemoryStorage) DeleteImage(id uuid.UUID) error {
+func (s *MemoryStorage) DeleteProjectMember(id uuid.UUID) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
-	delete(s.images, id)
+	delete(s.members, id)
+	return nil
+}
+
+func (s *MemoryStorage) CreateOrganization(org *models.Organization) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if org.ID == uuid.Nil {
+		org.ID = uuid.New()
+	}
+	s.orgs[org.ID] = org
+	return nil
+}
+
+func (s *MemoryStorage) GetOrganizationByID(id uuid.UUID) (*models.Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if org, exists := s.orgs[id]; exists {
+		return org, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) UpdateOrganization(org *models.Organization) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.orgs[org.ID]; !exists {
+		return nil
+	}
+	s.orgs[org.ID] = org
+	return nil
+}
+
+func (s *MemoryStorage) DeleteOrganization(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.orgs, id)
+	return nil
+}
+
+func (s *MemoryStorage) CreateOrgMember(member *models.OrgMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if member.ID == uuid.Nil {
+		member.ID = uuid.New()
+	}
+	s.orgUsers[member.ID] = member
+	return nil
+}
+
+func (s *MemoryStorage) GetOrgMember(orgID, userID uuid.UUID) (*models.OrgMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	for _, member := range s.orgUsers {
+		if member.OrgID == orgID && member.UserID == userID {
+			return member, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetOrgMembers(orgID uuid.UUID) ([]*models.OrgMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var members []*models.OrgMember
+	for _, member := range s.orgUsers {
+		if member.OrgID == orgID {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].CreatedAt.Before(members[j].CreatedAt)
+	})
+	return members, nil
+}
+
+func (s *MemoryStorage) GetOrgMembershipsByUserID(userID uuid.UUID) ([]*models.OrgMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var members []*models.OrgMember
+	for _, member := range s.orgUsers {
+		if member.UserID == userID {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (s *MemoryStorage) UpdateOrgMember(member *models.OrgMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.orgUsers[member.ID]; !exists {
+		return nil
+	}
+	s.orgUsers[member.ID] = member
+	return nil
+}
+
+func (s *MemoryStorage) DeleteOrgMember(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.orgUsers, id)
+	return nil
+}
+
+func (s *MemoryStorage) CreateCredential(cred *models.ProviderCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if cred.ID == uuid.Nil {
+		cred.ID = uuid.New()
+	}
+	now := time.Now()
+	cred.CreatedAt, cred.UpdatedAt = now, now
+	s.creds[cred.ID] = cred
 	return nil
-}
\ No newline at end of file
+}
+
+func (s *MemoryStorage) GetCredentialByID(id uuid.UUID) (*models.ProviderCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if cred, exists := s.creds[id]; exists {
+		return cred, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetCredential(userID uuid.UUID, provider string) (*models.ProviderCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	for _, cred := range s.creds {
+		if cred.UserID == userID && cred.Provider == provider {
+			return cred, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetCredentialsByUserID(userID uuid.UUID) ([]*models.ProviderCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var creds []*models.ProviderCredential
+	for _, cred := range s.creds {
+		if cred.UserID == userID {
+			creds = append(creds, cred)
+		}
+	}
+	sort.Slice(creds, func(i, j int) bool {
+		return creds[i].Provider < creds[j].Provider
+	})
+	return creds, nil
+}
+
+func (s *MemoryStorage) UpdateCredential(cred *models.ProviderCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.creds[cred.ID]; !exists {
+		return nil
+	}
+	cred.UpdatedAt = time.Now()
+	s.creds[cred.ID] = cred
+	return nil
+}
+
+func (s *MemoryStorage) DeleteCredential(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.creds, id)
+	return nil
+}
+
+func (s *MemoryStorage) CreateAccessKey(key *models.AccessKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if key.ID == uuid.Nil {
+		key.ID = uuid.New()
+	}
+	now := time.Now()
+	key.CreatedAt, key.UpdatedAt = now, now
+	s.keys[key.ID] = key
+	return nil
+}
+
+func (s *MemoryStorage) GetAccessKeyByID(id uuid.UUID) (*models.AccessKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if key, exists := s.keys[id]; exists {
+		return key, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetAccessKeyByHash(hash string) (*models.AccessKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	for _, key := range s.keys {
+		if key.KeyHash == hash {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetAccessKeysByUserID(userID uuid.UUID) ([]*models.AccessKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var keys []*models.AccessKey
+	for _, key := range s.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (s *MemoryStorage) UpdateAccessKey(key *models.AccessKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.keys[key.ID]; !exists {
+		return nil
+	}
+	key.UpdatedAt = time.Now()
+	s.keys[key.ID] = key
+	return nil
+}
+
+func (s *MemoryStorage) DeleteAccessKey(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.keys, id)
+	return nil
+}
+
+// maxMemoryProxyLogs 内存存储只保留最近的代理记录
+const maxMemoryProxyLogs = 10000
+
+func (s *MemoryStorage) CreateProxyLog(entry *models.ProxyLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	s.proxyLog = append(s.proxyLog, entry)
+	if len(s.proxyLog) > maxMemoryProxyLogs {
+		s.proxyLog = s.proxyLog[len(s.proxyLog)-maxMemoryProxyLogs:]
+	}
+	return nil
+}
+
+func (s *MemoryStorage) GetProxyLogsByUserID(userID uuid.UUID, limit int) ([]*models.ProxyLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var entries []*models.ProxyLog
+	for i := len(s.proxyLog) - 1; i >= 0 && len(entries) < limit; i-- {
+		if entry := s.proxyLog[i]; entry.UserID != nil && *entry.UserID == userID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (s *MemoryStorage) CreateUsageEntry(entry *models.UsageEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	now := time.Now()
+	entry.CreatedAt, entry.UpdatedAt = now, now
+	s.usage[entry.ID] = entry
+	return nil
+}
+
+func (s *MemoryStorage) UpdateUsageEntry(entry *models.UsageEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.usage[entry.ID]; !exists {
+		return nil
+	}
+	entry.UpdatedAt = time.Now()
+	s.usage[entry.ID] = entry
+	return nil
+}
+
+func (s *MemoryStorage) GetUsageEntries(filter UsageFilter) ([]*models.UsageEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var entries []*models.UsageEntry
+	for _, entry := range s.usage {
+		if filter.UserID != nil && entry.UserID != *filter.UserID {
+			continue
+		}
+		if filter.OrgID != nil && (entry.OrgID == nil || *entry.OrgID != *filter.OrgID) {
+			continue
+		}
+		if filter.ProjectID != nil && (entry.ProjectID == nil || *entry.ProjectID != *filter.ProjectID) {
+			continue
+		}
+		if !filter.Since.IsZero() && entry.CreatedAt.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !entry.CreatedAt.Before(filter.Until) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+func (s *MemoryStorage) CountImagesSince(userID uuid.UUID, since time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	count := 0
+	for _, entry := range s.usage {
+		if entry.UserID != userID || entry.CreatedAt.Before(since) {
+			continue
+		}
+		switch entry.Status {
+		case "pending":
+			count += entry.N
+		case "success":
+			count += entry.Images
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStorage) GetUsageAccount(userID uuid.UUID) (*models.UsageAccount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if account, exists := s.accounts[userID]; exists {
+		copied := *account
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) CreateUsageAccount(account *models.UsageAccount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.accounts[account.UserID]; exists {
+		return errors.New("usage account already exists")
+	}
+	now := time.Now()
+	account.CreatedAt, account.UpdatedAt = now, now
+	copied := *account
+	s.accounts[account.UserID] = &copied
+	return nil
+}
+
+// UpdateUsageAccount 只更新配额，余额必须通过 AdjustCredits 修改
+func (s *MemoryStorage) UpdateUsageAccount(account *models.UsageAccount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	existing, exists := s.accounts[account.UserID]
+	if !exists {
+		return nil
+	}
+	existing.MonthlyImageQuota = account.MonthlyImageQuota
+	existing.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStorage) AdjustCredits(userID uuid.UUID, delta int64, allowNegative bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	account, exists := s.accounts[userID]
+	if !exists {
+		return false, nil
+	}
+	if !allowNegative && delta < 0 && account.CreditMicros+delta < 0 {
+		return false, nil
+	}
+	account.CreditMicros += delta
+	account.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (s *MemoryStorage) CreateImage(image *models.Image) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if image.ID == uuid.Nil {
+		image.ID = uuid.New()
+	}
+	s.images[image.ID] = image
+	return nil
+}
+
+func (s *MemoryStorage) GetImageByID(id uuid.UUID) (*models.Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if image, exists := s.images[id]; exists {
+		return image, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetImagesByProjectID(projectID uuid.UUID) ([]*models.Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var images []*models.Image
+	for _, image := range s.images {
+		if image.ProjectID == projectID {
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+func (s *MemoryStorage) GetImagesByJobID(jobID uuid.UUID) ([]*models.Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var images []*models.Image
+	for _, image := range s.images {
+		if image.JobID != nil && *image.JobID == jobID {
+			images = append(images, image)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].CreatedAt.Before(images[j].CreatedAt)
+	})
+	return images, nil
+}
+
+func (s *MemoryStorage) GetImagesByGenerationID(generationID uuid.UUID) ([]*models.Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var images []*models.Image
+	for _, image := range s.images {
+		if image.GenerationID != nil && *image.GenerationID == generationID {
+			images = append(images, image)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].OutputIndex < images[j].OutputIndex
+	})
+	return images, nil
+}
+
+func (s *MemoryStorage) GetImagesByParentID(parentID uuid.UUID) ([]*models.Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var images []*models.Image
+	for _, image := range s.images {
+		if image.ParentImageID != nil && *image.ParentImageID == parentID {
+			images = append(images, image)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].CreatedAt.Before(images[j].CreatedAt)
+	})
+	return images, nil
+}
+
+func (s *MemoryStorage) GetImagesByBlobKey(key string) ([]*models.Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var images []*models.Image
+	for _, image := range s.images {
+		if key != "" && image.BlobKey == key {
+			images = append(images, image)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].CreatedAt.After(images[j].CreatedAt)
+	})
+	return images, nil
+}
+
+func (s *MemoryStorage) UpdateImage(image *models.Image) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.images[image.ID]; !exists {
+		return nil
+	}
+	s.images[image.ID] = image
+	return nil
+}
+
+func (s *MemoryStorage) DeleteImage(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.images, id)
+	return nil
+}
+
+func (s *MemoryStorage) CreateGeneration(generation *models.Generation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if generation.ID == uuid.Nil {
+		generation.ID = uuid.New()
+	}
+	s.gens[generation.ID] = generation
+	return nil
+}
+
+func (s *MemoryStorage) GetGenerationByID(id uuid.UUID) (*models.Generation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if generation, exists := s.gens[id]; exists {
+		return generation, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) CreateTemplate(template *models.PromptTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for _, existing := range s.prompts {
+		if existing.UserID == template.UserID && existing.Name == template.Name {
+			return errors.New("template name already exists")
+		}
+	}
+	if template.ID == uuid.Nil {
+		template.ID = uuid.New()
+	}
+	s.prompts[template.ID] = template
+	return nil
+}
+
+func (s *MemoryStorage) GetTemplateByID(id uuid.UUID) (*models.PromptTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if template, exists := s.prompts[id]; exists {
+		return template, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetTemplateByName(userID uuid.UUID, name string) (*models.PromptTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	for _, template := range s.prompts {
+		if template.UserID == userID && template.Name == name {
+			return template, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetTemplatesByUserID(userID uuid.UUID) ([]*models.PromptTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var templates []*models.PromptTemplate
+	for _, template := range s.prompts {
+		if template.UserID == userID {
+			templates = append(templates, template)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+func (s *MemoryStorage) UpdateTemplate(template *models.PromptTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.prompts[template.ID]; !exists {
+		return nil
+	}
+	for _, existing := range s.prompts {
+		if existing.ID != template.ID && existing.UserID == template.UserID && existing.Name == template.Name {
+			return errors.New("template name already exists")
+		}
+	}
+	s.prompts[template.ID] = template
+	return nil
+}
+
+func (s *MemoryStorage) DeleteTemplate(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.prompts, id)
+	return nil
+}
+
+func (s *MemoryStorage) CreateScene(scene *models.Scene) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if scene.ID == uuid.Nil {
+		scene.ID = uuid.New()
+	}
+	s.scenes[scene.ID] = scene
+	return nil
+}
+
+func (s *MemoryStorage) GetSceneByID(id uuid.UUID) (*models.Scene, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if scene, exists := s.scenes[id]; exists {
+		return scene, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetScenesByProjectID(projectID uuid.UUID) ([]*models.Scene, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var scenes []*models.Scene
+	for _, scene := range s.scenes {
+		if scene.ProjectID == projectID {
+			scenes = append(scenes, scene)
+		}
+	}
+	sort.Slice(scenes, func(i, j int) bool {
+		return scenes[i].OrderIndex < scenes[j].OrderIndex
+	})
+	return scenes, nil
+}
+
+func (s *MemoryStorage) UpdateScene(scene *models.Scene) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.scenes[scene.ID]; exists {
+		s.scenes[scene.ID] = scene
+	}
+	return nil
+}
+
+func (s *MemoryStorage) DeleteScene(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for _, image := range s.images {
+		if image.SceneID != nil && *image.SceneID == id {
+			image.SceneID = nil
+			image.UpdatedAt = time.Now()
+		}
+	}
+	delete(s.scenes, id)
+	return nil
+}
+
+func (s *MemoryStorage) SetSceneOrder(projectID uuid.UUID, ids []uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for i, id := range ids {
+		if scene, exists := s.scenes[id]; exists && scene.ProjectID == projectID {
+			scene.OrderIndex = i
+			scene.UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) SelectSceneImage(sceneID, imageID uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	scene, exists := s.scenes[sceneID]
+	if !exists || scene.SelectedImageID != nil {
+		return false, nil
+	}
+	scene.SelectedImageID = &imageID
+	scene.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (s *MemoryStorage) ClearSceneImage(imageID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for _, scene := range s.scenes {
+		if scene.SelectedImageID != nil && *scene.SelectedImageID == imageID {
+			scene.SelectedImageID = nil
+			scene.UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) CreateReference(ref *models.ReferenceAsset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if ref.ID == uuid.Nil {
+		ref.ID = uuid.New()
+	}
+	s.refs[ref.ID] = ref
+	return nil
+}
+
+func (s *MemoryStorage) GetReferenceByID(id uuid.UUID) (*models.ReferenceAsset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if ref, exists := s.refs[id]; exists {
+		return ref, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) GetReferencesByProjectID(projectID uuid.UUID) ([]*models.ReferenceAsset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var refs []*models.ReferenceAsset
+	for _, ref := range s.refs {
+		if ref.ProjectID == projectID {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].CreatedAt.Before(refs[j].CreatedAt)
+	})
+	return refs, nil
+}
+
+func (s *MemoryStorage) UpdateReference(ref *models.ReferenceAsset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.refs[ref.ID]; exists {
+		s.refs[ref.ID] = ref
+	}
+	return nil
+}
+
+func (s *MemoryStorage) DeleteReference(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	delete(s.refs, id)
+	return nil
+}
+
+func (s *MemoryStorage) DeleteReferencesByImageID(imageID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for id, ref := range s.refs {
+		if ref.ImageID == imageID {
+			delete(s.refs, id)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) CreateJob(job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *MemoryStorage) GetJobByID(id uuid.UUID) (*models.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if job, exists := s.jobs[id]; exists {
+		return job, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) UpdateJob(job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.jobs[job.ID]; !exists {
+		return nil
+	}
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *MemoryStorage) CreateSession(session *models.AuthSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if session.ID == uuid.Nil {
+		session.ID = uuid.New()
+	}
+	s.sessions[session.ID] = session
+	return nil
+}
+
+func (s *MemoryStorage) GetSessionByID(id uuid.UUID) (*models.AuthSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if session, exists := s.sessions[id]; exists {
+		return session, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) UpdateSession(session *models.AuthSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if _, exists := s.sessions[session.ID]; !exists {
+		return nil
+	}
+	s.sessions[session.ID] = session
+	return nil
+}
+
+func (s *MemoryStorage) CreateRefreshToken(token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	s.refresh[token.ID] = token
+	return nil
+}
+
+func (s *MemoryStorage) GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	for _, token := range s.refresh {
+		if token.TokenHash == hash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStorage) MarkRefreshTokenUsed(id uuid.UUID, usedAt time.Time, replacedBy uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	token, exists := s.refresh[id]
+	if !exists || token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &usedAt
+	token.ReplacedBy = &replacedBy
+	return true, nil
+}
+
+func (s *MemoryStorage) RevokeAccessToken(token *models.RevokedToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	for jti, expiresAt := range s.revoked {
+		if expiresAt.Before(now) {
+			delete(s.revoked, jti)
+		}
+	}
+	s.revoked[token.JTI] = token.ExpiresAt
+	return nil
+}
+
+func (s *MemoryStorage) IsAccessTokenRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	_, revoked := s.revoked[jti]
+	return revoked, nil
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"time"
+
+	"ai-design-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// Store 是处理器依赖的存储接口，内存与 GORM 实现都满足它。
+// 约定：按 ID 查询不到记录时返回 (nil, nil)，而不是错误。
+type Store interface {
+	UserStore
+	ProjectStore
+	MemberStore
+	OrgStore
+	CredentialStore
+	AccessKeyStore
+	ProxyLogStore
+	UsageStore
+	ImageStore
+	GenerationStore
+	TemplateStore
+	SceneStore
+	ReferenceStore
+	JobStore
+	TokenStore
+}
+
+type UserStore interface {
+	CreateUser(user *models.User) error
+	GetUserByID(id uuid.UUID) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+	UpdateUser(user *models.User) error
+}
+
+type ProjectStore interface {
+	CreateProject(project *models.Project) error
+	GetProjectByID(id uuid.UUID) (*models.Project, error)
+	GetProjectsByUserID(userID uuid.UUID) ([]*models.Project, error)
+	GetProjectsByOrgID(orgID uuid.UUID) ([]*models.Project, error)
+	UpdateProject(project *models.Project) error
+	DeleteProject(id uuid.UUID) error
+}
+
+type OrgStore interface {
+	CreateOrganization(org *models.Organization) error
+	GetOrganizationByID(id uuid.UUID) (*models.Organization, error)
+	UpdateOrganization(org *models.Organization) error
+	DeleteOrganization(id uuid.UUID) error
+	CreateOrgMember(member *models.OrgMember) error
+	GetOrgMember(orgID, userID uuid.UUID) (*models.OrgMember, error)
+	GetOrgMembers(orgID uuid.UUID) ([]*models.OrgMember, error)
+	GetOrgMembershipsByUserID(userID uuid.UUID) ([]*models.OrgMember, error)
+	UpdateOrgMember(member *models.OrgMember) error
+	DeleteOrgMember(id uuid.UUID) error
+}
+
+type CredentialStore interface {
+	CreateCredential(cred *models.ProviderCredential) error
+	GetCredentialByID(id uuid.UUID) (*models.ProviderCredential, error)
+	GetCredential(userID uuid.UUID, provider string) (*models.ProviderCredential, error)
+	GetCredentialsByUserID(userID uuid.UUID) ([]*models.ProviderCredential, error)
+	UpdateCredential(cred *models.ProviderCredential) error
+	DeleteCredential(id uuid.UUID) error
+}
+
+type AccessKeyStore interface {
+	CreateAccessKey(key *models.AccessKey) error
+	GetAccessKeyByID(id uuid.UUID) (*models.AccessKey, error)
+	GetAccessKeyByHash(hash string) (*models.AccessKey, error)
+	GetAccessKeysByUserID(userID uuid.UUID) ([]*models.AccessKey, error)
+	UpdateAccessKey(key *models.AccessKey) error
+	DeleteAccessKey(id uuid.UUID) error
+}
+
+type ProxyLogStore interface {
+	CreateProxyLog(entry *models.ProxyLog) error
+	// GetProxyLogsByUserID 按时间倒序返回最近的 limit 条记录
+	GetProxyLogsByUserID(userID uuid.UUID, limit int) ([]*models.ProxyLog, error)
+}
+
+// UsageFilter 用量查询条件，为空的字段不参与过滤
+type UsageFilter struct {
+	UserID    *uuid.UUID
+	OrgID     *uuid.UUID
+	ProjectID *uuid.UUID
+	Since     time.Time
+	Until     time.Time
+}
+
+type UsageStore interface {
+	CreateUsageEntry(entry *models.UsageEntry) error
+	UpdateUsageEntry(entry *models.UsageEntry) error
+	GetUsageEntries(filter UsageFilter) ([]*models.UsageEntry, error)
+	// CountImagesSince 统计用户自 since 起的图片数，进行中的调用按请求数计入
+	CountImagesSince(userID uuid.UUID, since time.Time) (int, error)
+
+	GetUsageAccount(userID uuid.UUID) (*models.UsageAccount, error)
+	CreateUsageAccount(account *models.UsageAccount) error
+	UpdateUsageAccount(account *models.UsageAccount) error
+	// AdjustCredits 原子地增减余额；allowNegative 为 false 时余额不足返回 false
+	AdjustCredits(userID uuid.UUID, delta int64, allowNegative bool) (bool, error)
+}
+
+type MemberStore interface {
+	CreateProjectMember(member *models.ProjectMember) error
+	GetProjectMember(projectID, userID uuid.UUID) (*models.ProjectMember, error)
+	GetProjectMembers(projectID uuid.UUID) ([]*models.ProjectMember, error)
+	GetMembershipsByUserID(userID uuid.UUID) ([]*models.ProjectMember, error)
+	UpdateProjectMember(member *models.ProjectMember) error
+	DeleteProjectMember(id uuid.UUID) error
+}
+
+type ImageStore interface {
+	CreateImage(image *models.Image) error
+	GetImageByID(id uuid.UUID) (*models.Image, error)
+	GetImagesByProjectID(projectID uuid.UUID) ([]*models.Image, error)
+	GetImagesByJobID(jobID uuid.UUID) ([]*models.Image, error)
+	// GetImagesByGenerationID 按 OutputIndex 排序
+	GetImagesByGenerationID(generationID uuid.UUID) ([]*models.Image, error)
+	GetImagesByParentID(parentID uuid.UUID) ([]*models.Image, error)
+	// GetImagesByBlobKey 内容相同的图片，按创建时间倒序
+	GetImagesByBlobKey(key string) ([]*models.Image, error)
+	UpdateImage(image *models.Image) error
+	DeleteImage(id uuid.UUID) error
+}
+
+type GenerationStore interface {
+	CreateGeneration(generation *models.Generation) error
+	GetGenerationByID(id uuid.UUID) (*models.Generation, error)
+}
+
+type TemplateStore interface {
+	CreateTemplate(template *models.PromptTemplate) error
+	GetTemplateByID(id uuid.UUID) (*models.PromptTemplate, error)
+	GetTemplateByName(userID uuid.UUID, name string) (*models.PromptTemplate, error)
+	GetTemplatesByUserID(userID uuid.UUID) ([]*models.PromptTemplate, error)
+	UpdateTemplate(template *models.PromptTemplate) error
+	DeleteTemplate(id uuid.UUID) error
+}
+
+type SceneStore interface {
+	CreateScene(scene *models.Scene) error
+	GetSceneByID(id uuid.UUID) (*models.Scene, error)
+	// GetScenesByProjectID 按 OrderIndex 排序
+	GetScenesByProjectID(projectID uuid.UUID) ([]*models.Scene, error)
+	UpdateScene(scene *models.Scene) error
+	// DeleteScene 删除镜头，并清除其图片上的 scene_id，图片本身保留
+	DeleteScene(id uuid.UUID) error
+	// SetSceneOrder 按 ids 的顺序重写项目中镜头的 OrderIndex
+	SetSceneOrder(projectID uuid.UUID, ids []uuid.UUID) error
+	// SelectSceneImage 仅在镜头尚未选用画面时设置，返回是否设置成功
+	SelectSceneImage(sceneID, imageID uuid.UUID) (bool, error)
+	// ClearSceneImage 图片被删除时，取消所有选用它的镜头
+	ClearSceneImage(imageID uuid.UUID) error
+}
+
+type ReferenceStore interface {
+	CreateReference(ref *models.ReferenceAsset) error
+	GetReferenceByID(id uuid.UUID) (*models.ReferenceAsset, error)
+	// GetReferencesByProjectID 按创建时间排序
+	GetReferencesByProjectID(projectID uuid.UUID) ([]*models.ReferenceAsset, error)
+	UpdateReference(ref *models.ReferenceAsset) error
+	DeleteReference(id uuid.UUID) error
+	// DeleteReferencesByImageID 图片被删除时移除引用它的参考素材
+	DeleteReferencesByImageID(imageID uuid.UUID) error
+}
+
+type JobStore interface {
+	CreateJob(job *models.Job) error
+	GetJobByID(id uuid.UUID) (*models.Job, error)
+	UpdateJob(job *models.Job) error
+}
+
+type TokenStore interface {
+	CreateSession(session *models.AuthSession) error
+	GetSessionByID(id uuid.UUID) (*models.AuthSession, error)
+	UpdateSession(session *models.AuthSession) error
+
+	CreateRefreshToken(token *models.RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*models.RefreshToken, error)
+	// MarkRefreshTokenUsed 原子地将未使用的令牌标记为已使用；
+	// 返回 false 表示令牌已被使用过（并发刷新或重放）
+	MarkRefreshTokenUsed(id uuid.UUID, usedAt time.Time, replacedBy uuid.UUID) (bool, error)
+
+	RevokeAccessToken(token *models.RevokedToken) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+}
+
+var (
+	_ Store = (*MemoryStorage)(nil)
+	_ Store = (*GormStorage)(nil)
+)
@@ -0,0 +1,111 @@
+package upstream
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+// BreakerStatus 熔断器状态
+type BreakerStatus struct {
+	Provider    string     `json:"provider"`
+	State       string     `json:"state"`
+	Failures    int        `json:"consecutive_failures"`
+	OpenUntil   *time.Time `json:"open_until,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+}
+
+// breaker 连续失败达到阈值后熔断，冷却结束后放行一个探测请求（half-open），
+// 探测成功则恢复，失败则重新熔断
+type breaker struct {
+	mu          sync.Mutex
+	failures    int
+	openUntil   time.Time
+	probing     bool
+	lastFailure time.Time
+}
+
+func (c *Client) breaker(name string) *breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[name]
+	if !ok {
+		b = &breaker{}
+		c.breakers[name] = b
+	}
+	return b
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.probing = false
+}
+
+func (b *breaker) failure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.failures++
+	b.lastFailure = now
+	if b.probing || b.failures >= threshold {
+		b.openUntil = now.Add(cooldown)
+	}
+	b.probing = false
+}
+
+// release 探测请求被调用方取消时，让下一个请求继续探测
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) status(name string) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := BreakerStatus{Provider: name, State: StateClosed, Failures: b.failures}
+	if !b.openUntil.IsZero() {
+		s.State = StateHalfOpen
+		if time.Now().Before(b.openUntil) {
+			s.State = StateOpen
+		}
+		until := b.openUntil
+		s.OpenUntil = &until
+	}
+	if !b.lastFailure.IsZero() {
+		last := b.lastFailure
+		s.LastFailure = &last
+	}
+	return s
+}
+
+func sortStatus(list []BreakerStatus) {
+	sort.Slice(list, func(i, j int) bool { return list[i].Provider < list[j].Provider })
+}
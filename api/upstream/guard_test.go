@@ -0,0 +1,70 @@
+package upstream
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.100.100.200", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"fd00:ec2::254", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("IsPublicAddr(%s) = %v, want %v", tt.addr, got, tt.public)
+		}
+	}
+}
+
+func TestPublicOnlyRejectsLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	opts := Options{Timeout: 5 * time.Second, ConnectTimeout: time.Second, MaxRetries: 2, RetryBaseDelay: time.Second, RetryMaxDelay: time.Second}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := New(opts).Do("", req)
+	if err != nil {
+		t.Fatalf("default client: %v", err)
+	}
+	resp.Body.Close()
+
+	opts.PublicOnly = true
+	start := time.Now()
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	if _, err := New(opts).Do("", req); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("public-only client: got %v, want ErrForbiddenAddress", err)
+	}
+	// 被拒绝的地址不应重试
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("forbidden address was retried (took %v)", elapsed)
+	}
+}
@@ -0,0 +1,228 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ai-design-backend/config"
+)
+
+// ErrCircuitOpen provider 连续失败后熔断，冷却期内的调用直接失败
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Options 上游客户端的超时、重试与熔断参数
+type Options struct {
+	Timeout             time.Duration // 单次请求的总超时
+	ConnectTimeout      time.Duration
+	MaxIdleConnsPerHost int
+	MaxRetries          int
+	RetryBaseDelay      time.Duration
+	RetryMaxDelay       time.Duration // 退避与 Retry-After 的上限，上游要求等待更久时不再重试
+	BreakerThreshold    int           // 连续失败多少次后熔断，0 表示不熔断
+	BreakerCooldown     time.Duration
+	// PublicOnly 只允许连接公网地址，用于拉取调用方提供的 URL
+	PublicOnly bool
+}
+
+// Client 所有上游调用共用的 HTTP 客户端：连接池、超时、带抖动的指数退避重试，
+// 以及按 provider 区分的熔断器
+type Client struct {
+	http *http.Client
+	opts Options
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+var (
+	Default *Client
+	// Fetch 按 URL 拉取图片等调用方可控的地址，拒绝连接内网与元数据地址
+	Fetch *Client
+)
+
+func Init() {
+	cfg := config.Config
+	opts := Options{
+		Timeout:             cfg.UpstreamTimeout,
+		ConnectTimeout:      cfg.UpstreamConnectTimeout,
+		MaxIdleConnsPerHost: cfg.UpstreamMaxIdleConns,
+		MaxRetries:          cfg.UpstreamMaxRetries,
+		RetryBaseDelay:      cfg.UpstreamRetryBaseDelay,
+		RetryMaxDelay:       cfg.UpstreamRetryMaxDelay,
+		BreakerThreshold:    cfg.BreakerThreshold,
+		BreakerCooldown:     cfg.BreakerCooldown,
+	}
+	Default = New(opts)
+
+	opts.PublicOnly = !cfg.ImageFetchAllowPrivate
+	Fetch = New(opts)
+}
+
+func New(opts Options) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: opts.ConnectTimeout, KeepAlive: 30 * time.Second}
+	if opts.PublicOnly {
+		// 在连接前检查解析后的地址，DNS 重绑定与重定向到内网同样会被拒绝；代理会绕过检查，因此不使用
+		dialer.Control = publicOnly
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = opts.ConnectTimeout
+	transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	if transport.MaxIdleConns < opts.MaxIdleConnsPerHost {
+		transport.MaxIdleConns = opts.MaxIdleConnsPerHost
+	}
+
+	return &Client{
+		http:     &http.Client{Timeout: opts.Timeout, Transport: transport},
+		opts:     opts,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// Do 发送请求。name 为 provider 名称，用于熔断与健康检查；为空时不经过熔断器（如拉取图片）。
+// 有请求体的请求需要能重放（http.NewRequest 使用 bytes.Reader 等时会自动设置 GetBody）。
+func (c *Client) Do(name string, req *http.Request) (*http.Response, error) {
+	var b *breaker
+	if name != "" && c.opts.BreakerThreshold > 0 {
+		b = c.breaker(name)
+		if !b.allow() {
+			return nil, fmt.Errorf("%s: %w", name, ErrCircuitOpen)
+		}
+	}
+
+	resp, err := c.doWithRetry(req)
+	if b != nil {
+		switch {
+		case err != nil && req.Context().Err() != nil:
+			// 调用方取消，不代表上游不可用
+			b.release()
+		case err != nil || resp.StatusCode >= 500:
+			b.failure(c.opts.BreakerThreshold, c.opts.BreakerCooldown)
+		default:
+			b.success()
+		}
+	}
+	return resp, err
+}
+
+func (c *Client) doWithRetry(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.http.Do(req)
+		if attempt >= c.opts.MaxRetries || !retryable(req, resp, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		delay := c.backoff(attempt)
+		if resp != nil {
+			if wait, ok := RetryAfter(resp.Header); ok {
+				if wait > c.opts.RetryMaxDelay {
+					return resp, err
+				}
+				delay = wait
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable 只重试上游明确没有处理请求的失败：429/503 以及连接阶段的错误；
+// GET 等幂等请求还会重试 502/504 与其他网络错误
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+	if err != nil {
+		if req.Context().Err() != nil || errors.Is(err, ErrForbiddenAddress) {
+			return false
+		}
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		return idempotent
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// backoff 全抖动的指数退避：[0, min(max, base*2^attempt))
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.opts.RetryBaseDelay << attempt
+	if ceiling <= 0 || ceiling > c.opts.RetryMaxDelay {
+		ceiling = c.opts.RetryMaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// RetryAfter 解析秒数或 HTTP 日期形式的 Retry-After
+func RetryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// Track 预先登记 provider，使其在第一次调用前也出现在健康检查中
+func (c *Client) Track(names ...string) {
+	for _, name := range names {
+		c.breaker(name)
+	}
+}
+
+// Status 各 provider 熔断器的当前状态，供健康检查展示
+func (c *Client) Status() []BreakerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	list := make([]BreakerStatus, 0, len(c.breakers))
+	for name, b := range c.breakers {
+		list = append(list, b.status(name))
+	}
+	sortStatus(list)
+	return list
+}
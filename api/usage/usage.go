@@ -0,0 +1,256 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrQuotaExceeded       = errors.New("monthly image quota exceeded")
+	ErrInsufficientCredits = errors.New("insufficient credits")
+)
+
+// Micros 每单位额度/金额对应的 micros
+const Micros = 1_000_000
+
+var (
+	prices         = map[string]int64{}
+	initialCredits int64
+)
+
+// Init 解析价格表与初始额度
+func Init() {
+	table, err := ParsePriceTable(config.Config.PriceTable)
+	if err != nil {
+		log.Fatal("Invalid PRICE_TABLE:", err)
+	}
+	prices = table
+
+	initialCredits, err = ParseAmount(config.Config.InitialCredits)
+	if err != nil {
+		log.Fatal("Invalid INITIAL_CREDITS:", err)
+	}
+}
+
+// ParseAmount 将十进制金额转换为 micros
+func ParseAmount(s string) (int64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return int64(math.Round(f * Micros)), nil
+}
+
+// ToAmount 将 micros 转换为十进制金额，用于接口展示
+func ToAmount(micros int64) float64 {
+	return float64(micros) / Micros
+}
+
+// ParsePriceTable 解析 model=price、model@size=price 形式的价格表
+func ParsePriceTable(entries []string) (map[string]int64, error) {
+	table := make(map[string]int64)
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid price entry %q", entry)
+		}
+		price, err := ParseAmount(value)
+		if err != nil || price < 0 {
+			return nil, fmt.Errorf("invalid price entry %q", entry)
+		}
+		table[strings.TrimSpace(key)] = price
+	}
+	return table, nil
+}
+
+// Price 单张图片的预估价格，依次匹配 model@size、model、*
+func Price(model, size string) int64 {
+	if price, ok := prices[model+"@"+size]; ok {
+		return price
+	}
+	if price, ok := prices[model]; ok {
+		return price
+	}
+	return prices["*"]
+}
+
+// Call 描述一次上游调用
+type Call struct {
+	UserID    uuid.UUID
+	ProjectID *uuid.UUID
+	OrgID     *uuid.UUID
+	Source    string // generate, batch, storyboard, edit, proxy
+	Provider  string
+	Model     string
+	Size      string
+	N         int
+	KeySource string
+}
+
+// chargesCredits 只有使用服务端 key 的调用才消耗额度
+func (call Call) chargesCredits() bool {
+	return config.Config.CreditsEnabled && call.KeySource == models.KeySourceServer
+}
+
+func (call Call) images() int {
+	if call.N < 1 {
+		return 1
+	}
+	return call.N
+}
+
+// MonthStart 当前自然月的起始时间（UTC）
+func MonthStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Account 返回用户的额度账户，不存在时按初始额度创建
+func Account(userID uuid.UUID) (*models.UsageAccount, error) {
+	account, err := config.Storage.GetUsageAccount(userID)
+	if err != nil || account != nil {
+		return account, err
+	}
+
+	account = &models.UsageAccount{UserID: userID, CreditMicros: initialCredits}
+	if err := config.Storage.CreateUsageAccount(account); err != nil {
+		// 并发创建时另一个请求已经写入
+		if existing, getErr := config.Storage.GetUsageAccount(userID); getErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+// QuotaLimit 用户每月可生成的图片数，0 表示不限
+func QuotaLimit(account *models.UsageAccount) int {
+	switch {
+	case account.MonthlyImageQuota < 0:
+		return 0
+	case account.MonthlyImageQuota > 0:
+		return account.MonthlyImageQuota
+	}
+	return config.Config.MonthlyImageQuota
+}
+
+func checkQuota(account *models.UsageAccount, n int) error {
+	limit := QuotaLimit(account)
+	if limit == 0 {
+		return nil
+	}
+	used, err := config.Storage.CountImagesSince(account.UserID, MonthStart(time.Now()))
+	if err != nil {
+		return err
+	}
+	if used+n > limit {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Check 在提交任务前预先检查配额与余额，不预留额度；
+// 真正的扣减在每次上游调用的 Begin 中完成
+func Check(call Call) error {
+	account, err := Account(call.UserID)
+	if err != nil {
+		return err
+	}
+	if err := checkQuota(account, call.images()); err != nil {
+		return err
+	}
+	if call.chargesCredits() && account.CreditMicros < Price(call.Model, call.Size)*int64(call.images()) {
+		return ErrInsufficientCredits
+	}
+	return nil
+}
+
+// Charge 一次进行中的上游调用
+type Charge struct {
+	call     Call
+	entry    *models.UsageEntry
+	reserved int64
+}
+
+// Begin 检查配额、按请求数预扣额度并写入 pending 记录，上游调用结束后必须调用 Finish
+func Begin(call Call) (*Charge, error) {
+	account, err := Account(call.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkQuota(account, call.images()); err != nil {
+		return nil, err
+	}
+
+	charge := &Charge{call: call}
+	if call.chargesCredits() {
+		charge.reserved = Price(call.Model, call.Size) * int64(call.images())
+		ok, err := config.Storage.AdjustCredits(call.UserID, -charge.reserved, false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrInsufficientCredits
+		}
+	}
+
+	charge.entry = &models.UsageEntry{
+		UserID:    call.UserID,
+		ProjectID: call.ProjectID,
+		OrgID:     call.OrgID,
+		Source:    call.Source,
+		Provider:  call.Provider,
+		Model:     call.Model,
+		Size:      call.Size,
+		N:         call.images(),
+		Status:    "pending",
+		KeySource: call.KeySource,
+	}
+	if err := config.Storage.CreateUsageEntry(charge.entry); err != nil {
+		charge.refund(charge.reserved)
+		return nil, err
+	}
+	return charge, nil
+}
+
+func (ch *Charge) refund(amount int64) {
+	if amount == 0 {
+		return
+	}
+	if _, err := config.Storage.AdjustCredits(ch.call.UserID, amount, true); err != nil {
+		log.Printf("usage: failed to adjust credits for %s: %v", ch.call.UserID, err)
+	}
+}
+
+// Finish 按实际返回的图片数结算：失败的调用不计费，多退少补。ch 为 nil 时什么也不做
+func (ch *Charge) Finish(images int, callErr error) {
+	if ch == nil {
+		return
+	}
+	entry := ch.entry
+	entry.Images = images
+	entry.Status = "success"
+	if callErr != nil {
+		entry.Status = "failed"
+		entry.Error = callErr.Error()
+		entry.Images = 0
+	}
+	entry.CostMicros = Price(entry.Model, entry.Size) * int64(entry.Images)
+
+	if ch.call.chargesCredits() {
+		ch.refund(ch.reserved - entry.CostMicros)
+	}
+	if err := config.Storage.UpdateUsageEntry(entry); err != nil {
+		log.Printf("usage: failed to update entry %s: %v", entry.ID, err)
+	}
+}
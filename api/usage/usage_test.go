@@ -0,0 +1,201 @@
+package usage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ai-design-backend/config"
+	"ai-design-backend/models"
+	"ai-design-backend/storage"
+
+	"github.com/google/uuid"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1", Micros},
+		{" 0.04 ", 40_000},
+		{"0.0000005", 1},
+		{"-2.5", -2_500_000},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAmount(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "abc", "NaN", "Inf", "1,5"} {
+		if _, err := ParseAmount(bad); err == nil {
+			t.Errorf("ParseAmount(%q) succeeded, want error", bad)
+		}
+	}
+	if got := ToAmount(1_250_000); got != 1.25 {
+		t.Errorf("ToAmount = %v, want 1.25", got)
+	}
+}
+
+func TestParsePriceTable(t *testing.T) {
+	table, err := ParsePriceTable([]string{"*=0.01", " gpt-image-1 = 0.04", "gpt-image-1@1536x1024=0.06"})
+	if err != nil {
+		t.Fatalf("ParsePriceTable: %v", err)
+	}
+	want := map[string]int64{"*": 10_000, "gpt-image-1": 40_000, "gpt-image-1@1536x1024": 60_000}
+	if len(table) != len(want) {
+		t.Fatalf("table = %v, want %v", table, want)
+	}
+	for key, price := range want {
+		if table[key] != price {
+			t.Errorf("table[%q] = %d, want %d", key, table[key], price)
+		}
+	}
+
+	for _, bad := range [][]string{{"gpt-image-1"}, {"gpt-image-1=free"}, {"gpt-image-1=-1"}} {
+		if _, err := ParsePriceTable(bad); err == nil {
+			t.Errorf("ParsePriceTable(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestPrice(t *testing.T) {
+	defer func(old map[string]int64) { prices = old }(prices)
+	prices = map[string]int64{"*": 1, "m": 2, "m@big": 3}
+
+	tests := []struct {
+		model, size string
+		want        int64
+	}{
+		{"m", "big", 3},
+		{"m", "small", 2},
+		{"other", "big", 1},
+	}
+	for _, tt := range tests {
+		if got := Price(tt.model, tt.size); got != tt.want {
+			t.Errorf("Price(%q, %q) = %d, want %d", tt.model, tt.size, got, tt.want)
+		}
+	}
+
+	prices = map[string]int64{}
+	if got := Price("m", "big"); got != 0 {
+		t.Errorf("Price without table = %d, want 0", got)
+	}
+}
+
+func TestMonthStart(t *testing.T) {
+	now := time.Date(2024, 3, 31, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*3600))
+	want := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	if got := MonthStart(now); !got.Equal(want) {
+		t.Errorf("MonthStart = %v, want %v", got, want)
+	}
+}
+
+func TestQuotaLimit(t *testing.T) {
+	setup(t)
+	config.Config.MonthlyImageQuota = 10
+	for _, tt := range []struct{ quota, want int }{{0, 10}, {-1, 0}, {3, 3}} {
+		if got := QuotaLimit(&models.UsageAccount{MonthlyImageQuota: tt.quota}); got != tt.want {
+			t.Errorf("QuotaLimit(%d) = %d, want %d", tt.quota, got, tt.want)
+		}
+	}
+}
+
+// setup 使用内存存储与按次计费的价格表，测试结束后恢复
+func setup(t *testing.T) {
+	t.Helper()
+	oldConfig, oldStorage, oldPrices, oldCredits := config.Config, config.Storage, prices, initialCredits
+	t.Cleanup(func() {
+		config.Config, config.Storage, prices, initialCredits = oldConfig, oldStorage, oldPrices, oldCredits
+	})
+	config.Config = &config.AppConfig{CreditsEnabled: true}
+	config.Storage = storage.GetMemoryStorage()
+	prices = map[string]int64{"*": Micros}
+	initialCredits = 3 * Micros
+}
+
+func credits(t *testing.T, userID uuid.UUID) int64 {
+	t.Helper()
+	account, err := Account(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account.CreditMicros
+}
+
+func TestChargeSettlesActualImages(t *testing.T) {
+	setup(t)
+	userID := uuid.New()
+	call := Call{UserID: userID, Source: "generate", Model: "m", N: 2, KeySource: models.KeySourceServer}
+
+	// 预扣 2 张，实际只返回 1 张，退回 1 张
+	ch, err := Begin(call)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if got := credits(t, userID); got != 1*Micros {
+		t.Fatalf("credits after Begin = %d, want %d", got, 1*Micros)
+	}
+	ch.Finish(1, nil)
+	if got := credits(t, userID); got != 2*Micros {
+		t.Fatalf("credits after Finish = %d, want %d", got, 2*Micros)
+	}
+	if ch.entry.Status != "success" || ch.entry.Images != 1 || ch.entry.CostMicros != Micros {
+		t.Errorf("entry = %+v", ch.entry)
+	}
+
+	// 失败的调用全额退回
+	ch, err = Begin(call)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	ch.Finish(0, errors.New("upstream down"))
+	if got := credits(t, userID); got != 2*Micros {
+		t.Errorf("credits after failed call = %d, want %d", got, 2*Micros)
+	}
+	if ch.entry.Status != "failed" || ch.entry.CostMicros != 0 {
+		t.Errorf("entry = %+v", ch.entry)
+	}
+
+	// 余额不足时不预扣
+	call.N = 3
+	if _, err := Begin(call); !errors.Is(err, ErrInsufficientCredits) {
+		t.Fatalf("Begin err = %v, want ErrInsufficientCredits", err)
+	}
+	if err := Check(call); !errors.Is(err, ErrInsufficientCredits) {
+		t.Fatalf("Check err = %v, want ErrInsufficientCredits", err)
+	}
+	if got := credits(t, userID); got != 2*Micros {
+		t.Errorf("credits after rejected call = %d, want %d", got, 2*Micros)
+	}
+
+	// 用户自己的 key 不消耗额度
+	call.KeySource = models.KeySourceUser
+	ch, err = Begin(call)
+	if err != nil {
+		t.Fatalf("Begin with user key: %v", err)
+	}
+	ch.Finish(3, nil)
+	if got := credits(t, userID); got != 2*Micros {
+		t.Errorf("credits after user-key call = %d, want %d", got, 2*Micros)
+	}
+
+	// nil 的 Charge 可以直接 Finish
+	var none *Charge
+	none.Finish(1, nil)
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	setup(t)
+	config.Config.MonthlyImageQuota = 2
+	call := Call{UserID: uuid.New(), Source: "generate", Model: "m", N: 3}
+	if err := Check(call); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Check err = %v, want ErrQuotaExceeded", err)
+	}
+	call.N = 2
+	if err := Check(call); err != nil {
+		t.Fatalf("Check within quota: %v", err)
+	}
+}
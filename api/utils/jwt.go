@@ -10,30 +10,34 @@ import (
 )
 
 type JWTClaims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	SessionID uuid.UUID `json:"sid"` // 所属登录会话，会话吊销后令牌失效
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID uuid.UUID, email string) (string, error) {
+// GenerateJWT 签发短期 access token，jti 用于注销时加入吊销列表
+func GenerateJWT(userID uuid.UUID, email string, sessionID uuid.UUID) (string, error) {
+	now := time.Now()
 	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			Issuer:    config.Config.JWTIssuer,
+			Subject:   userID.String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.Config.AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.Config.JWTSecret))
+	return currentKeys().sign(claims)
 }
 
 func ValidateJWT(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.Config.JWTSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, currentKeys().keyFunc, jwt.WithIssuer(config.Config.JWTIssuer))
 
 	if err != nil {
 		return nil, err
@@ -45,15 +49,3 @@ func ValidateJWT(tokenString string) (*JWTClaims, error) {
 
 	return nil, errors.New("invalid token")
 }
-
-func HashPassword(password string) (string, error) {
-	// 这里应该使用 bcrypt 或其他安全的哈希算法
-	// 为了简化，暂时直接返回
-	return password, nil
-}
-
-func CheckPassword(hashedPassword, password string) bool {
-	// 这里应该使用 bcrypt 或其他安全的哈希算法
-	// 为了简化，暂时直接比较
-	return hashedPassword == password
-}
\ No newline at end of file
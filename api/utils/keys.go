@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"ai-design-backend/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultJWTSecret 未配置 JWT_SECRET 时的占位值，生产环境禁止使用
+const DefaultJWTSecret = "your-jwt-secret-key"
+
+// verifyKey 用于校验签名的公钥（或 HS256 共享密钥）
+type verifyKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+// KeySet 当前签名密钥以及轮换期间仍然接受的校验密钥
+type KeySet struct {
+	signingKID string
+	signing    interface{}
+	method     jwt.SigningMethod
+	verify     map[string]verifyKey
+}
+
+var keys *KeySet
+
+// InitKeys 从 JWT_KEYS_DIR 加载 RS256/EdDSA 密钥；未配置目录时退回 HS256 共享密钥。
+// 目录中 <kid>.pem 为私钥（可签名），<kid>.pub.pem 为仅用于校验的公钥（已退役或外部签发）。
+func InitKeys() {
+	cfg := config.Config
+	if cfg.JWTKeysDir == "" {
+		if cfg.JWTSecret == DefaultJWTSecret || cfg.JWTSecret == "" {
+			if cfg.Environment == "production" {
+				log.Fatal("Refusing to start in production with the default JWT_SECRET; set JWT_SECRET or JWT_KEYS_DIR")
+			}
+			log.Println("WARNING: using the default JWT_SECRET, do not use this in production")
+		}
+		keys = hmacKeySet(cfg.JWTSecret)
+		return
+	}
+
+	set, err := LoadKeySet(cfg.JWTKeysDir, cfg.JWTSigningKeyID)
+	if err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+	keys = set
+	log.Printf("Signing JWTs with %s key %q (%d verification keys)", set.method.Alg(), set.signingKID, len(set.verify))
+}
+
+func hmacKeySet(secret string) *KeySet {
+	return &KeySet{
+		method:  jwt.SigningMethodHS256,
+		signing: []byte(secret),
+		verify: map[string]verifyKey{
+			"": {method: jwt.SigningMethodHS256, key: []byte(secret)},
+		},
+	}
+}
+
+func currentKeys() *KeySet {
+	if keys == nil {
+		keys = hmacKeySet(config.Config.JWTSecret)
+	}
+	return keys
+}
+
+// LoadKeySet 读取目录下的 PEM 密钥，signingKID 为空且只有一个私钥时自动选用
+func LoadKeySet(dir, signingKID string) (*KeySet, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{verify: make(map[string]verifyKey)}
+	private := make(map[string]crypto.Signer)
+	for _, file := range files {
+		name := filepath.Base(file)
+		kid := strings.TrimSuffix(strings.TrimSuffix(name, ".pem"), ".pub")
+
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parsePEMKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		var public crypto.PublicKey
+		if signer, ok := key.(crypto.Signer); ok {
+			private[kid] = signer
+			public = signer.Public()
+		} else {
+			public = key
+		}
+
+		method, err := methodFor(public)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		set.verify[kid] = verifyKey{kid: kid, method: method, key: public}
+	}
+
+	if signingKID == "" {
+		if len(private) != 1 {
+			return nil, fmt.Errorf("found %d private keys in %s, set JWT_SIGNING_KEY_ID", len(private), dir)
+		}
+		for kid := range private {
+			signingKID = kid
+		}
+	}
+	signer, ok := private[signingKID]
+	if !ok {
+		return nil, fmt.Errorf("private key %q not found in %s", signingKID, dir)
+	}
+
+	set.signingKID = signingKID
+	set.signing = signer
+	set.method = set.verify[signingKID].method
+	return set, nil
+}
+
+func parsePEMKey(raw []byte) (interface{}, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+func methodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", public)
+}
+
+// keyFunc 按 kid 选择校验密钥，并要求签名算法与密钥类型一致，防止算法混淆
+func (s *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.key, nil
+}
+
+func (s *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.method, claims)
+	if s.signingKID != "" {
+		token.Header["kid"] = s.signingKID
+	}
+	return token.SignedString(s.signing)
+}
+
+// JWK 公钥的 JSON Web Key 表示
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS 返回所有可公开的校验公钥；HS256 共享密钥不会出现在这里
+func JWKS() []JWK {
+	set := currentKeys()
+	list := []JWK{}
+	for _, k := range set.verify {
+		b64 := base64.RawURLEncoding.EncodeToString
+		switch pub := k.key.(type) {
+		case *rsa.PublicKey:
+			list = append(list, JWK{
+				Kty: "RSA", Kid: k.kid, Use: "sig", Alg: k.method.Alg(),
+				N: b64(pub.N.Bytes()),
+				E: b64(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			list = append(list, JWK{
+				Kty: "OKP", Kid: k.kid, Use: "sig", Alg: k.method.Alg(),
+				Crv: "Ed25519",
+				X:   b64(pub),
+			})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Kid < list[j].Kid })
+	return list
+}
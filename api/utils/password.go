@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"ai-design-backend/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrPasswordTooLong = errors.New("password must not exceed 72 bytes")
+
+// HashPassword 使用 bcrypt 加密密码，结果形如 $2a$12$...，自带算法与 cost 信息
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost())
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", ErrPasswordTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword 校验密码；兼容旧版本直接保存的明文密码，
+// 登录成功后应通过 NeedsRehash 判断是否需要升级
+func CheckPassword(hashedPassword, password string) bool {
+	if isBcrypt(hashedPassword) {
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(hashedPassword), []byte(password)) == 1
+}
+
+// NeedsRehash 明文密码或 cost 低于当前配置的哈希都需要重新加密
+func NeedsRehash(hashedPassword string) bool {
+	if !isBcrypt(hashedPassword) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost < passwordCost()
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func passwordCost() int {
+	cost := config.Config.BcryptCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
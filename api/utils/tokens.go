@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewRefreshToken 生成随机 refresh token，返回明文与用于存储的哈希
+func NewRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// AccessKeyPrefix 服务签发的 API Key 前缀，用于与 JWT 和上游 key 区分
+const AccessKeyPrefix = "adk_"
+
+// NewAccessKey 生成用户 API Key，返回明文与用于存储的哈希
+func NewAccessKey() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key := AccessKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return key, HashToken(key), nil
+}
+
+// HashToken 计算令牌的 SHA-256，数据库中只保存该值
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,182 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"ai-design-backend/config"
+)
+
+var (
+	ErrDisabled   = errors.New("vault is not configured")
+	ErrUnknownKey = errors.New("vault: unknown master key")
+)
+
+// Sealed 信封加密的结果：每条数据使用独立的随机数据密钥（DEK）加密，
+// DEK 再由主密钥加密保存。KeyID 标识加密 DEK 所用的主密钥，便于轮换。
+type Sealed struct {
+	KeyID      string
+	WrappedKey string // base64(nonce || AES-GCM(master, DEK))
+	Ciphertext string // base64(nonce || AES-GCM(DEK, plaintext))
+}
+
+// Vault 持有当前主密钥以及轮换前仍可用于解密的旧主密钥
+type Vault struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+}
+
+// Default 未配置 VAULT_MASTER_KEY 时为 nil
+var Default *Vault
+
+func Init() {
+	if config.Config.VaultMasterKey == "" {
+		log.Println("WARNING: VAULT_MASTER_KEY is not set, user API key storage is disabled")
+		return
+	}
+
+	master, err := DecodeKey(config.Config.VaultMasterKey)
+	if err != nil {
+		log.Fatal("Invalid VAULT_MASTER_KEY:", err)
+	}
+	var previous [][]byte
+	for _, encoded := range config.Config.VaultPreviousKeys {
+		key, err := DecodeKey(encoded)
+		if err != nil {
+			log.Fatal("Invalid VAULT_PREVIOUS_KEYS:", err)
+		}
+		previous = append(previous, key)
+	}
+
+	v, err := New(master, previous...)
+	if err != nil {
+		log.Fatal("Failed to init vault:", err)
+	}
+	Default = v
+	log.Printf("Vault enabled with master key %s", v.currentID)
+}
+
+func Enabled() bool {
+	return Default != nil
+}
+
+// DecodeKey 解析 base64 或十六进制编码的 32 字节主密钥
+func DecodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("master key must be 32 bytes encoded as base64 or hex")
+}
+
+func New(master []byte, previous ...[]byte) (*Vault, error) {
+	v := &Vault{keys: make(map[string]cipher.AEAD)}
+	for i, key := range append([][]byte{master}, previous...) {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(key)
+		if i == 0 {
+			v.currentID = id
+		}
+		v.keys[id] = aead
+	}
+	return v, nil
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, aad []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	out := aead.Seal(nonce, nonce, plaintext, aad)
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+func open(aead cipher.AEAD, encoded string, aad []byte) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < aead.NonceSize() {
+		return nil, errors.New("vault: ciphertext too short")
+	}
+	return aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], aad)
+}
+
+// Seal 加密 plaintext；aad 为附加认证数据（如记录所属用户 ID），解密时必须一致，
+// 防止密文被挪用到其他记录
+func (v *Vault) Seal(plaintext, aad []byte) (Sealed, error) {
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return Sealed{}, err
+	}
+	dataAEAD, err := newAEAD(dek)
+	if err != nil {
+		return Sealed{}, err
+	}
+
+	ciphertext, err := seal(dataAEAD, plaintext, aad)
+	if err != nil {
+		return Sealed{}, err
+	}
+	wrapped, err := seal(v.keys[v.currentID], dek, []byte(v.currentID))
+	if err != nil {
+		return Sealed{}, err
+	}
+	return Sealed{KeyID: v.currentID, WrappedKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+func (v *Vault) Open(s Sealed, aad []byte) ([]byte, error) {
+	master, ok := v.keys[s.KeyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	dek, err := open(master, s.WrappedKey, []byte(s.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("vault: unwrap data key: %w", err)
+	}
+	dataAEAD, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataAEAD, s.Ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("vault: decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// NeedsRewrap 数据是否由旧主密钥加密，需要用当前主密钥重新加密
+func (v *Vault) NeedsRewrap(s Sealed) bool {
+	return s.KeyID != v.currentID
+}
@@ -0,0 +1,114 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestDecodeKey(t *testing.T) {
+	key := testKey(7)
+	for _, encoded := range []string{hex.EncodeToString(key), base64.StdEncoding.EncodeToString(key), " " + hex.EncodeToString(key) + "\n"} {
+		got, err := DecodeKey(encoded)
+		if err != nil || !bytes.Equal(got, key) {
+			t.Errorf("DecodeKey(%q) = %x, %v", encoded, got, err)
+		}
+	}
+	for _, bad := range []string{"", "short", hex.EncodeToString(key[:16]), base64.StdEncoding.EncodeToString(append(key, 1))} {
+		if _, err := DecodeKey(bad); err == nil {
+			t.Errorf("DecodeKey(%q) succeeded, want error", bad)
+		}
+	}
+	if _, err := New(testKey(1)[:16]); err == nil {
+		t.Error("New accepted a 16-byte master key")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	v, err := New(testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aad := []byte("user-1")
+	s, err := v.Seal([]byte("sk-secret"), aad)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if bytes.Contains([]byte(s.Ciphertext+s.WrappedKey), []byte("sk-secret")) {
+		t.Fatal("plaintext leaked into sealed data")
+	}
+	got, err := v.Open(s, aad)
+	if err != nil || string(got) != "sk-secret" {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	// 每次加密使用独立的数据密钥与 nonce
+	again, _ := v.Seal([]byte("sk-secret"), aad)
+	if again.Ciphertext == s.Ciphertext || again.WrappedKey == s.WrappedKey {
+		t.Error("sealing the same plaintext twice produced identical output")
+	}
+
+	// 密文不能挪用到其他记录
+	if _, err := v.Open(s, []byte("user-2")); err == nil {
+		t.Error("Open succeeded with a different AAD")
+	}
+
+	tampered := s
+	raw, _ := base64.StdEncoding.DecodeString(s.Ciphertext)
+	raw[len(raw)-1] ^= 1
+	tampered.Ciphertext = base64.StdEncoding.EncodeToString(raw)
+	if _, err := v.Open(tampered, aad); err == nil {
+		t.Error("Open succeeded on tampered ciphertext")
+	}
+
+	// 换用其他数据的 DEK 无法解密
+	swapped := s
+	swapped.WrappedKey = again.WrappedKey
+	if _, err := v.Open(swapped, aad); err == nil {
+		t.Error("Open succeeded with another record's data key")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldVault, _ := New(testKey(1))
+	s, err := oldVault.Seal([]byte("sk-old"), []byte("org"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换后旧主密钥仍可解密，但需要重新加密
+	rotated, err := New(testKey(2), testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rotated.NeedsRewrap(s) {
+		t.Error("NeedsRewrap = false for data sealed with the previous key")
+	}
+	got, err := rotated.Open(s, []byte("org"))
+	if err != nil || string(got) != "sk-old" {
+		t.Fatalf("Open with previous key = %q, %v", got, err)
+	}
+
+	resealed, err := rotated.Seal(got, []byte("org"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.NeedsRewrap(resealed) || resealed.KeyID == s.KeyID {
+		t.Errorf("resealed with key %s, want the current key", resealed.KeyID)
+	}
+
+	// 移除旧主密钥后无法解密
+	current, _ := New(testKey(2))
+	if _, err := current.Open(s, []byte("org")); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Open without previous key: err = %v, want ErrUnknownKey", err)
+	}
+	if got, err := current.Open(resealed, []byte("org")); err != nil || string(got) != "sk-old" {
+		t.Errorf("Open resealed = %q, %v", got, err)
+	}
+}
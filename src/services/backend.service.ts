@@ -176,9 +176,10 @@ class BackendAPIService {
 
   // 图片生成相关
   async generateImage(request: GenerateImageRequest): Promise<GenerateImageResponse> {
+    // 生成接口默认异步返回任务ID，这里需要直接拿到图片，使用同步模式
     const response = await this.makeRequest<GenerateImageResponse>('/generate/image', {
       method: 'POST',
-      body: JSON.stringify(request),
+      body: JSON.stringify({ ...request, async: false }),
     })
     
     if (response.data) {
@@ -196,6 +197,7 @@ class BackendAPIService {
         model,
         size,
         project_id: projectId,
+        async: false,
       }),
     })
     